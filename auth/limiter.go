@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Reasons reported by LimitError, also used as metric labels.
+const (
+	ReasonRateLimited   = "rate_limited"
+	ReasonQuotaExceeded = "quota_exceeded"
+)
+
+// LimitError is returned when a key has exceeded its rate limit or daily quota.
+type LimitError struct {
+	Reason     string        // ReasonRateLimited or ReasonQuotaExceeded
+	RetryAfter time.Duration // When the request may be retried
+}
+
+// Error implements the error interface.
+func (e *LimitError) Error() string {
+	if e.Reason == ReasonQuotaExceeded {
+		return "daily analysis quota exceeded"
+	}
+	return fmt.Sprintf("rate limit exceeded, retry in %s", e.RetryAfter)
+}
+
+// LimitConfig holds the limits applied to keys that do not set their own.
+type LimitConfig struct {
+	RateLimit  float64 // Requests per minute; 0 means unlimited
+	Burst      int     // Requests allowed at once before the rate applies; at least 1
+	DailyQuota int     // Analyses per UTC day; 0 means unlimited
+}
+
+// Limiter enforces per-key request rates with token buckets and counts analyses per UTC day.
+// State is kept in memory, so limits reset when the process restarts.
+type Limiter struct {
+	mu      sync.Mutex
+	cfg     LimitConfig
+	buckets map[string]*rate.Limiter // Token bucket per key ID
+	usage   map[string]int           // Analyses per key ID on day
+	day     string                   // UTC date the usage counts belong to
+	now     func() time.Time         // Clock, replaceable in tests
+}
+
+// NewLimiter creates a limiter with the given defaults.
+func NewLimiter(cfg LimitConfig) *Limiter {
+	if cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+	return &Limiter{
+		cfg:     cfg,
+		buckets: make(map[string]*rate.Limiter),
+		usage:   make(map[string]int),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the key's bucket. It returns a *LimitError if the bucket
+// is empty. Analyses are counted against the daily quota separately, with Charge.
+func (l *Limiter) Allow(k Key) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+
+	if perMinute := l.rateFor(k); perMinute > 0 {
+		bucket, ok := l.buckets[k.ID]
+		if !ok || bucket.Limit() != rate.Limit(perMinute/60) {
+			bucket = rate.NewLimiter(rate.Limit(perMinute/60), l.cfg.Burst)
+			l.buckets[k.ID] = bucket
+		}
+		r := bucket.ReserveN(now, 1)
+		if delay := r.DelayFrom(now); delay > 0 {
+			r.CancelAt(now) // Rejected requests do not use up tokens
+			return &LimitError{Reason: ReasonRateLimited, RetryAfter: delay}
+		}
+	}
+	return nil
+}
+
+// Charge counts n further analyses against the key's daily quota, all or none, for
+// requests that start several analyses at once such as batches. It returns a
+// *LimitError if the quota does not cover all of them.
+func (l *Limiter) Charge(k Key, n int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.count(k, n, l.now())
+}
+
+// Refund takes back n analyses charged today, for requests that were rejected after
+// they were charged, e.g. because the server was overloaded.
+func (l *Limiter) Refund(k Key, n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.now().UTC().Format(time.DateOnly) != l.day {
+		return // The charge was made yesterday and is already forgotten
+	}
+	l.usage[k.ID] = max(l.usage[k.ID]-n, 0)
+}
+
+// count adds n analyses to the key's usage today unless that exceeds its quota.
+// l.mu must be held.
+func (l *Limiter) count(k Key, n int, now time.Time) error {
+	if day := now.UTC().Format(time.DateOnly); day != l.day {
+		l.day = day
+		clear(l.usage)
+	}
+	if quota := l.quotaFor(k); quota > 0 && l.usage[k.ID]+n > quota {
+		midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+		return &LimitError{Reason: ReasonQuotaExceeded, RetryAfter: midnight.Sub(now)}
+	}
+	l.usage[k.ID] += n
+	return nil
+}
+
+// Usage returns the number of analyses counted for the key today.
+func (l *Limiter) Usage(id string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.now().UTC().Format(time.DateOnly) != l.day {
+		return 0
+	}
+	return l.usage[id]
+}
+
+// rateFor returns the requests per minute allowed for k.
+func (l *Limiter) rateFor(k Key) float64 {
+	if k.RateLimit > 0 {
+		return k.RateLimit
+	}
+	return l.cfg.RateLimit
+}
+
+// quotaFor returns the daily analysis quota of k.
+func (l *Limiter) quotaFor(k Key) int {
+	if k.DailyQuota > 0 {
+		return k.DailyQuota
+	}
+	return l.cfg.DailyQuota
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestLimiter_Rate checks the token bucket and the retry delay it reports
+func TestLimiter_Rate(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter(LimitConfig{RateLimit: 60, Burst: 2})
+	l.now = func() time.Time { return now }
+	key := Key{ID: "k1"}
+
+	for i := 0; i < 2; i++ {
+		if err := l.Allow(key); err != nil {
+			t.Fatalf("request %d within burst rejected: %v", i, err)
+		}
+	}
+	var limitErr *LimitError
+	if err := l.Allow(key); !errors.As(err, &limitErr) || limitErr.Reason != ReasonRateLimited {
+		t.Fatalf("third request error = %v; want rate limit", err)
+	}
+	if limitErr.RetryAfter <= 0 || limitErr.RetryAfter > time.Second {
+		t.Errorf("RetryAfter = %s; want up to 1s at 60 requests per minute", limitErr.RetryAfter)
+	}
+
+	// Tokens refill over time
+	now = now.Add(time.Second)
+	if err := l.Allow(key); err != nil {
+		t.Errorf("request after refill rejected: %v", err)
+	}
+
+	// A key's own limit overrides the default
+	if err := l.Allow(Key{ID: "k2", RateLimit: 6000}); err != nil {
+		t.Errorf("request on key with own limit rejected: %v", err)
+	}
+}
+
+// TestLimiter_Quota checks the daily quota and its reset at UTC midnight
+func TestLimiter_Quota(t *testing.T) {
+	now := time.Date(2025, 6, 1, 23, 0, 0, 0, time.UTC)
+	l := NewLimiter(LimitConfig{})
+	l.now = func() time.Time { return now }
+	key := Key{ID: "k1", DailyQuota: 2}
+
+	for i := 0; i < 2; i++ {
+		if err := l.Charge(key, 1); err != nil {
+			t.Fatalf("analysis %d within quota rejected: %v", i, err)
+		}
+	}
+	// Requests only pass the rate limit; they count once their analyses are charged
+	if err := l.Allow(key); err != nil {
+		t.Errorf("request rejected: %v", err)
+	}
+
+	var limitErr *LimitError
+	if err := l.Charge(key, 1); !errors.As(err, &limitErr) || limitErr.Reason != ReasonQuotaExceeded {
+		t.Fatalf("analysis over quota error = %v; want quota exceeded", err)
+	}
+	if limitErr.RetryAfter != time.Hour {
+		t.Errorf("RetryAfter = %s; want 1h until midnight", limitErr.RetryAfter)
+	}
+	if got := l.Usage("k1"); got != 2 {
+		t.Errorf("Usage = %d; want 2", got)
+	}
+
+	// A refunded analysis can be used again
+	l.Refund(key, 1)
+	if err := l.Charge(key, 1); err != nil {
+		t.Errorf("analysis after refund rejected: %v", err)
+	}
+
+	now = now.Add(time.Hour)
+	if err := l.Charge(key, 1); err != nil {
+		t.Errorf("analysis on the next day rejected: %v", err)
+	}
+}
+
+// TestLimiter_Charge checks that multi-analysis requests are counted all or none
+func TestLimiter_Charge(t *testing.T) {
+	l := NewLimiter(LimitConfig{DailyQuota: 10})
+	key := Key{ID: "k1"}
+
+	if err := l.Charge(key, 8); err != nil {
+		t.Fatalf("Charge(8) rejected: %v", err)
+	}
+	var limitErr *LimitError
+	if err := l.Charge(key, 3); !errors.As(err, &limitErr) || limitErr.Reason != ReasonQuotaExceeded {
+		t.Errorf("Charge(3) over quota error = %v; want quota exceeded", err)
+	}
+	if got := l.Usage("k1"); got != 8 {
+		t.Errorf("Usage = %d after rejected charge; want 8", got)
+	}
+	if err := l.Charge(key, 2); err != nil {
+		t.Errorf("Charge(2) up to the quota rejected: %v", err)
+	}
+}
+
+// TestLimiter_Refund checks that refunded analyses can be used again
+func TestLimiter_Refund(t *testing.T) {
+	l := NewLimiter(LimitConfig{})
+	key := Key{ID: "k1", DailyQuota: 2}
+	if err := l.Charge(key, 2); err != nil {
+		t.Fatalf("Charge(2) rejected: %v", err)
+	}
+	l.Refund(key, 3)
+	if got := l.Usage("k1"); got != 0 {
+		t.Errorf("Usage = %d after refund; want 0", got)
+	}
+	if err := l.Charge(key, 2); err != nil {
+		t.Errorf("Charge(2) after refund rejected: %v", err)
+	}
+}
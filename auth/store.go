@@ -0,0 +1,208 @@
+// Package auth manages API keys and enforces their rate limits and daily quotas.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Errors returned when a request cannot be authenticated.
+var (
+	ErrMissingKey  = errors.New("API key required")
+	ErrInvalidKey  = errors.New("invalid or revoked API key")
+	ErrKeyNotFound = errors.New("API key not found")
+)
+
+// secretPrefix marks API keys so that they are easy to recognize, e.g. in secret scanners.
+const secretPrefix = "lt_"
+
+// Key is a stored API key. Only a hash of the secret is kept; the secret itself
+// is shown once, when the key is created.
+type Key struct {
+	ID         string     `json:"id"`                    // Public identifier, safe to log
+	Name       string     `json:"name"`                  // Owner or purpose of the key
+	Hash       string     `json:"hash,omitempty"`        // SHA-256 of the secret, hex encoded
+	RateLimit  float64    `json:"rate_limit,omitempty"`  // Requests per minute; 0 uses the default
+	DailyQuota int        `json:"daily_quota,omitempty"` // Analyses per UTC day; 0 uses the default
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"` // Set once the key has been revoked
+}
+
+// Revoked reports whether the key may no longer be used.
+func (k Key) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// Store holds API keys in memory and persists them to a JSON file.
+type Store struct {
+	mu   sync.Mutex
+	path string // File the keys are saved to; empty keeps them in memory only
+	keys []Key  // In creation order
+}
+
+// OpenStore loads the keys saved at path, starting empty if the file does not exist yet.
+// An empty path creates a store that is not persisted.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read API key store: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.keys); err != nil {
+		return nil, fmt.Errorf("invalid API key store %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Create adds a key and returns it together with its secret, which is not stored.
+func (s *Store) Create(name string, rateLimit float64, dailyQuota int) (Key, string, error) {
+	if rateLimit < 0 || dailyQuota < 0 {
+		return Key{}, "", errors.New("rate limit and daily quota must not be negative")
+	}
+	id, err := randomHex(4)
+	if err != nil {
+		return Key{}, "", err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return Key{}, "", err
+	}
+	secret = secretPrefix + secret
+
+	key := Key{
+		ID:         id,
+		Name:       name,
+		Hash:       hashSecret(secret),
+		RateLimit:  rateLimit,
+		DailyQuota: dailyQuota,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = append(s.keys, key)
+	if err := s.save(); err != nil {
+		s.keys = s.keys[:len(s.keys)-1]
+		return Key{}, "", err
+	}
+	return key, secret, nil
+}
+
+// Revoke marks the key with the given ID as revoked. Revoked keys are kept for auditing.
+func (s *Store) Revoke(id string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.keys {
+		if s.keys[i].ID != id {
+			continue
+		}
+		if !s.keys[i].Revoked() {
+			now := time.Now().UTC()
+			s.keys[i].RevokedAt = &now
+			if err := s.save(); err != nil {
+				s.keys[i].RevokedAt = nil
+				return Key{}, err
+			}
+		}
+		return s.keys[i], nil
+	}
+	return Key{}, ErrKeyNotFound
+}
+
+// List returns all keys, including revoked ones, in creation order.
+func (s *Store) List() []Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Key(nil), s.keys...)
+}
+
+// Lookup returns the active key matching secret.
+func (s *Store) Lookup(secret string) (Key, error) {
+	if secret == "" {
+		return Key{}, ErrMissingKey
+	}
+	hash := hashSecret(secret)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hash)) == 1 {
+			if k.Revoked() {
+				return Key{}, ErrInvalidKey
+			}
+			return k, nil
+		}
+	}
+	return Key{}, ErrInvalidKey
+}
+
+// Ping checks that the store can be written, for readiness checks.
+func (s *Store) Ping() error {
+	if s.path == "" {
+		return nil
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.path), ".api-keys-ping-*")
+	if err != nil {
+		return fmt.Errorf("API key store is not writable: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// save writes the keys to the store file, replacing it atomically. Callers hold s.mu.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".api-keys-*")
+	if err != nil {
+		return fmt.Errorf("unable to save API key store: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op after a successful rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to save API key store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to save API key store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("unable to save API key store: %w", err)
+	}
+	return nil
+}
+
+// hashSecret returns the hex-encoded SHA-256 of an API key secret.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate API key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
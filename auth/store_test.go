@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestStore covers creating, looking up, revoking and reloading keys
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	s, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore returned error: %v", err)
+	}
+
+	key, secret, err := s.Create("ci", 30, 100)
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if !strings.HasPrefix(secret, secretPrefix) || strings.Contains(key.Hash, secret) {
+		t.Errorf("secret = %q, hash = %q; want prefixed secret that is not stored", secret, key.Hash)
+	}
+
+	if got, err := s.Lookup(secret); err != nil || got.ID != key.ID {
+		t.Errorf("Lookup = %+v, %v; want key %s", got, err, key.ID)
+	}
+	if _, err := s.Lookup("lt_wrong"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Lookup(wrong) error = %v; want ErrInvalidKey", err)
+	}
+	if _, err := s.Lookup(""); !errors.Is(err, ErrMissingKey) {
+		t.Errorf("Lookup(\"\") error = %v; want ErrMissingKey", err)
+	}
+
+	// Keys survive a restart
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("reopening store returned error: %v", err)
+	}
+	if got, err := reopened.Lookup(secret); err != nil || got.Name != "ci" || got.DailyQuota != 100 {
+		t.Errorf("Lookup after reopen = %+v, %v; want key ci", got, err)
+	}
+
+	// Revoked keys are kept but rejected
+	if _, err := s.Revoke(key.ID); err != nil {
+		t.Fatalf("Revoke returned error: %v", err)
+	}
+	if _, err := s.Lookup(secret); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Lookup(revoked) error = %v; want ErrInvalidKey", err)
+	}
+	if keys := s.List(); len(keys) != 1 || !keys[0].Revoked() {
+		t.Errorf("List = %+v; want one revoked key", keys)
+	}
+	if _, err := s.Revoke("unknown"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Revoke(unknown) error = %v; want ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,511 @@
+// Package batch analyzes lists of URLs in the background with bounded parallelism.
+// Each URL is analyzed independently, so a URL that fails, times out or even panics
+// is recorded as a failed item without affecting the rest of the batch. A batch can
+// also be seeded from a site's sitemaps, which are audited along the way, or from a
+// crawl of the site, which builds its internal link graph.
+package batch
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"lucytech/crawl"
+	"lucytech/logging"
+	"lucytech/metrics"
+	"lucytech/parser"
+	"lucytech/sitemap"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Errors returned when submitting or looking up batches.
+var (
+	ErrNoURLs      = errors.New("no URLs given")
+	ErrTooManyURLs = errors.New("too many URLs")
+	ErrTooManyJobs = errors.New("too many batches running")
+	ErrJobNotFound = errors.New("batch not found")
+)
+
+// itemTimeout is the upper bound on analyzing one URL of a batch.
+const itemTimeout = 5 * time.Minute
+
+// Batch and item statuses.
+const (
+	StatusPending  = "pending"  // Item: waiting for a worker
+	StatusRunning  = "running"  // Item or batch: being analyzed
+	StatusDone     = "done"     // Item or batch: finished; for a batch, failed items included
+	StatusFailed   = "failed"   // Item: the analysis returned an error; batch: the sitemap or start URL could not be read
+	StatusCanceled = "canceled" // Item or batch: stopped before it finished
+)
+
+// Item is the outcome of analyzing one URL of a batch.
+type Item struct {
+	URL        string                 `json:"url"`
+	Status     string                 `json:"status"`
+	Result     *parser.AnalysisResult `json:"result,omitempty"`      // Set once the analysis succeeded
+	Error      string                 `json:"error,omitempty"`       // Why the analysis failed
+	DurationMS int64                  `json:"duration_ms,omitempty"` // Time taken to analyze the URL
+}
+
+// Job is a snapshot of a batch and its items.
+type Job struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Total      int        `json:"total"`     // Number of URLs in the batch; grows while a crawl runs
+	Completed  int        `json:"completed"` // Items that are done, failed or canceled
+	Failed     int        `json:"failed"`    // Items whose analysis failed
+	Items      []Item     `json:"items"`     // In submission order
+
+	SitemapURL string          `json:"sitemap_url,omitempty"` // Site or sitemap the batch was seeded from
+	Sitemap    *sitemap.Report `json:"sitemap,omitempty"`     // Audit of the sitemaps, once they have been read
+	CrawlURL   string          `json:"crawl_url,omitempty"`   // Start URL of a crawl
+	Crawl      *crawl.Graph    `json:"crawl,omitempty"`       // Link graph, once the crawl has finished
+	Error      string          `json:"error,omitempty"`       // Why the batch failed as a whole
+}
+
+// Config bounds the work done by batches. Zero values select the defaults.
+type Config struct {
+	Parallelism int           // URLs analyzed at once per batch; default 4
+	MaxURLs     int           // URLs accepted per batch; default 1000
+	MaxRunning  int           // Batches running at once; default 4
+	Retention   time.Duration // How long finished batches are kept; default 1 hour
+}
+
+// job is a batch with the means to cancel it.
+type job struct {
+	Job
+	cancel context.CancelFunc
+}
+
+// Manager runs batches and keeps their results until they expire.
+type Manager struct {
+	cfg Config
+
+	// Analyze performs each analysis; replaceable in tests.
+	Analyze func(ctx context.Context, rawURL string, opts parser.Options) (*parser.AnalysisResult, error)
+	// Audit reads and checks the sitemaps of batches seeded from a sitemap; replaceable in tests.
+	Audit func(ctx context.Context, rawURL string, cfg sitemap.Config) (*sitemap.Report, error)
+	// Crawl crawls the site of batches seeded from a crawl; replaceable in tests.
+	Crawl func(ctx context.Context, rawURL string, cfg crawl.Config, visit crawl.Visit) (*crawl.Graph, error)
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	running int
+	now     func() time.Time
+}
+
+// NewManager returns a manager with the given limits.
+func NewManager(cfg Config) *Manager {
+	if cfg.Parallelism <= 0 {
+		cfg.Parallelism = 4
+	}
+	if cfg.MaxURLs <= 0 {
+		cfg.MaxURLs = 1000
+	}
+	if cfg.MaxRunning <= 0 {
+		cfg.MaxRunning = 4
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = time.Hour
+	}
+	return &Manager{
+		cfg:     cfg,
+		Analyze: parser.AnalyzePage,
+		Audit:   sitemap.Audit,
+		Crawl:   crawl.Crawl,
+		jobs:    make(map[string]*job),
+		now:     time.Now,
+	}
+}
+
+// Config returns the limits the manager was created with, defaults filled in.
+func (m *Manager) Config() Config {
+	return m.cfg
+}
+
+// Submit starts analyzing the URLs in the background and returns the new batch.
+// The batch runs detached from ctx, so it continues after the request ends, but logs
+// with the logger of ctx, so its messages carry the request ID.
+func (m *Manager) Submit(ctx context.Context, urls []string, opts parser.Options) (Job, error) {
+	if len(urls) == 0 {
+		return Job{}, ErrNoURLs
+	}
+	if len(urls) > m.cfg.MaxURLs {
+		return Job{}, fmt.Errorf("%w: %d given, at most %d allowed", ErrTooManyURLs, len(urls), m.cfg.MaxURLs)
+	}
+	ctx, j, snapshot, err := m.start(ctx, urls, "", "")
+	if err != nil {
+		return Job{}, err
+	}
+	go m.run(ctx, j, opts)
+	return snapshot, nil
+}
+
+// SubmitSitemap starts a batch that audits the sitemaps of a site, or the sitemap at
+// rawURL, and then analyzes the listed URLs that robots.txt allows. The number of URLs
+// is known only once the sitemaps are read; charge, if not nil, is then called with
+// the number of analyses beyond the first, which the submission itself accounts for.
+// An error from charge, e.g. because a quota is exhausted, fails the batch.
+func (m *Manager) SubmitSitemap(ctx context.Context, rawURL string, opts parser.Options, charge func(n int) error) (Job, error) {
+	if strings.TrimSpace(rawURL) == "" {
+		return Job{}, ErrNoURLs
+	}
+	ctx, j, snapshot, err := m.start(ctx, nil, rawURL, "")
+	if err != nil {
+		return Job{}, err
+	}
+	go func() {
+		if err := m.seed(ctx, j, charge); err != nil {
+			logging.FromContext(ctx).Warn("Unable to seed batch from sitemap", "batch_id", j.ID, "url", rawURL, "error", err)
+			m.finish(ctx, j, err)
+			return
+		}
+		m.run(ctx, j, opts)
+	}()
+	return snapshot, nil
+}
+
+// SubmitCrawl starts a batch that crawls the site from rawURL, analyzing each internal
+// HTML page it reaches as an item, and builds the site's internal link graph. Links are
+// not checked by the analyses, as the crawl requests internal pages itself. MaxPages is
+// capped at the manager's MaxURLs. charge, if not nil, is called for every page after
+// the first, which the submission itself accounts for; an error from it stops the crawl,
+// and the graph built so far is kept.
+func (m *Manager) SubmitCrawl(ctx context.Context, rawURL string, cfg crawl.Config, opts parser.Options, charge func(n int) error) (Job, error) {
+	if strings.TrimSpace(rawURL) == "" {
+		return Job{}, ErrNoURLs
+	}
+	if cfg.MaxPages <= 0 || cfg.MaxPages > m.cfg.MaxURLs {
+		cfg.MaxPages = m.cfg.MaxURLs
+	}
+	cfg.Parallelism = m.cfg.Parallelism
+	opts.SkipLinkChecks = true
+
+	ctx, j, snapshot, err := m.start(ctx, nil, "", rawURL)
+	if err != nil {
+		return Job{}, err
+	}
+	go func() {
+		crawlCtx, stop := context.WithCancelCause(ctx)
+		defer stop(nil)
+		var pages atomic.Int64
+		visit := func(ctx context.Context, pageURL string) (*parser.AnalysisResult, error) {
+			if pages.Add(1) > 1 && charge != nil {
+				if err := charge(1); err != nil {
+					stop(err)
+					return nil, err
+				}
+			}
+			return m.visit(ctx, j, pageURL, opts)
+		}
+
+		graph, err := m.Crawl(crawlCtx, rawURL, cfg, visit)
+		if err != nil {
+			logging.FromContext(ctx).Warn("Unable to crawl site", "batch_id", j.ID, "url", rawURL, "error", err)
+		} else {
+			m.mu.Lock()
+			j.Crawl = graph
+			m.mu.Unlock()
+		}
+		m.finish(ctx, j, err)
+	}()
+	return snapshot, nil
+}
+
+// visit adds a page reached by a crawl to the batch and analyzes it.
+func (m *Manager) visit(ctx context.Context, j *job, rawURL string, opts parser.Options) (*parser.AnalysisResult, error) {
+	m.mu.Lock()
+	i := len(j.Items)
+	j.Items = append(j.Items, Item{URL: rawURL, Status: StatusPending})
+	j.Total++
+	m.mu.Unlock()
+
+	m.analyzeItem(ctx, j, i, opts)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if item := j.Items[i]; item.Result == nil {
+		return nil, errors.New(item.Error)
+	}
+	return j.Items[i].Result, nil
+}
+
+// start registers a new running batch, unless too many batches are running already.
+// The batch's context keeps only the logger of the submitting request's context.
+func (m *Manager) start(reqCtx context.Context, urls []string, sitemapURL, crawlURL string) (context.Context, *job, Job, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, nil, Job{}, err
+	}
+	ctx, cancel := context.WithCancel(logging.WithLogger(context.Background(), logging.FromContext(reqCtx)))
+	j := &job{
+		Job: Job{
+			ID:         hex.EncodeToString(id),
+			Status:     StatusRunning,
+			CreatedAt:  m.now(),
+			SitemapURL: sitemapURL,
+			CrawlURL:   crawlURL,
+		},
+		cancel: cancel,
+	}
+	j.setItems(urls)
+
+	m.mu.Lock()
+	m.evict()
+	if m.running >= m.cfg.MaxRunning {
+		m.mu.Unlock()
+		cancel()
+		return nil, nil, Job{}, ErrTooManyJobs
+	}
+	m.running++
+	m.jobs[j.ID] = j
+	snapshot := j.snapshot()
+	m.mu.Unlock()
+
+	metrics.BatchJobsRunning.Inc()
+	return ctx, j, snapshot, nil
+}
+
+// seed audits the sitemaps of a batch and makes the URLs they list its items.
+func (m *Manager) seed(ctx context.Context, j *job, charge func(n int) error) error {
+	report, err := m.Audit(ctx, j.SitemapURL, sitemap.Config{MaxURLs: m.cfg.MaxURLs})
+	if err != nil {
+		return err
+	}
+	urls := report.Analyzable()
+	if charge != nil {
+		if err := charge(len(urls) - 1); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j.Sitemap = report
+	j.setItems(urls)
+	return nil
+}
+
+// setItems makes the URLs the pending items of the batch. m.mu must be held once
+// the batch is registered.
+func (j *job) setItems(urls []string) {
+	j.Total = len(urls)
+	j.Items = make([]Item, len(urls))
+	for i, u := range urls {
+		j.Items[i] = Item{URL: u, Status: StatusPending}
+	}
+}
+
+// Get returns the current state of a batch.
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.evict()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return j.snapshot(), nil
+}
+
+// Cancel stops a running batch. Items already being analyzed finish; pending ones are canceled.
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	j.cancel()
+	return m.Get(id)
+}
+
+// run analyzes the items of a batch with at most Parallelism analyses at once.
+func (m *Manager) run(ctx context.Context, j *job, opts parser.Options) {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(m.cfg.Parallelism, len(j.Items)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				m.analyzeItem(ctx, j, i, opts)
+			}
+		}()
+	}
+	for i := range j.Items {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	// Pages linked from the analyzed pages but not listed complete the audit. The
+	// workers are done, so the items can be read without the lock; the report is
+	// replaced rather than changed, as snapshots share it.
+	if j.Sitemap != nil {
+		var pages []*parser.AnalysisResult
+		for _, item := range j.Items {
+			if item.Result != nil {
+				pages = append(pages, item.Result)
+			}
+		}
+		report := *j.Sitemap
+		report.Issues = append(slices.Clip(report.Issues), j.Sitemap.Unlisted(pages)...)
+		m.mu.Lock()
+		j.Sitemap = &report
+		m.mu.Unlock()
+	}
+	m.finish(ctx, j, nil)
+}
+
+// finish marks a batch as done, canceled, or failed with err.
+func (m *Manager) finish(ctx context.Context, j *job, err error) {
+	m.mu.Lock()
+	finished := m.now()
+	j.FinishedAt = &finished
+	switch {
+	case ctx.Err() != nil:
+		j.Status = StatusCanceled
+	case err != nil:
+		j.Status = StatusFailed
+		j.Error = err.Error()
+	default:
+		j.Status = StatusDone
+	}
+	m.running--
+	m.mu.Unlock()
+	j.cancel() // Release the context's resources
+
+	metrics.BatchJobsRunning.Dec()
+	logging.FromContext(ctx).Info("Batch finished", "batch_id", j.ID, "status", j.Status, "total", j.Total, "failed", j.Failed)
+}
+
+// analyzeItem analyzes one URL and records the outcome. A panicking analysis only
+// fails its own item.
+func (m *Manager) analyzeItem(ctx context.Context, j *job, i int, opts parser.Options) {
+	if ctx.Err() != nil {
+		m.finishItem(j, i, Item{Status: StatusCanceled, Error: "batch canceled"})
+		return
+	}
+	m.mu.Lock()
+	j.Items[i].Status = StatusRunning
+	rawURL := j.Items[i].URL
+	m.mu.Unlock()
+
+	start := m.now()
+	outcome := Item{Status: StatusDone}
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				logging.FromContext(ctx).Error("Batch analysis panicked", "batch_id", j.ID, "url", rawURL, "panic", p)
+				outcome = Item{Status: StatusFailed, Error: fmt.Sprintf("internal error: %v", p)}
+			}
+		}()
+		itemCtx, cancel := context.WithTimeout(ctx, itemTimeout)
+		defer cancel()
+		result, err := m.Analyze(itemCtx, rawURL, opts)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			outcome = Item{Status: StatusCanceled, Error: "batch canceled"}
+			return
+		case err != nil:
+			outcome = Item{Status: StatusFailed, Error: err.Error()}
+			return
+		}
+		outcome.Result = result
+	}()
+	outcome.DurationMS = m.now().Sub(start).Milliseconds()
+	m.finishItem(j, i, outcome)
+}
+
+// finishItem stores the outcome of an item and updates the batch counters.
+func (m *Manager) finishItem(j *job, i int, outcome Item) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	outcome.URL = j.Items[i].URL
+	j.Items[i] = outcome
+	j.Completed++
+
+	result := "ok"
+	switch outcome.Status {
+	case StatusFailed:
+		j.Failed++
+		result = "error"
+	case StatusCanceled:
+		result = "canceled"
+	}
+	metrics.BatchItems.WithLabelValues(result).Inc()
+}
+
+// evict forgets finished batches older than the retention period. m.mu must be held.
+func (m *Manager) evict() {
+	cutoff := m.now().Add(-m.cfg.Retention)
+	for id, j := range m.jobs {
+		if j.FinishedAt != nil && j.FinishedAt.Before(cutoff) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// snapshot copies the batch so it can be read without holding the lock. m.mu must be held.
+func (j *job) snapshot() Job {
+	s := j.Job
+	s.Items = append([]Item(nil), j.Items...)
+	return s
+}
+
+// ParseURLs extracts URLs from a pasted or uploaded list with one URL per line.
+// Blank lines, "#" comments and duplicates are skipped.
+func ParseURLs(text string) []string {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return collect(lines)
+}
+
+// ParseCSV extracts URLs from the first column of a CSV file, skipping a "url" header
+// like ParseURLs skips blank lines, comments and duplicates.
+func ParseCSV(text string) ([]string, error) {
+	r := csv.NewReader(strings.NewReader(text))
+	r.FieldsPerRecord = -1 // Rows may carry any number of extra columns
+	r.Comment = '#'
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	var cells []string
+	for i, rec := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(rec[0]), "url") {
+			continue
+		}
+		cells = append(cells, rec[0])
+	}
+	return collect(cells), nil
+}
+
+// collect trims the candidate URLs and drops blanks, comments and duplicates.
+func collect(candidates []string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		c = strings.TrimSpace(c)
+		if c == "" || strings.HasPrefix(c, "#") || seen[c] {
+			continue
+		}
+		seen[c] = true
+		urls = append(urls, c)
+	}
+	return urls
+}
@@ -0,0 +1,283 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"lucytech/crawl"
+	"lucytech/logging"
+	"lucytech/parser"
+	"lucytech/sitemap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor polls the batch until it is no longer running
+func waitFor(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		j, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("Get returned error: %v", err)
+		}
+		if j.Status != StatusRunning {
+			return j
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("batch did not finish")
+	return Job{}
+}
+
+// TestManager_IsolatesFailures checks that failing and panicking URLs do not abort the batch
+func TestManager_IsolatesFailures(t *testing.T) {
+	m := NewManager(Config{Parallelism: 2})
+	m.Analyze = func(ctx context.Context, rawURL string, opts parser.Options) (*parser.AnalysisResult, error) {
+		switch {
+		case strings.Contains(rawURL, "down"):
+			return nil, errors.New("connection refused")
+		case strings.Contains(rawURL, "panic"):
+			panic("nil map")
+		}
+		return &parser.AnalysisResult{URL: rawURL, Title: "ok"}, nil
+	}
+
+	job, err := m.Submit(t.Context(), []string{"https://a.example", "https://down.example", "https://panic.example", "https://b.example"}, parser.Options{})
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	j := waitFor(t, m, job.ID)
+
+	if j.Status != StatusDone || j.Completed != 4 || j.Failed != 2 {
+		t.Errorf("batch = %s, %d completed, %d failed; want done, 4, 2", j.Status, j.Completed, j.Failed)
+	}
+	want := []string{StatusDone, StatusFailed, StatusFailed, StatusDone}
+	for i, item := range j.Items {
+		if item.Status != want[i] {
+			t.Errorf("Items[%d] = %s (%s); want %s", i, item.Status, item.Error, want[i])
+		}
+	}
+	if j.Items[0].Result == nil || j.Items[0].Result.Title != "ok" {
+		t.Errorf("Items[0].Result = %+v; want the analysis result", j.Items[0].Result)
+	}
+}
+
+// TestManager_RequestLogger checks that a batch outlives its request but logs with the request's logger
+func TestManager_RequestLogger(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler).With("request_id", "abc123")
+	release := make(chan struct{})
+	m := NewManager(Config{})
+	m.Analyze = func(ctx context.Context, rawURL string, opts parser.Options) (*parser.AnalysisResult, error) {
+		<-release
+		if ctx.Err() != nil || logging.FromContext(ctx) != logger {
+			return nil, errors.New("analysis lost the request logger or was canceled")
+		}
+		return &parser.AnalysisResult{}, nil
+	}
+
+	reqCtx, cancel := context.WithCancel(logging.WithLogger(t.Context(), logger))
+	job, err := m.Submit(reqCtx, []string{"https://a.example"}, parser.Options{})
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	cancel() // The request ends before the batch does
+	close(release)
+	if j := waitFor(t, m, job.ID); j.Status != StatusDone || j.Failed != 0 {
+		t.Errorf("batch = %s, items %+v; want done with the request logger", j.Status, j.Items)
+	}
+}
+
+// TestManager_BoundedParallelism checks that no more than Parallelism analyses run at once
+func TestManager_BoundedParallelism(t *testing.T) {
+	var current, peak atomic.Int32
+	m := NewManager(Config{Parallelism: 3})
+	m.Analyze = func(ctx context.Context, rawURL string, opts parser.Options) (*parser.AnalysisResult, error) {
+		n := current.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		current.Add(-1)
+		return &parser.AnalysisResult{}, nil
+	}
+
+	urls := make([]string, 20)
+	for i := range urls {
+		urls[i] = "https://example.com/" + string(rune('a'+i))
+	}
+	job, _ := m.Submit(t.Context(), urls, parser.Options{})
+	waitFor(t, m, job.ID)
+	if got := peak.Load(); got != 3 {
+		t.Errorf("peak parallelism = %d; want 3", got)
+	}
+}
+
+// TestManager_Cancel checks that canceling stops pending items
+func TestManager_Cancel(t *testing.T) {
+	release := make(chan struct{})
+	var once sync.Once
+	m := NewManager(Config{Parallelism: 1})
+	m.Analyze = func(ctx context.Context, rawURL string, opts parser.Options) (*parser.AnalysisResult, error) {
+		<-release
+		return &parser.AnalysisResult{}, nil
+	}
+	defer once.Do(func() { close(release) })
+
+	job, _ := m.Submit(t.Context(), []string{"https://a.example", "https://b.example", "https://c.example"}, parser.Options{})
+	if _, err := m.Cancel(job.ID); err != nil {
+		t.Fatalf("Cancel returned error: %v", err)
+	}
+	once.Do(func() { close(release) })
+	j := waitFor(t, m, job.ID)
+
+	if j.Status != StatusCanceled || j.Items[2].Status != StatusCanceled {
+		t.Errorf("batch = %s, last item %s; want both canceled", j.Status, j.Items[2].Status)
+	}
+	if _, err := m.Cancel("unknown"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Cancel(unknown) = %v; want ErrJobNotFound", err)
+	}
+}
+
+// TestManager_Limits checks the URL and running batch limits and eviction of old batches
+func TestManager_Limits(t *testing.T) {
+	release := make(chan struct{})
+	m := NewManager(Config{MaxURLs: 2, MaxRunning: 1, Retention: time.Minute})
+	m.Analyze = func(ctx context.Context, rawURL string, opts parser.Options) (*parser.AnalysisResult, error) {
+		<-release
+		return &parser.AnalysisResult{}, nil
+	}
+
+	if _, err := m.Submit(t.Context(), nil, parser.Options{}); !errors.Is(err, ErrNoURLs) {
+		t.Errorf("empty batch: err = %v; want ErrNoURLs", err)
+	}
+	if _, err := m.Submit(t.Context(), []string{"a", "b", "c"}, parser.Options{}); !errors.Is(err, ErrTooManyURLs) {
+		t.Errorf("3 URLs: err = %v; want ErrTooManyURLs", err)
+	}
+	first, err := m.Submit(t.Context(), []string{"https://a.example"}, parser.Options{})
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	if _, err := m.Submit(t.Context(), []string{"https://b.example"}, parser.Options{}); !errors.Is(err, ErrTooManyJobs) {
+		t.Errorf("second batch: err = %v; want ErrTooManyJobs", err)
+	}
+	close(release)
+	waitFor(t, m, first.ID)
+
+	// Finished batches are forgotten after the retention period
+	m.mu.Lock()
+	m.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	m.mu.Unlock()
+	if _, err := m.Get(first.ID); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expired batch: err = %v; want ErrJobNotFound", err)
+	}
+}
+
+// TestManager_SubmitSitemap checks that sitemap batches analyze the listed URLs, report
+// unlisted pages and fail when the sitemap is missing or the charge is refused
+func TestManager_SubmitSitemap(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<urlset><url><loc>%[1]s/</loc></url><url><loc>%[1]s/about</loc></url></urlset>`, srv.URL)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	m := NewManager(Config{})
+	m.Analyze = func(ctx context.Context, rawURL string, opts parser.Options) (*parser.AnalysisResult, error) {
+		return &parser.AnalysisResult{URL: rawURL, Links: []parser.LinkResult{{URL: srv.URL + "/pricing", Internal: true}}}, nil
+	}
+	var charged int
+	job, err := m.SubmitSitemap(t.Context(), srv.URL, parser.Options{}, func(n int) error {
+		charged = n
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SubmitSitemap returned error: %v", err)
+	}
+	j := waitFor(t, m, job.ID)
+	if j.Status != StatusDone || j.Total != 2 || charged != 1 || j.Sitemap == nil {
+		t.Fatalf("batch = %s with %d URLs, %d charged, sitemap %v; want done with 2 URLs, 1 charged beyond the submission", j.Status, j.Total, charged, j.Sitemap)
+	}
+	if issues := j.Sitemap.Issues; len(issues) != 1 || issues[0].Type != sitemap.IssueUnlisted || issues[0].URL != srv.URL+"/pricing" {
+		t.Errorf("issues = %+v; want /pricing reported as not in the sitemap", issues)
+	}
+
+	job, _ = m.SubmitSitemap(t.Context(), srv.URL, parser.Options{}, func(int) error { return errors.New("daily quota exceeded") })
+	if j := waitFor(t, m, job.ID); j.Status != StatusFailed || j.Error != "daily quota exceeded" || j.Total != 0 {
+		t.Errorf("refused charge: batch = %s (%s) with %d URLs; want failed without URLs", j.Status, j.Error, j.Total)
+	}
+	job, _ = m.SubmitSitemap(t.Context(), srv.URL+"/missing.xml", parser.Options{}, nil)
+	if j := waitFor(t, m, job.ID); j.Status != StatusFailed || !strings.Contains(j.Error, "no readable sitemap") {
+		t.Errorf("missing sitemap: batch = %s (%s); want failed", j.Status, j.Error)
+	}
+}
+
+// TestManager_SubmitCrawl checks that crawled pages become items and the graph is kept
+func TestManager_SubmitCrawl(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	page := func(links string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, "<html><body>%s</body></html>", links)
+		}
+	}
+	mux.HandleFunc("/{$}", page(`<a href="/a">a</a><a href="/b">b</a>`))
+	mux.HandleFunc("/a", page(`<a href="/b">b</a>`))
+	mux.HandleFunc("/b", page(""))
+
+	m := NewManager(Config{})
+	var charges atomic.Int64
+	job, err := m.SubmitCrawl(t.Context(), srv.URL, crawl.Config{}, parser.Options{}, func(n int) error {
+		charges.Add(int64(n))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SubmitCrawl returned error: %v", err)
+	}
+	j := waitFor(t, m, job.ID)
+	if j.Status != StatusDone || j.Total != 3 || j.Completed != 3 || charges.Load() != 2 || j.Crawl == nil {
+		t.Fatalf("batch = %s with %d/%d pages, %d charged, graph %v; want done with 3 pages, 2 charged beyond the submission", j.Status, j.Completed, j.Total, charges.Load(), j.Crawl)
+	}
+	if b := j.Crawl.Pages[2]; b.URL != srv.URL+"/b" || len(b.Inbound) != 2 {
+		t.Errorf("page = %+v; want /b with links from / and /a", b)
+	}
+
+	job, _ = m.SubmitCrawl(t.Context(), srv.URL, crawl.Config{}, parser.Options{}, func(int) error { return errors.New("daily quota exceeded") })
+	j = waitFor(t, m, job.ID)
+	if j.Status != StatusDone || j.Total != 1 || j.Crawl == nil || j.Crawl.Stopped != "daily quota exceeded" {
+		t.Errorf("refused charge: batch = %s with %d pages, graph %+v; want the start page and the crawl stopped", j.Status, j.Total, j.Crawl)
+	}
+	job, _ = m.SubmitCrawl(t.Context(), "http://", crawl.Config{}, parser.Options{}, nil)
+	if j := waitFor(t, m, job.ID); j.Status != StatusFailed || j.Error == "" {
+		t.Errorf("invalid URL: batch = %s (%s); want failed", j.Status, j.Error)
+	}
+}
+
+// TestParseURLs checks parsing of pasted lists and CSV files
+func TestParseURLs(t *testing.T) {
+	got := ParseURLs("https://a.example\n\n  # landing pages\nhttps://b.example/?tags=x,y  \nhttps://a.example\n")
+	if strings.Join(got, " ") != "https://a.example https://b.example/?tags=x,y" {
+		t.Errorf("ParseURLs = %q", got)
+	}
+
+	got, err := ParseCSV("url,owner\nhttps://a.example,marketing\n\"https://b.example/?q=a,b\",sales\n")
+	if err != nil || strings.Join(got, " ") != "https://a.example https://b.example/?q=a,b" {
+		t.Errorf("ParseCSV = %q, %v", got, err)
+	}
+	if _, err := ParseCSV("\"unterminated\n"); err == nil {
+		t.Error("ParseCSV accepted malformed CSV")
+	}
+}
@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache is a concurrency-safe key/value store whose entries go stale after a TTL.
+// Stale entries are kept so callers can revalidate them instead of starting over.
+// When a maximum size is set, the least recently used entries are evicted first.
+type Cache[K comparable, V any] struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int                 // Upper bound on stored entries; 0 means unbounded
+	entries    map[K]*list.Element // Values are *entry[K, V]
+	order      *list.List          // Most recently used entries at the front
+	now        func() time.Time    // Clock, replaceable in tests
+}
+
+// entry is a cached value with its key and expiry time.
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// Entry is a snapshot of a cached value, as returned by Entries.
+type Entry[K comparable, V any] struct {
+	Key     K
+	Value   V
+	Expires time.Time
+	Fresh   bool
+}
+
+// New creates a cache whose entries stay fresh for ttl and which holds at most
+// maxEntries entries (0 for no limit). A ttl of zero disables caching.
+func New[K comparable, V any](ttl time.Duration, maxEntries int) *Cache[K, V] {
+	return &Cache[K, V]{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[K]*list.Element),
+		order:      list.New(),
+		now:        time.Now,
+	}
+}
+
+// Enabled reports whether the cache stores anything at all.
+func (c *Cache[K, V]) Enabled() bool {
+	return c != nil && c.ttl > 0
+}
+
+// Get returns the value stored for key, whether it is still fresh, and whether it was found.
+func (c *Cache[K, V]) Get(key K) (value V, fresh bool, ok bool) {
+	if !c.Enabled() {
+		return value, false, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return value, false, false
+	}
+	c.order.MoveToFront(el)
+	e := el.Value.(*entry[K, V])
+	return e.value, c.now().Before(e.expires), true
+}
+
+// Set stores value for key with the cache's default TTL.
+func (c *Cache[K, V]) Set(key K, value V) {
+	if !c.Enabled() {
+		return
+	}
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL stores value for key with a custom TTL, evicting the least recently
+// used entry if the cache is full.
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	if !c.Enabled() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := &entry[K, V]{key: key, value: value, expires: c.now().Add(ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(e)
+
+	if c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry[K, V]).key)
+	}
+}
+
+// Delete removes key from the cache and reports whether it was present.
+func (c *Cache[K, V]) Delete(key K) bool {
+	if !c.Enabled() {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+	return ok
+}
+
+// Purge removes every entry and returns how many were removed.
+func (c *Cache[K, V]) Purge() int {
+	if !c.Enabled() {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := len(c.entries)
+	c.entries = make(map[K]*list.Element)
+	c.order.Init()
+	return n
+}
+
+// Entries returns a snapshot of all entries, most recently used first.
+func (c *Cache[K, V]) Entries() []Entry[K, V] {
+	if !c.Enabled() {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	entries := make([]Entry[K, V], 0, len(c.entries))
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*entry[K, V])
+		entries = append(entries, Entry[K, V]{Key: e.key, Value: e.value, Expires: e.expires, Fresh: now.Before(e.expires)})
+	}
+	return entries
+}
+
+// Len returns the number of stored entries, fresh or stale.
+func (c *Cache[K, V]) Len() int {
+	if !c.Enabled() {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// MaxEntries returns the configured size limit, 0 meaning unbounded.
+func (c *Cache[K, V]) MaxEntries() int {
+	if c == nil {
+		return 0
+	}
+	return c.maxEntries
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCache checks freshness, staleness and the disabled state
+func TestCache(t *testing.T) {
+	now := time.Now()
+	c := New[string, int](time.Minute, 0)
+	c.now = func() time.Time { return now }
+
+	if _, _, ok := c.Get("a"); ok {
+		t.Fatal("expected miss on empty cache")
+	}
+
+	c.Set("a", 1)
+	if v, fresh, ok := c.Get("a"); !ok || !fresh || v != 1 {
+		t.Errorf("Get(a) = %d, %v, %v; want 1, true, true", v, fresh, ok)
+	}
+
+	// After the TTL the entry is stale but still available for revalidation
+	now = now.Add(2 * time.Minute)
+	if v, fresh, ok := c.Get("a"); !ok || fresh || v != 1 {
+		t.Errorf("Get(a) after TTL = %d, %v, %v; want 1, false, true", v, fresh, ok)
+	}
+
+	c.Delete("a")
+	if _, _, ok := c.Get("a"); ok {
+		t.Error("expected miss after Delete")
+	}
+
+	// A zero TTL disables the cache entirely
+	disabled := New[string, int](0, 0)
+	disabled.Set("a", 1)
+	if _, _, ok := disabled.Get("a"); ok || disabled.Len() != 0 {
+		t.Error("expected disabled cache to store nothing")
+	}
+}
+
+// TestCache_LRU checks that the least recently used entry is evicted when the cache is full
+func TestCache_LRU(t *testing.T) {
+	c := New[string, int](time.Minute, 2)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a") // a is now more recently used than b
+	c.Set("c", 3)
+
+	if _, _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, _, ok := c.Get(key); !ok {
+			t.Errorf("expected %s to be cached", key)
+		}
+	}
+
+	// Entries lists the most recently used entry first
+	entries := c.Entries()
+	if len(entries) != 2 || entries[0].Key != "c" {
+		t.Errorf("Entries() = %+v; want c first", entries)
+	}
+
+	if n := c.Purge(); n != 2 || c.Len() != 0 {
+		t.Errorf("Purge() = %d, Len() = %d; want 2, 0", n, c.Len())
+	}
+}
+
+// TestCache_SetWithTTL checks that per-entry TTLs override the default
+func TestCache_SetWithTTL(t *testing.T) {
+	now := time.Now()
+	c := New[string, bool](time.Hour, 0)
+	c.now = func() time.Time { return now }
+
+	c.Set("ok", true)
+	c.SetWithTTL("failed", false, time.Minute)
+
+	now = now.Add(2 * time.Minute)
+	if _, fresh, _ := c.Get("ok"); !fresh {
+		t.Error("expected entry with default TTL to be fresh")
+	}
+	if _, fresh, _ := c.Get("failed"); fresh {
+		t.Error("expected entry with short TTL to be stale")
+	}
+}
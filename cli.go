@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"lucytech/parser"
+	"lucytech/report"
+	"os"
+	"time"
+)
+
+// Exit codes of the analyze command, so CI jobs can tell findings from breakage.
+const (
+	exitOK       = 0 // No findings at or above the -fail-on level
+	exitFindings = 1 // Findings at or above the -fail-on level
+	exitUsage    = 2 // Bad flags, unreadable config or unwritable output
+)
+
+// cliFormats lists the output formats of the analyze command.
+var cliFormats = map[string]func(io.Writer, []report.Page) error{
+	"text":             report.WriteText,
+	report.FormatJSON:  writePagesJSON,
+	report.FormatJUnit: report.WriteJUnit,
+	report.FormatSARIF: report.WriteSARIF,
+}
+
+// runCLI implements "lucytech analyze [flags] URL...": it analyzes each URL once,
+// writes a report and returns the process exit code.
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: lucytech analyze [flags] URL...")
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "text", "output format: text, json, junit or sarif")
+	output := fs.String("o", "", "write the report to this file instead of stdout")
+	failOn := fs.String("fail-on", report.LevelError, "exit with status 1 on findings of this level or above: error, warning, note or never")
+	assertionsPath := fs.String("assertions", "", "path to a JSON file with CSS selector assertions")
+	render := fs.Bool("render", false, "analyze the DOM rendered by a headless browser")
+	waitSelector := fs.String("wait-selector", "", "in render mode, wait for this selector instead of network idle")
+	chromePath := fs.String("chrome", "", "path to the Chromium binary used for rendering (default: search PATH)")
+	proxy := fs.String("proxy", "", "http, https or socks5 proxy URL for page fetches and link checks (default: HTTP_PROXY/HTTPS_PROXY)")
+	noProxy := fs.String("no-proxy", "", "comma-separated hosts, domains and CIDR ranges reached without the proxy (default: NO_PROXY)")
+	caFile := fs.String("ca-file", "", "PEM bundle of CA certificates to trust in addition to the system's")
+	clientCert := fs.String("client-cert", "", "PEM client certificate for mutual TLS")
+	clientKey := fs.String("client-key", "", "PEM private key of the client certificate")
+	insecure := fs.Bool("insecure-skip-verify", false, "do not verify TLS certificates; every result is flagged as insecure")
+	sortQuery := fs.Bool("sort-query", false, "sort query parameters by name when comparing and checking links")
+	stripTracking := fs.Bool("strip-tracking", false, "drop tracking query parameters such as utm_source, gclid and fbclid when comparing and checking links")
+	localRoot := fs.String("local-root", "", "directory that file:// URLs may be read from, e.g. a build output directory")
+	sameSite := fs.String("same-site", parser.SiteHost, "links counted as internal: host, subdomains or domain (the registrable domain)")
+	sameSiteHosts := fs.String("same-site-hosts", "", "comma-separated further hosts counted as internal, e.g. cdn.example.net or *.example.org")
+	timeout := fs.Duration("timeout", 2*time.Minute, "maximum time to analyze each page")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	write, ok := cliFormats[*format]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown format %q\n", *format)
+		return exitUsage
+	}
+	rules, err := parser.ParseSameSite(*sameSite, *sameSiteHosts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	switch *failOn {
+	case report.LevelError, report.LevelWarning, report.LevelNote, "never":
+	default:
+		fmt.Fprintf(stderr, "unknown -fail-on level %q\n", *failOn)
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	// Keep stdout for the report; only problems are logged
+	slog.SetDefault(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	opts := parser.Options{Render: *render, WaitSelector: *waitSelector}
+	if *assertionsPath != "" {
+		loaded, err := parser.LoadAssertions(*assertionsPath)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		opts.Assertions = loaded
+	}
+	parser.Renderer = &parser.ChromeFetcher{ExecPath: *chromePath}
+	parser.LocalRoot = *localRoot
+	parser.SameSiteRules = rules
+	parser.URLNormalizer = parser.Normalizer{SortQuery: *sortQuery, StripTracking: *stripTracking}
+	if err := parser.ConfigureTransport(parser.TransportConfig{
+		Proxy:              *proxy,
+		NoProxy:            *noProxy,
+		CAFile:             *caFile,
+		ClientCert:         *clientCert,
+		ClientKey:          *clientKey,
+		InsecureSkipVerify: *insecure,
+	}); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	pages := make([]report.Page, 0, fs.NArg())
+	for _, url := range fs.Args() {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		result, err := parser.AnalyzePage(ctx, url, opts)
+		cancel()
+		page := report.Page{URL: url, Result: result}
+		if err != nil {
+			page.Error = err.Error()
+		}
+		pages = append(pages, page)
+	}
+
+	var out bytes.Buffer
+	if err := write(&out, pages); err != nil {
+		fmt.Fprintln(stderr, "unable to write report:", err)
+		return exitUsage
+	}
+	if *output != "" {
+		if err := os.WriteFile(*output, out.Bytes(), 0o644); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+	} else if _, err := out.WriteTo(stdout); err != nil {
+		return exitUsage
+	}
+
+	if *failOn != "never" {
+		for _, f := range report.Findings(pages) {
+			if report.AtLeast(f.Level, *failOn) {
+				return exitFindings
+			}
+		}
+	}
+	return exitOK
+}
+
+// writePagesJSON writes the analyzed pages as an indented JSON array.
+func writePagesJSON(w io.Writer, pages []report.Page) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(pages)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRunCLI checks the report formats and exit codes of the analyze command
+func TestRunCLI(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<!DOCTYPE html><title>Home</title><h1>Home</h1><a href="/about">About</a>`)
+		case "/broken":
+			fmt.Fprint(w, `<!DOCTYPE html><title>Broken</title><h1>Broken</h1><a href="/missing">Missing</a>`)
+		case "/about":
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	// A clean page passes
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{ts.URL + "/"}, &stdout, &stderr); code != exitOK {
+		t.Errorf("clean page: exit %d; want %d\n%s%s", code, exitOK, stdout.String(), stderr.String())
+	}
+	if !strings.HasPrefix(stdout.String(), "PASS "+ts.URL+"/") {
+		t.Errorf("unexpected text output: %s", stdout.String())
+	}
+
+	// A broken internal link fails the run, and the JUnit report is written to a file
+	out := filepath.Join(t.TempDir(), "report.xml")
+	stdout.Reset()
+	if code := runCLI([]string{"-format", "junit", "-o", out, ts.URL + "/", ts.URL + "/broken"}, &stdout, &stderr); code != exitFindings {
+		t.Errorf("broken link: exit %d; want %d", code, exitFindings)
+	}
+	raw, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+	var suites struct {
+		Tests    int `xml:"tests,attr"`
+		Failures int `xml:"failures,attr"`
+	}
+	if err := xml.Unmarshal(raw, &suites); err != nil || suites.Tests != 4 || suites.Failures != 1 {
+		t.Errorf("JUnit totals = %+v, %v; want 4 tests, 1 failure\n%s", suites, err, raw)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("stdout not empty with -o: %s", stdout.String())
+	}
+
+	// -fail-on never only reports
+	if code := runCLI([]string{"-format", "sarif", "-fail-on", "never", ts.URL + "/broken"}, &stdout, &stderr); code != exitOK {
+		t.Errorf("-fail-on never: exit %d; want %d", code, exitOK)
+	}
+
+	// Usage errors
+	for _, args := range [][]string{{}, {"-format", "docx", ts.URL}, {"-fail-on", "fatal", ts.URL}, {"-same-site", "origin", ts.URL}} {
+		if code := runCLI(args, &stdout, &stderr); code != exitUsage {
+			t.Errorf("args %q: exit %d; want %d", args, code, exitUsage)
+		}
+	}
+}
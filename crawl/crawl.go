@@ -0,0 +1,494 @@
+// Package crawl follows the internal links of a site from its start page and builds
+// the internal link graph: the click depth of every page, its inbound and outbound
+// links, redirect chains and loops, broken internal links, pages with few inbound
+// links, and orphan pages that the sitemaps list but no crawled page links to.
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"lucytech/logging"
+	"lucytech/metrics"
+	"lucytech/parser"
+	"lucytech/sitemap"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRedirects is the longest redirect chain followed before it is reported as broken.
+const maxRedirects = 10
+
+// userAgent identifies the requests of the crawler.
+const userAgent = "lucytech-crawler"
+
+// httpClient requests pages hop by hop, so redirect chains can be recorded; replaceable in tests.
+var httpClient = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: parser.SharedTransport,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Config bounds a crawl. Zero values select the defaults.
+type Config struct {
+	MaxPages    int // Pages added to the graph, redirects included; default 500
+	MaxDepth    int // Clicks from the start page; default 10
+	Parallelism int // Pages requested at once; default 4
+	WeakInbound int // Pages with at most this many inbound links are reported; default 1
+}
+
+// Visit analyzes an HTML page reached by the crawl; the internal links of the result
+// are followed.
+type Visit func(ctx context.Context, rawURL string) (*parser.AnalysisResult, error)
+
+// Page is a node of the internal link graph.
+type Page struct {
+	URL        string   `json:"url"`
+	Depth      int      `json:"depth"`                 // Clicks from the start page; redirects do not count
+	StatusCode int      `json:"status_code,omitempty"` // Status of the URL itself, 0 if there was no response
+	RedirectTo string   `json:"redirect_to,omitempty"` // Where the redirect chain starting here ends
+	Title      string   `json:"title,omitempty"`
+	Error      string   `json:"error,omitempty"`   // Why the page could not be requested or analyzed
+	Blocked    bool     `json:"blocked,omitempty"` // Disallowed by robots.txt, so not requested
+	InSitemap  bool     `json:"in_sitemap"`
+	Inbound    []string `json:"inbound"`  // Pages linking or redirecting here
+	Outbound   []string `json:"outbound"` // Internal URLs linked from here, or the redirect target
+}
+
+// Page states, see Page.State.
+const (
+	StateOK         = "ok"          // The page loaded
+	StateRedirect   = "redirect"    // The URL redirects
+	StateBroken     = "broken"      // Error status, no response or a redirect loop
+	StateBlocked    = "blocked"     // Disallowed by robots.txt
+	StateNotCrawled = "not_crawled" // Found, but the crawl ended before requesting it
+)
+
+// State classifies the page for reports.
+func (p Page) State() string {
+	switch {
+	case p.Blocked:
+		return StateBlocked
+	case p.StatusCode >= 400, p.StatusCode == 0 && p.Error != "":
+		return StateBroken
+	case p.RedirectTo != "":
+		return StateRedirect
+	case p.StatusCode >= 300 && p.Error != "":
+		return StateBroken // The redirect chain loops or is too long
+	case p.StatusCode == 0:
+		return StateNotCrawled
+	default:
+		return StateOK
+	}
+}
+
+// Hop is one request of a redirect chain.
+type Hop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code,omitempty"` // 0 for an external target, which is not requested
+}
+
+// RedirectChain is the sequence of redirects starting at an internal URL.
+type RedirectChain struct {
+	From  string `json:"from"`
+	Hops  []Hop  `json:"hops"`            // Every URL requested, From and the final target included
+	Error string `json:"error,omitempty"` // Set for loops and chains that are too long
+}
+
+// BrokenLink is an internal link to a page that cannot be loaded.
+type BrokenLink struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	StatusCode int    `json:"status_code,omitempty"` // Final status of the target, after redirects
+	Error      string `json:"error,omitempty"`
+}
+
+// Graph is the outcome of a crawl.
+type Graph struct {
+	Start          string          `json:"start"`
+	Pages          []Page          `json:"pages"` // By click depth, in the order they were found
+	Redirects      []RedirectChain `json:"redirects"`
+	BrokenLinks    []BrokenLink    `json:"broken_links"`
+	Orphans        []string        `json:"orphans"`    // Listed in the sitemaps, but no crawled page links to them
+	WeakPages      []string        `json:"weak_pages"` // Pages with few inbound links
+	SitemapEntries int             `json:"sitemap_entries"`
+	SitemapError   string          `json:"sitemap_error,omitempty"` // Why the sitemaps could not be read
+	Truncated      bool            `json:"truncated"`               // Links were left unfollowed at MaxPages or MaxDepth
+	Stopped        string          `json:"stopped,omitempty"`       // Why the crawl ended early
+}
+
+// node is a page with the bookkeeping of the crawl.
+type node struct {
+	Page
+	inbound, outbound map[string]bool
+	finalStatus       int  // Status at the end of the redirect chain
+	html              bool // The page can be analyzed
+}
+
+// crawler holds the state of one crawl.
+type crawler struct {
+	cfg    Config
+	visit  Visit
+	host   string // Normalized host of the start page
+	robots *sitemap.Robots
+	nodes  map[string]*node
+	order  []*node // In discovery order
+	graph  *Graph
+}
+
+// Crawl crawls the site of rawURL breadth-first from rawURL, visiting every internal
+// HTML page it reaches within the limits, and returns the link graph. Pages disallowed
+// by robots.txt are recorded but not requested. If ctx ends, the graph built so far
+// is returned with the cause in Stopped.
+func Crawl(ctx context.Context, rawURL string, cfg Config, visit Visit) (*Graph, error) {
+	if cfg.MaxPages <= 0 {
+		cfg.MaxPages = 500
+	}
+	if cfg.MaxDepth <= 0 {
+		cfg.MaxDepth = 10
+	}
+	if cfg.Parallelism <= 0 {
+		cfg.Parallelism = 4
+	}
+	if cfg.WeakInbound <= 0 {
+		cfg.WeakInbound = 1
+	}
+
+	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+		rawURL = "https://" + rawURL
+	}
+	start, err := url.Parse(rawURL)
+	if err != nil || start.Host == "" {
+		return nil, fmt.Errorf("%w: %s", parser.ErrInvalidURL, rawURL)
+	}
+	origin := start.Scheme + "://" + start.Host
+
+	c := &crawler{
+		cfg:    cfg,
+		visit:  visit,
+		host:   parser.NormalizeURL(start).Host,
+		robots: sitemap.FetchRobots(ctx, origin+"/robots.txt"),
+		nodes:  make(map[string]*node),
+		graph:  &Graph{Start: parser.NormalizeURL(start).String()},
+	}
+	level := []*node{c.add(c.graph.Start, 0)}
+	for depth := 0; len(level) > 0; depth++ {
+		if ctx.Err() != nil {
+			c.graph.Stopped = context.Cause(ctx).Error()
+			break
+		}
+		level = c.crawlLevel(ctx, level, depth)
+	}
+	if c.graph.Stopped == "" && ctx.Err() != nil {
+		c.graph.Stopped = context.Cause(ctx).Error()
+	}
+
+	c.readSitemaps(ctx, origin)
+	c.build()
+	metrics.CrawledPages.Add(float64(len(c.graph.Pages)))
+	metrics.CrawlBrokenLinks.Add(float64(len(c.graph.BrokenLinks)))
+	logging.FromContext(ctx).Info("Crawl complete", "start", c.graph.Start, "pages", len(c.graph.Pages), "broken_links", len(c.graph.BrokenLinks), "orphans", len(c.graph.Orphans), "truncated", c.graph.Truncated)
+	return c.graph, nil
+}
+
+// add creates the node of a URL found at the given depth.
+func (c *crawler) add(u string, depth int) *node {
+	n := &node{Page: Page{URL: u, Depth: depth}, inbound: make(map[string]bool), outbound: make(map[string]bool)}
+	c.nodes[u] = n
+	c.order = append(c.order, n)
+	return n
+}
+
+// crawlLevel requests the pages of one click depth, follows their redirects, visits the
+// HTML pages and returns the pages they link to that are crawled next.
+func (c *crawler) crawlLevel(ctx context.Context, level []*node, depth int) []*node {
+	resolved := make([]resolution, len(level))
+	c.parallel(len(level), func(i int) { resolved[i] = resolve(ctx, level[i].URL, c.host) })
+	if ctx.Err() != nil {
+		return nil // Requests that failed because the crawl ended are not broken links
+	}
+
+	// Redirect targets belong to the same depth: following a redirect is not a click
+	var visit []*node
+	for i, n := range level {
+		r := resolved[i]
+		if len(r.hops) > 0 {
+			n.StatusCode = r.hops[0].StatusCode
+		}
+		n.finalStatus = r.finalStatus()
+		if r.err != nil {
+			n.Error = r.err.Error()
+		}
+		if len(r.hops) > 1 || (r.err != nil && len(r.hops) > 0) {
+			c.redirect(n, r, depth, &visit)
+			continue
+		}
+		if r.err == nil && r.html() {
+			n.html = true
+			visit = append(visit, n)
+		}
+	}
+
+	results := make([]*parser.AnalysisResult, len(visit))
+	errs := make([]error, len(visit))
+	c.parallel(len(visit), func(i int) { results[i], errs[i] = c.visit(ctx, visit[i].URL) })
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	var next []*node
+	for i, n := range visit {
+		if errs[i] != nil {
+			n.Error = errs[i].Error()
+			continue
+		}
+		n.Title = results[i].Title
+		for _, link := range results[i].Links {
+			if !link.Internal {
+				continue
+			}
+			u, err := url.Parse(link.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || parser.NormalizeURL(u).Host != c.host {
+				continue
+			}
+			if target := c.link(n, u, depth+1); target != nil {
+				next = append(next, target)
+			}
+		}
+	}
+	return next
+}
+
+// redirect records the redirect chain of a node and adds its internal target to the
+// graph at the same depth, to be visited with the current level.
+func (c *crawler) redirect(n *node, r resolution, depth int, visit *[]*node) {
+	chain := RedirectChain{From: n.URL, Hops: r.hops}
+	if r.err != nil {
+		chain.Error = r.err.Error()
+	}
+	c.graph.Redirects = append(c.graph.Redirects, chain)
+	if r.err != nil {
+		return
+	}
+
+	final := r.hops[len(r.hops)-1]
+	n.RedirectTo = final.URL
+	n.outbound[final.URL] = true
+	if r.external {
+		return
+	}
+	target, ok := c.nodes[final.URL]
+	if !ok {
+		if len(c.nodes) >= c.cfg.MaxPages {
+			c.graph.Truncated = true
+			return
+		}
+		target = c.add(final.URL, depth)
+		target.StatusCode, target.finalStatus = final.StatusCode, final.StatusCode
+		if r.html() {
+			target.html = true
+			*visit = append(*visit, target)
+		}
+	}
+	target.inbound[n.URL] = true
+}
+
+// link records an internal link and returns the target if it is new and is to be crawled.
+func (c *crawler) link(from *node, u *url.URL, depth int) *node {
+	to := parser.NormalizeURL(u).String()
+	if to == from.URL {
+		return nil
+	}
+	from.outbound[to] = true
+	if target, ok := c.nodes[to]; ok {
+		target.inbound[from.URL] = true
+		return nil
+	}
+	if depth > c.cfg.MaxDepth || len(c.nodes) >= c.cfg.MaxPages {
+		c.graph.Truncated = true
+		return nil
+	}
+	target := c.add(to, depth)
+	target.inbound[from.URL] = true
+	if !c.robots.Allowed(u) {
+		target.Blocked = true
+		return nil
+	}
+	return target
+}
+
+// parallel calls fn for 0..n-1 with at most Parallelism calls at once.
+func (c *crawler) parallel(n int, fn func(i int)) {
+	sem := make(chan struct{}, c.cfg.Parallelism)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
+
+// readSitemaps marks the pages listed in the site's sitemaps and finds the orphans:
+// listed pages that no crawled page links to. The start page is never an orphan.
+func (c *crawler) readSitemaps(ctx context.Context, origin string) {
+	c.graph.Orphans = []string{}
+	report, err := sitemap.Read(ctx, origin, sitemap.Config{MaxURLs: 50000})
+	if err != nil {
+		c.graph.SitemapError = err.Error()
+		return
+	}
+	c.graph.SitemapEntries = len(report.Entries)
+	for _, e := range report.Entries {
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			continue
+		}
+		key := parser.NormalizeURL(u).String()
+		n, ok := c.nodes[key]
+		if ok {
+			n.InSitemap = true
+		}
+		if key != c.graph.Start && (!ok || len(n.inbound) == 0) {
+			c.graph.Orphans = append(c.graph.Orphans, key)
+		}
+	}
+}
+
+// build fills in the pages, broken links and weakly linked pages of the graph.
+func (c *crawler) build() {
+	g := c.graph
+	g.Pages = make([]Page, 0, len(c.order))
+	g.BrokenLinks, g.WeakPages = []BrokenLink{}, []string{}
+	if g.Redirects == nil {
+		g.Redirects = []RedirectChain{}
+	}
+
+	nodes := slices.Clone(c.order)
+	slices.SortStableFunc(nodes, func(a, b *node) int { return a.Depth - b.Depth })
+	for _, n := range nodes {
+		n.Inbound = sortedKeys(n.inbound)
+		n.Outbound = sortedKeys(n.outbound)
+		g.Pages = append(g.Pages, n.Page)
+
+		if n.broken() {
+			for _, from := range n.Inbound {
+				g.BrokenLinks = append(g.BrokenLinks, BrokenLink{From: from, To: n.URL, StatusCode: n.finalStatus, Error: n.Error})
+			}
+		}
+		if n.URL != g.Start && n.html && n.Error == "" && len(n.Inbound) <= c.cfg.WeakInbound {
+			g.WeakPages = append(g.WeakPages, n.URL)
+		}
+	}
+	slices.SortFunc(g.BrokenLinks, func(a, b BrokenLink) int {
+		return strings.Compare(a.From+"\x00"+a.To, b.From+"\x00"+b.To)
+	})
+}
+
+// broken reports whether links to the page lead nowhere: the page, or the end of its
+// redirect chain, could not be loaded. Pages that were not requested are not broken.
+func (n *node) broken() bool {
+	if n.Blocked || (n.StatusCode == 0 && n.Error == "") {
+		return false
+	}
+	return (n.Error != "" && !n.html) || n.finalStatus >= 400
+}
+
+// resolution is the outcome of requesting a URL and following its redirects.
+type resolution struct {
+	hops        []Hop
+	contentType string // Of the final response
+	external    bool   // The chain ends on another host, which is not requested
+	err         error  // Transport error, loop or too many redirects
+}
+
+// finalStatus returns the status at the end of the chain.
+func (r resolution) finalStatus() int {
+	if len(r.hops) == 0 || r.err != nil {
+		return 0
+	}
+	return r.hops[len(r.hops)-1].StatusCode
+}
+
+// html reports whether the final response is a page that can be analyzed.
+func (r resolution) html() bool {
+	status := r.finalStatus()
+	ct := strings.ToLower(r.contentType)
+	return !r.external && status >= 200 && status < 300 && (ct == "" || strings.Contains(ct, "html"))
+}
+
+// errRedirectLoop is reported for redirect chains that return to a URL already requested.
+var errRedirectLoop = errors.New("redirect loop")
+
+// resolve requests a URL and follows its redirects one hop at a time. Each hop is a
+// HEAD request, or a GET request for servers that do not support HEAD.
+func resolve(ctx context.Context, u, host string) resolution {
+	var r resolution
+	seen := make(map[string]bool)
+	for range maxRedirects + 1 {
+		if seen[u] {
+			r.err = fmt.Errorf("%w back to %s", errRedirectLoop, u)
+			return r
+		}
+		seen[u] = true
+
+		resp, err := request(ctx, http.MethodHead, u)
+		if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+			resp.Body.Close()
+			resp, err = request(ctx, http.MethodGet, u)
+		}
+		if err != nil {
+			r.err = err
+			return r
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+		resp.Body.Close()
+		r.hops = append(r.hops, Hop{URL: u, StatusCode: resp.StatusCode})
+		r.contentType = resp.Header.Get("Content-Type")
+
+		loc, err := resp.Location()
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 || err != nil {
+			return r
+		}
+		next := parser.NormalizeURL(loc)
+		u = next.String()
+		if next.Host != host {
+			r.hops = append(r.hops, Hop{URL: u})
+			r.external = true
+			return r
+		}
+	}
+	r.err = fmt.Errorf("more than %d redirects", maxRedirects)
+	return r
+}
+
+// request sends a request identified as the crawler.
+func request(ctx context.Context, method, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	return httpClient.Do(req)
+}
+
+// sortedKeys returns the keys of a set in order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
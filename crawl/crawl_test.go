@@ -0,0 +1,137 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"lucytech/parser"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newSite serves a small site with redirects, a loop, a missing page, a PDF, a page
+// blocked by robots.txt and a sitemap listing an orphan
+func newSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	page := func(links ...string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintf(w, "<html><head><title>%s</title></head><body>", r.URL.Path)
+			for _, l := range links {
+				fmt.Fprintf(w, `<a href="%s">link</a>`, l)
+			}
+			fmt.Fprint(w, "</body></html>")
+		}
+	}
+	redirect := func(to string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, to, http.StatusMovedPermanently) }
+	}
+
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+	})
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<urlset><url><loc>%[1]s/</loc></url><url><loc>%[1]s/a</loc></url><url><loc>%[1]s/orphan</loc></url></urlset>`, srv.URL)
+	})
+	mux.HandleFunc("/{$}", page("/a", "/b#top", "/old", "/loop", "/missing", "/guide.pdf", "/private/x", "https://external.example/"))
+	mux.HandleFunc("/a", page("/", "/deep"))
+	mux.HandleFunc("/b", page("/a", "/b"))
+	mux.HandleFunc("/deep", page("/deeper"))
+	mux.HandleFunc("/deeper", page())
+	mux.HandleFunc("/old", redirect("/older"))
+	mux.HandleFunc("/older", redirect("/moved"))
+	mux.HandleFunc("/moved", page())
+	mux.HandleFunc("/loop", redirect("/loop2"))
+	mux.HandleFunc("/loop2", redirect("/loop"))
+	mux.HandleFunc("/guide.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+	})
+	mux.HandleFunc("/orphan", page())
+	return srv
+}
+
+// analyze visits pages with the real analyzer, without checking links
+func analyze(ctx context.Context, rawURL string) (*parser.AnalysisResult, error) {
+	return parser.AnalyzePage(ctx, rawURL, parser.Options{SkipLinkChecks: true})
+}
+
+// TestCrawl checks click depth, links, redirects, broken links, orphans and weak pages
+func TestCrawl(t *testing.T) {
+	srv := newSite(t)
+	g, err := Crawl(context.Background(), srv.URL, Config{MaxDepth: 2}, analyze)
+	if err != nil {
+		t.Fatalf("Crawl returned error: %v", err)
+	}
+	path := func(u string) string { return strings.TrimPrefix(u, srv.URL) }
+
+	pages := make(map[string]Page)
+	var order []string
+	for _, p := range g.Pages {
+		pages[path(p.URL)] = p
+		order = append(order, fmt.Sprintf("%s:%d", path(p.URL), p.Depth))
+	}
+	// /moved is reached through redirects from a depth 1 link; /deeper is beyond MaxDepth
+	want := "/:0 /a:1 /b:1 /old:1 /loop:1 /missing:1 /guide.pdf:1 /private/x:1 /moved:1 /deep:2"
+	if strings.Join(order, " ") != want {
+		t.Errorf("pages = %s; want %s", strings.Join(order, " "), want)
+	}
+	if !g.Truncated {
+		t.Error("Truncated = false; want true for the link beyond MaxDepth")
+	}
+
+	if a := pages["/a"]; a.Title != "/a" || !a.InSitemap || strings.Join(a.Inbound, " ") != srv.URL+"/ "+srv.URL+"/b" {
+		t.Errorf("/a = %+v; want its title, sitemap flag and inbound links from / and /b", a)
+	}
+	if b := pages["/b"]; len(b.Inbound) != 1 || len(b.Outbound) != 1 {
+		t.Errorf("/b = %+v; want the fragment link counted as / and the self-link ignored", b)
+	}
+	if p := pages["/private/x"]; !p.Blocked || p.StatusCode != 0 {
+		t.Errorf("/private/x = %+v; want blocked and not requested", p)
+	}
+	if p := pages["/old"]; p.StatusCode != http.StatusMovedPermanently || p.RedirectTo != srv.URL+"/moved" {
+		t.Errorf("/old = %+v; want a 301 ending at /moved", p)
+	}
+
+	if len(g.Redirects) != 2 || len(g.Redirects[0].Hops) != 3 || g.Redirects[1].Error == "" {
+		t.Errorf("redirects = %+v; want the 2-hop chain and the loop", g.Redirects)
+	}
+	var broken []string
+	for _, l := range g.BrokenLinks {
+		broken = append(broken, path(l.From)+" -> "+path(l.To))
+	}
+	if strings.Join(broken, ", ") != "/ -> /loop, / -> /missing" {
+		t.Errorf("broken links = %v; want the loop and the missing page", broken)
+	}
+	if len(g.Orphans) != 1 || path(g.Orphans[0]) != "/orphan" || g.SitemapEntries != 3 {
+		t.Errorf("orphans = %v of %d entries; want /orphan", g.Orphans, g.SitemapEntries)
+	}
+	var weak []string
+	for _, u := range g.WeakPages {
+		weak = append(weak, path(u))
+	}
+	if strings.Join(weak, " ") != "/b /moved /deep" {
+		t.Errorf("weak pages = %v; want the pages with one inbound link", weak)
+	}
+}
+
+// TestCrawl_Stopped checks that a crawl ended by its context returns what it found
+func TestCrawl_Stopped(t *testing.T) {
+	srv := newSite(t)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	g, err := Crawl(ctx, srv.URL, Config{}, func(ctx context.Context, rawURL string) (*parser.AnalysisResult, error) {
+		result, err := analyze(ctx, rawURL)
+		cancel(fmt.Errorf("daily quota exceeded"))
+		return result, err
+	})
+	if err != nil {
+		t.Fatalf("Crawl returned error: %v", err)
+	}
+	if g.Stopped != "daily quota exceeded" || len(g.Pages) != 1 || len(g.BrokenLinks) != 0 {
+		t.Errorf("graph = %d pages, %d broken links, stopped %q; want only the start page", len(g.Pages), len(g.BrokenLinks), g.Stopped)
+	}
+}
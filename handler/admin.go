@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"lucytech/auth"
+	"lucytech/logging"
+	"lucytech/parser"
+	"net/http"
+)
+
+// LinkCacheResponse is the JSON body returned by the link cache admin endpoint.
+type LinkCacheResponse struct {
+	parser.LinkCacheStats
+	Entries []parser.LinkCacheEntry `json:"entries,omitempty"` // Cached verdicts, most recently used first
+	Purged  int                     `json:"purged,omitempty"`  // Number of entries removed by a DELETE
+}
+
+// LinkCacheHandler lets operators inspect and purge the shared link verdict cache.
+// GET lists the cached verdicts; DELETE purges all of them, or only the one for
+// the link given in the "url" query parameter.
+func LinkCacheHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, LinkCacheResponse{
+			LinkCacheStats: parser.LinkCacheInfo(),
+			Entries:        parser.LinkCacheEntries(),
+		})
+	case http.MethodDelete:
+		link := r.URL.Query().Get("url")
+		purged := parser.PurgeLinkCache(link)
+		logging.FromContext(r.Context()).Info("Link cache purged", "url", link, "purged", purged)
+		writeJSON(w, http.StatusOK, LinkCacheResponse{LinkCacheStats: parser.LinkCacheInfo(), Purged: purged})
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{Error: "method not allowed"})
+	}
+}
+
+// LogFormatResponse is the JSON body returned by the log format admin endpoint.
+type LogFormatResponse struct {
+	Format string `json:"format"` // Current log output format, "text" or "json"
+}
+
+// LogFormatHandler reports the log output format on GET and switches it on PUT,
+// taking the new format from the "format" query parameter.
+func LogFormatHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, LogFormatResponse{Format: logging.Format()})
+	case http.MethodPut:
+		if err := logging.SetFormat(r.URL.Query().Get("format")); err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{Error: err.Error()})
+			return
+		}
+		logging.FromContext(r.Context()).Info("Log format changed", "format", logging.Format())
+		writeJSON(w, http.StatusOK, LogFormatResponse{Format: logging.Format()})
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{Error: "method not allowed"})
+	}
+}
+
+// APIKeyRequest is the JSON body accepted when creating an API key.
+type APIKeyRequest struct {
+	Name       string  `json:"name"`                  // Owner or purpose of the key
+	RateLimit  float64 `json:"rate_limit,omitempty"`  // Requests per minute; 0 uses the default
+	DailyQuota int     `json:"daily_quota,omitempty"` // Analyses per UTC day; 0 uses the default
+}
+
+// APIKeyInfo describes a stored API key without its secret hash.
+type APIKeyInfo struct {
+	auth.Key
+	UsageToday int    `json:"usage_today"`      // Analyses counted against today's quota
+	Secret     string `json:"secret,omitempty"` // The key to hand out; only returned on creation
+}
+
+// APIKeysHandler manages API keys: GET lists them, POST creates one from an
+// APIKeyRequest and returns its secret once, and DELETE revokes the key given
+// in the "id" query parameter.
+func APIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if apiKeys == nil {
+		writeJSON(w, http.StatusNotFound, APIError{Error: "API key authentication is disabled"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		keys := apiKeys.List()
+		infos := make([]APIKeyInfo, 0, len(keys))
+		for _, k := range keys {
+			infos = append(infos, keyInfo(k))
+		}
+		writeJSON(w, http.StatusOK, infos)
+	case http.MethodPost:
+		var req APIKeyRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{Error: "invalid JSON body: " + err.Error()})
+			return
+		}
+		if req.Name == "" {
+			writeJSON(w, http.StatusBadRequest, APIError{Error: "name is required"})
+			return
+		}
+		key, secret, err := apiKeys.Create(req.Name, req.RateLimit, req.DailyQuota)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{Error: err.Error()})
+			return
+		}
+		logging.FromContext(r.Context()).Info("API key created", "api_key", key.ID, "name", key.Name)
+		info := keyInfo(key)
+		info.Secret = secret
+		writeJSON(w, http.StatusCreated, info)
+	case http.MethodDelete:
+		key, err := apiKeys.Revoke(r.URL.Query().Get("id"))
+		if errors.Is(err, auth.ErrKeyNotFound) {
+			writeJSON(w, http.StatusNotFound, APIError{Error: err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, APIError{Error: err.Error()})
+			return
+		}
+		logging.FromContext(r.Context()).Info("API key revoked", "api_key", key.ID)
+		writeJSON(w, http.StatusOK, keyInfo(key))
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{Error: "method not allowed"})
+	}
+}
+
+// keyInfo strips the secret hash from a key and adds its usage.
+func keyInfo(k auth.Key) APIKeyInfo {
+	k.Hash = ""
+	return APIKeyInfo{Key: k, UsageToday: keyLimiter.Usage(k.ID)}
+}
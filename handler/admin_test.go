@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"encoding/json"
+	"lucytech/logging"
+	"lucytech/parser"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestLinkCacheHandler checks inspecting and purging the link cache
+func TestLinkCacheHandler(t *testing.T) {
+	parser.ConfigureCache(parser.CacheConfig{LinkTTL: time.Hour, LinkCacheSize: 100})
+	defer parser.ConfigureCache(parser.CacheConfig{})
+
+	// GET reports the cache configuration
+	w := httptest.NewRecorder()
+	LinkCacheHandler(w, httptest.NewRequest(http.MethodGet, "/admin/link-cache", nil))
+	var resp LinkCacheResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.Enabled || resp.MaxEntries != 100 {
+		t.Errorf("response = %+v; want enabled cache with 100 max entries", resp)
+	}
+
+	// DELETE purges the cache
+	w = httptest.NewRecorder()
+	LinkCacheHandler(w, httptest.NewRequest(http.MethodDelete, "/admin/link-cache", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	// Other methods are rejected
+	w = httptest.NewRecorder()
+	LinkCacheHandler(w, httptest.NewRequest(http.MethodPost, "/admin/link-cache", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", w.Code)
+	}
+}
+
+// TestLogFormatHandler checks reading and switching the log format
+func TestLogFormatHandler(t *testing.T) {
+	defer logging.SetFormat(logging.FormatText)
+
+	w := httptest.NewRecorder()
+	LogFormatHandler(w, httptest.NewRequest(http.MethodPut, "/admin/log-format?format=json", nil))
+	if w.Code != http.StatusOK || logging.Format() != logging.FormatJSON {
+		t.Errorf("PUT json: status %d, format %q; want 200, json", w.Code, logging.Format())
+	}
+
+	w = httptest.NewRecorder()
+	LogFormatHandler(w, httptest.NewRequest(http.MethodGet, "/admin/log-format", nil))
+	var resp LogFormatResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Format != logging.FormatJSON {
+		t.Errorf("GET = %+v, %v; want format json", resp, err)
+	}
+
+	// Unknown formats are rejected and leave the format unchanged
+	w = httptest.NewRecorder()
+	LogFormatHandler(w, httptest.NewRequest(http.MethodPut, "/admin/log-format?format=xml", nil))
+	if w.Code != http.StatusBadRequest || logging.Format() != logging.FormatJSON {
+		t.Errorf("PUT xml: status %d, format %q; want 400, json", w.Code, logging.Format())
+	}
+}
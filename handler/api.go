@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"lucytech/logging"
+	"lucytech/parser"
+	"lucytech/report"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// APIRequest is the JSON body accepted by the /api/analyze endpoint.
+type APIRequest struct {
+	URL          string             `json:"url"`                     // Page to analyze
+	Assertions   []parser.Assertion `json:"assertions,omitempty"`    // Extra assertions evaluated alongside the configured ones
+	Render       bool               `json:"render,omitempty"`        // Analyze the DOM rendered by a headless browser
+	WaitSelector string             `json:"wait_selector,omitempty"` // In render mode, wait for this selector instead of network idle
+	HTML         string             `json:"html,omitempty"`          // Analyze this HTML instead of fetching URL, which then only serves as the optional base
+	CheckLinks   bool               `json:"check_links,omitempty"`   // With html, also check links resolved against URL
+	WARC         string             `json:"warc,omitempty"`          // Look URL up in this WARC archive below the local root instead of fetching it
+}
+
+// APIError is the JSON body returned when an API request fails.
+type APIError struct {
+	Error string `json:"error"`
+}
+
+// APIAnalyzeHandler analyzes the URL from a JSON request body and responds with the
+// analysis result as JSON, or as JUnit XML or SARIF when the Accept header asks for
+// application/junit+xml or application/sarif+json. Assertions supplied in the request
+// are evaluated in addition to the ones loaded from the assertions config.
+func APIAnalyzeHandler(w http.ResponseWriter, r *http.Request) {
+	// Only POST carries a request body to analyze
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{Error: "method not allowed"})
+		return
+	}
+
+	// Bound the body, which may carry a whole HTML document
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize+1<<20)
+
+	var req APIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logging.FromContext(r.Context()).Warn("Malformed API request body", "error", err)
+		writeJSON(w, http.StatusBadRequest, APIError{Error: "invalid JSON body: " + err.Error()})
+		return
+	}
+	if req.URL == "" && req.HTML == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{Error: "URL is required"})
+		return
+	}
+	if err := parser.ValidateAssertions(req.Assertions); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{Error: err.Error()})
+		return
+	}
+
+	// Request assertions run after the configured ones
+	opts := parser.Options{
+		Assertions:   append(append([]parser.Assertion{}, assertions...), req.Assertions...),
+		Render:       req.Render,
+		WaitSelector: req.WaitSelector,
+	}
+
+	// Select an alternative document source when the request names one
+	switch {
+	case req.HTML != "" && req.WARC != "":
+		writeJSON(w, http.StatusBadRequest, APIError{Error: "html and warc are mutually exclusive"})
+		return
+	case req.HTML != "":
+		req.URL, opts = uploadOptions([]byte(req.HTML), req.URL, req.CheckLinks, opts)
+	case req.WARC != "" && (req.Render || req.WaitSelector != ""):
+		// The browser would load the live site, not the archived record
+		writeJSON(w, http.StatusBadRequest, APIError{Error: "render and wait_selector cannot be used with warc"})
+		return
+	case req.WARC != "":
+		opts.Fetcher = &parser.WARCFetcher{Root: parser.LocalRoot, Path: req.WARC}
+	}
+
+	// Valid requests count against the API key's daily quota
+	if !chargeAnalyses(w, r, 1) {
+		return
+	}
+
+	analysis, err := parser.AnalyzePage(r.Context(), req.URL, opts)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("API page analysis failed", "url", req.URL, "error", err)
+		writeJSON(w, http.StatusBadGateway, APIError{Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Vary", "Accept")
+	format := negotiateFormat(r.Header.Get("Accept"))
+	if format == report.FormatJSON {
+		writeJSON(w, http.StatusOK, analysis)
+		return
+	}
+
+	// CI formats are rendered into a buffer so a failure can still be reported as an error
+	var body bytes.Buffer
+	if err := report.Write(&body, format, analysis); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to render report", "format", format, "error", err)
+		writeJSON(w, http.StatusInternalServerError, APIError{Error: "unable to render report"})
+		return
+	}
+	w.Header().Set("Content-Type", report.ContentType(format))
+	w.WriteHeader(http.StatusOK)
+	body.WriteTo(w)
+}
+
+// acceptFormats maps the media types /api/analyze can respond with to report formats.
+var acceptFormats = map[string]string{
+	"application/json":       report.FormatJSON,
+	"application/sarif+json": report.FormatSARIF,
+	"application/junit+xml":  report.FormatJUnit,
+	"application/xml":        report.FormatJUnit,
+	"text/xml":               report.FormatJUnit,
+}
+
+// negotiateFormat picks the report format for an Accept header: the supported media
+// type with the highest quality, the earliest listed on ties. Wildcards, a missing
+// header and unsupported types all fall back to JSON.
+func negotiateFormat(accept string) string {
+	best, bestQ := report.FormatJSON, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		format, ok := acceptFormats[mediaType]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			q = v
+		}
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best
+}
+
+// writeJSON encodes v as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to encode JSON response", "error", err)
+	}
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"lucytech/auth"
+	"lucytech/logging"
+	"lucytech/metrics"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiKeys holds the API keys accepted by RequireAPIKey; nil disables authentication.
+var apiKeys *auth.Store
+
+// keyLimiter enforces the rate limits and daily quotas of the API keys.
+var keyLimiter *auth.Limiter
+
+// ConfigureAuth enables API key authentication with the given store and default limits,
+// and makes the key store a readiness check.
+func ConfigureAuth(store *auth.Store, limits auth.LimitConfig) {
+	apiKeys = store
+	keyLimiter = auth.NewLimiter(limits)
+	RegisterReadinessCheck("api_keys", func(context.Context) error {
+		return store.Ping()
+	})
+}
+
+// RequireAPIKey rejects requests without a valid API key with 401 and requests over
+// the key's rate limit with 429 and a Retry-After header. Analyses are counted against
+// the daily quota by the handlers with chargeAnalyses, once a request was validated
+// and admitted, so rejected requests do not use up the quota. It passes everything
+// through while authentication is disabled.
+func RequireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiKeys == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, err := apiKeys.Lookup(apiKeyFrom(r))
+		if err != nil {
+			reason := "invalid_key"
+			if errors.Is(err, auth.ErrMissingKey) {
+				reason = "missing_key"
+			}
+			metrics.AuthRejections.WithLabelValues(reason).Inc()
+			logging.FromContext(r.Context()).Warn("Rejected unauthenticated request", "reason", reason)
+			// Lets browsers prompt for the key when the form is submitted
+			w.Header().Set("WWW-Authenticate", `Basic realm="lucytech", charset="UTF-8"`)
+			writeError(w, r, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		var limitErr *auth.LimitError
+		if err := keyLimiter.Allow(key); errors.As(err, &limitErr) {
+			metrics.AuthRejections.WithLabelValues(limitErr.Reason).Inc()
+			logging.FromContext(r.Context()).Warn("Rejected request over limit", "api_key", key.ID, "reason", limitErr.Reason)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(limitErr.RetryAfter)))
+			writeError(w, r, http.StatusTooManyRequests, limitErr.Error())
+			return
+		}
+
+		// Tag the remaining log lines of the request with the key
+		logger := logging.FromContext(r.Context()).With("api_key", key.ID)
+		ctx := context.WithValue(logging.WithLogger(r.Context(), logger), apiKeyCtxKey{}, key)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// apiKeyCtxKey is the context key of the API key that authenticated a request.
+type apiKeyCtxKey struct{}
+
+// chargeAnalyses counts n analyses against the daily quota of the request's API key.
+// If the quota is exhausted it answers 429 and returns false. Without authentication
+// it always returns true.
+func chargeAnalyses(w http.ResponseWriter, r *http.Request, n int) bool {
+	key, ok := r.Context().Value(apiKeyCtxKey{}).(auth.Key)
+	if !ok || n <= 0 {
+		return true
+	}
+	var limitErr *auth.LimitError
+	if err := keyLimiter.Charge(key, n); errors.As(err, &limitErr) {
+		metrics.AuthRejections.WithLabelValues(limitErr.Reason).Inc()
+		logging.FromContext(r.Context()).Warn("Rejected request over limit", "api_key", key.ID, "reason", limitErr.Reason, "analyses", n)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(limitErr.RetryAfter)))
+		writeError(w, r, http.StatusTooManyRequests, limitErr.Error())
+		return false
+	}
+	return true
+}
+
+// refundAnalyses takes back n analyses charged with chargeAnalyses, for requests that
+// were rejected afterwards.
+func refundAnalyses(r *http.Request, n int) {
+	if key, ok := r.Context().Value(apiKeyCtxKey{}).(auth.Key); ok && n > 0 {
+		keyLimiter.Refund(key, n)
+	}
+}
+
+// quotaCharger returns a function that counts n further analyses against the daily
+// quota of the request's API key. It is meant for work whose size is known only after
+// the response was sent, and returns nil without authentication.
+func quotaCharger(r *http.Request) func(n int) error {
+	key, ok := r.Context().Value(apiKeyCtxKey{}).(auth.Key)
+	if !ok {
+		return nil
+	}
+	limiter, logger := keyLimiter, logging.FromContext(r.Context())
+	return func(n int) error {
+		if n <= 0 {
+			return nil
+		}
+		err := limiter.Charge(key, n)
+		var limitErr *auth.LimitError
+		if errors.As(err, &limitErr) {
+			metrics.AuthRejections.WithLabelValues(limitErr.Reason).Inc()
+			logger.Warn("Rejected analyses over limit", "api_key", key.ID, "reason", limitErr.Reason, "analyses", n)
+		}
+		return err
+	}
+}
+
+// apiKeyFrom extracts the API key from an "Authorization: Bearer" header, the X-API-Key
+// header, or the password of HTTP basic authentication as sent by browsers.
+func apiKeyFrom(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	return ""
+}
+
+// writeError reports a rejected request as a JSON error on API routes and as the
+// error message of the HTML page on the form routes.
+func writeError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeJSON(w, status, APIError{Error: msg})
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, PageData{Error: msg}); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to render error message template", "error", err)
+	}
+}
+
+// retryAfterSeconds rounds a retry delay up to whole seconds for the Retry-After header.
+func retryAfterSeconds(d time.Duration) int {
+	return int(math.Max(1, math.Ceil(d.Seconds())))
+}
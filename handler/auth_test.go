@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"lucytech/auth"
+	"lucytech/batch"
+	"lucytech/parser"
+	"lucytech/throttle"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withAuth enables API key authentication for the duration of a test
+func withAuth(t *testing.T, limits auth.LimitConfig) {
+	t.Helper()
+	store, err := auth.OpenStore("")
+	if err != nil {
+		t.Fatalf("OpenStore returned error: %v", err)
+	}
+	origChecks := readinessChecks
+	ConfigureAuth(store, limits)
+	t.Cleanup(func() { apiKeys, keyLimiter, readinessChecks = nil, nil, origChecks })
+}
+
+// TestConfigureAuth_Readiness checks that /readyz fails while the API key store cannot be written
+func TestConfigureAuth_Readiness(t *testing.T) {
+	dir := t.TempDir()
+	store, err := auth.OpenStore(filepath.Join(dir, "keys", "api-keys.json"))
+	if err != nil {
+		t.Fatalf("OpenStore returned error: %v", err)
+	}
+	origChecks := readinessChecks
+	ConfigureAuth(store, auth.LimitConfig{})
+	defer func() { apiKeys, keyLimiter, readinessChecks = nil, nil, origChecks }()
+
+	// The directory of the store file does not exist
+	w := httptest.NewRecorder()
+	ReadyzHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "api_keys") {
+		t.Errorf("status %d body %q; want 503 naming the API key store", w.Code, w.Body)
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "keys"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	ReadyzHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("status %d body %q; want 200 once the store is writable", w.Code, w.Body)
+	}
+}
+
+// createKey creates an API key through the admin endpoint and returns its secret
+func createKey(t *testing.T, body string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	APIKeysHandler(w, httptest.NewRequest(http.MethodPost, "/admin/api-keys", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating key: status %d: %s", w.Code, w.Body)
+	}
+	var info APIKeyInfo
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if info.Hash != "" {
+		t.Error("created key exposes its hash")
+	}
+	return info.Secret
+}
+
+// TestRequireAPIKey covers missing, invalid and valid keys and the daily quota
+func TestRequireAPIKey(t *testing.T) {
+	withAuth(t, auth.LimitConfig{})
+	secret := createKey(t, `{"name":"ci","daily_quota":1}`)
+	h := RequireAPIKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if chargeAnalyses(w, r, 1) {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+
+	serve := func(path string, setKey func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if setKey != nil {
+			setKey(req)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	// Missing keys get a JSON error on the API and the error page on the form
+	w := serve("/api/analyze", nil)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `"error"`) {
+		t.Errorf("API without key: status %d body %q; want 401 JSON", w.Code, w.Body)
+	}
+	w = serve("/analyze", nil)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" || !strings.Contains(w.Body.String(), "Error:") {
+		t.Errorf("form without key: status %d; want 401 page with WWW-Authenticate", w.Code)
+	}
+
+	w = serve("/api/analyze", func(r *http.Request) { r.Header.Set("Authorization", "Bearer lt_wrong") })
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("invalid key: status %d; want 401", w.Code)
+	}
+
+	// Browsers send the key as the basic auth password
+	w = serve("/analyze", func(r *http.Request) { r.SetBasicAuth("", secret) })
+	if w.Code != http.StatusNoContent {
+		t.Errorf("valid key: status %d; want 204", w.Code)
+	}
+
+	// The quota of one analysis per day is used up
+	w = serve("/api/analyze", func(r *http.Request) { r.Header.Set("X-API-Key", secret) })
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("over quota: status %d, Retry-After %q; want 429 with Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+// TestRequireAPIKey_RejectedRequestsAreFree checks that requests rejected as invalid or
+// because of overload do not count against the daily quota
+func TestRequireAPIKey_RejectedRequestsAreFree(t *testing.T) {
+	withAuth(t, auth.LimitConfig{})
+	secret := createKey(t, `{"name":"ci","daily_quota":5}`)
+	key, err := apiKeys.Lookup(secret)
+	if err != nil {
+		t.Fatalf("Lookup returned error: %v", err)
+	}
+	serve := func(h http.Handler, path, body string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", secret)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+	analyze := RequireAPIKey(LimitConcurrency(http.HandlerFunc(APIAnalyzeHandler)))
+
+	if code := serve(analyze, "/api/analyze", `{"url":`); code != http.StatusBadRequest {
+		t.Errorf("invalid JSON: status %d; want 400", code)
+	}
+	if got := keyLimiter.Usage(key.ID); got != 0 {
+		t.Errorf("usage after 400 = %d; want 0", got)
+	}
+
+	// A full queue rejects the analysis before it is charged
+	gate := throttle.NewGate(1, 0, 10*time.Millisecond)
+	withThrottling(t, gate, nil)
+	release, err := gate.Acquire(t.Context())
+	if err != nil {
+		t.Fatalf("Acquire returned error: %v", err)
+	}
+	if code := serve(analyze, "/api/analyze", `{"url":"https://example.com"}`); code != http.StatusServiceUnavailable {
+		t.Errorf("queue full: status %d; want 503", code)
+	}
+	release()
+
+	// A batch rejected because too many batches are running is refunded
+	m := withBatches(t, batch.Config{MaxRunning: 1})
+	block := make(chan struct{})
+	defer close(block)
+	m.Analyze = func(ctx context.Context, rawURL string, opts parser.Options) (*parser.AnalysisResult, error) {
+		<-block
+		return &parser.AnalysisResult{}, nil
+	}
+	if _, err := m.Submit(t.Context(), []string{"https://running.example"}, parser.Options{}); err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	batchHandler := RequireAPIKey(http.HandlerFunc(APIBatchHandler))
+	if code := serve(batchHandler, "/api/batch", `["https://a.example", "https://b.example"]`); code != http.StatusServiceUnavailable {
+		t.Errorf("too many batches: status %d; want 503", code)
+	}
+	if code := serve(batchHandler, "/api/batch", `{"crawl":"https://a.example"}`); code != http.StatusServiceUnavailable {
+		t.Errorf("too many batches for a crawl: status %d; want 503", code)
+	}
+	if got := keyLimiter.Usage(key.ID); got != 0 {
+		t.Errorf("usage after 503 = %d; want 0", got)
+	}
+}
+
+// TestAPIKeysHandler covers listing and revoking keys
+func TestAPIKeysHandler(t *testing.T) {
+	withAuth(t, auth.LimitConfig{})
+	createKey(t, `{"name":"ci"}`)
+
+	w := httptest.NewRecorder()
+	APIKeysHandler(w, httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil))
+	var keys []APIKeyInfo
+	if err := json.NewDecoder(w.Body).Decode(&keys); err != nil || len(keys) != 1 {
+		t.Fatalf("GET = %v, %v; want one key", keys, err)
+	}
+	if keys[0].Hash != "" || keys[0].Secret != "" {
+		t.Error("listed key exposes its hash or secret")
+	}
+
+	w = httptest.NewRecorder()
+	APIKeysHandler(w, httptest.NewRequest(http.MethodDelete, "/admin/api-keys?id="+keys[0].ID, nil))
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte("revoked_at")) {
+		t.Errorf("DELETE: status %d body %q; want revoked key", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	APIKeysHandler(w, httptest.NewRequest(http.MethodDelete, "/admin/api-keys?id=unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("DELETE unknown: status %d; want 404", w.Code)
+	}
+
+	// A key needs a name
+	w = httptest.NewRecorder()
+	APIKeysHandler(w, httptest.NewRequest(http.MethodPost, "/admin/api-keys", strings.NewReader(`{}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("POST without name: status %d; want 400", w.Code)
+	}
+}
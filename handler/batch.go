@@ -0,0 +1,490 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"lucytech/batch"
+	"lucytech/crawl"
+	"lucytech/logging"
+	"lucytech/parser"
+	"lucytech/report"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// batches runs batch analyses; nil disables the batch endpoints.
+var batches *batch.Manager
+
+// batchTmpl renders the batch form and status pages.
+var batchTmpl *template.Template
+
+// ConfigureBatches enables the batch endpoints.
+func ConfigureBatches(m *batch.Manager) {
+	batches = m
+}
+
+// LoadBatchTemplate loads the batch page template. Like LoadTemplates, it panics on
+// error to fail fast on startup.
+func LoadBatchTemplate(path string) {
+	batchTmpl = template.Must(template.ParseFiles(path))
+	slog.Info("Templates loaded successfully", "path", path)
+}
+
+// BatchRequest is the JSON body accepted by the /api/batch endpoint. A bare JSON
+// array of URLs is accepted as well.
+type BatchRequest struct {
+	URLs         []string           `json:"urls"`                    // Pages to analyze
+	Sitemap      string             `json:"sitemap,omitempty"`       // Instead of URLs: a site or sitemap whose listed pages are analyzed
+	Crawl        string             `json:"crawl,omitempty"`         // Instead of URLs: a start URL whose site is crawled
+	MaxPages     int                `json:"max_pages,omitempty"`     // Crawl: pages requested at most; default and cap: the batch URL limit
+	MaxDepth     int                `json:"max_depth,omitempty"`     // Crawl: clicks from the start URL followed at most; default 10
+	Assertions   []parser.Assertion `json:"assertions,omitempty"`    // Extra assertions evaluated on every page
+	Render       bool               `json:"render,omitempty"`        // Analyze the DOMs rendered by a headless browser
+	WaitSelector string             `json:"wait_selector,omitempty"` // In render mode, wait for this selector instead of network idle
+}
+
+// APIBatchHandler manages batch analyses as JSON. POST starts a batch from a
+// BatchRequest and answers 202 with the batch, whose progress GET reports for the
+// "id" query parameter; DELETE cancels it.
+func APIBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if batches == nil {
+		writeJSON(w, http.StatusNotFound, APIError{Error: "batch analysis is disabled"})
+		return
+	}
+	id := r.URL.Query().Get("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		job, err := batches.Get(id)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, APIError{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	case http.MethodPost:
+		raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxUploadSize))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{Error: "unable to read request body: " + err.Error()})
+			return
+		}
+		var req BatchRequest
+		if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+			err = json.Unmarshal(raw, &req.URLs)
+		} else {
+			err = json.Unmarshal(raw, &req)
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{Error: "invalid JSON body: " + err.Error()})
+			return
+		}
+		if err := parser.ValidateAssertions(req.Assertions); err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{Error: err.Error()})
+			return
+		}
+		opts := parser.Options{
+			Assertions:   append(append([]parser.Assertion{}, assertions...), req.Assertions...),
+			Render:       req.Render,
+			WaitSelector: req.WaitSelector,
+		}
+		var job batch.Job
+		var ok bool
+		switch {
+		case req.Crawl != "" && len(req.URLs) == 0:
+			job, ok = submitCrawl(w, r, req.Crawl, crawl.Config{MaxPages: req.MaxPages, MaxDepth: req.MaxDepth}, opts)
+		case req.Sitemap != "" && len(req.URLs) == 0:
+			job, ok = submitSitemap(w, r, req.Sitemap, opts)
+		default:
+			job, ok = submitBatch(w, r, batch.ParseURLs(strings.Join(req.URLs, "\n")), opts)
+		}
+		if !ok {
+			return
+		}
+		w.Header().Set("Location", "/api/batch?id="+job.ID)
+		writeJSON(w, http.StatusAccepted, job)
+	case http.MethodDelete:
+		job, err := batches.Cancel(id)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, APIError{Error: err.Error()})
+			return
+		}
+		logging.FromContext(r.Context()).Info("Batch canceled", "batch_id", id)
+		writeJSON(w, http.StatusOK, job)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{Error: "method not allowed"})
+	}
+}
+
+// submitBatch charges the URLs against the API key's quota and starts the batch.
+// On failure it writes the error response and returns false.
+func submitBatch(w http.ResponseWriter, r *http.Request, urls []string, opts parser.Options) (batch.Job, bool) {
+	if len(urls) > batches.Config().MaxURLs {
+		writeError(w, r, http.StatusBadRequest, fmt.Sprintf("%s: %d given, at most %d allowed", batch.ErrTooManyURLs, len(urls), batches.Config().MaxURLs))
+		return batch.Job{}, false
+	}
+	if !chargeAnalyses(w, r, len(urls)) {
+		return batch.Job{}, false
+	}
+
+	return startBatch(w, r, len(urls), func() (batch.Job, error) { return batches.Submit(r.Context(), urls, opts) })
+}
+
+// submitSitemap starts a batch seeded from the sitemaps of a site. One analysis is
+// charged against the API key's quota up front, the other URLs once the sitemaps are read.
+func submitSitemap(w http.ResponseWriter, r *http.Request, site string, opts parser.Options) (batch.Job, bool) {
+	if !chargeAnalyses(w, r, 1) {
+		return batch.Job{}, false
+	}
+	return startBatch(w, r, 1, func() (batch.Job, error) { return batches.SubmitSitemap(r.Context(), site, opts, quotaCharger(r)) })
+}
+
+// submitCrawl starts a batch that crawls a site. Every page after the first is charged
+// against the API key's quota as the crawl reaches it.
+func submitCrawl(w http.ResponseWriter, r *http.Request, start string, cfg crawl.Config, opts parser.Options) (batch.Job, bool) {
+	if cfg.MaxPages < 0 || cfg.MaxDepth < 0 {
+		writeError(w, r, http.StatusBadRequest, "max_pages and max_depth must not be negative")
+		return batch.Job{}, false
+	}
+	if !chargeAnalyses(w, r, 1) {
+		return batch.Job{}, false
+	}
+	return startBatch(w, r, 1, func() (batch.Job, error) { return batches.SubmitCrawl(r.Context(), start, cfg, opts, quotaCharger(r)) })
+}
+
+// startBatch submits a batch and logs it. On failure it refunds the charged analyses,
+// writes the error response and returns false.
+func startBatch(w http.ResponseWriter, r *http.Request, charged int, submit func() (batch.Job, error)) (batch.Job, bool) {
+	job, err := submit()
+	if err != nil {
+		refundAnalyses(r, charged)
+	}
+	switch {
+	case errors.Is(err, batch.ErrTooManyJobs):
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(overloadRetryAfter)))
+		writeError(w, r, http.StatusServiceUnavailable, err.Error())
+		return batch.Job{}, false
+	case err != nil:
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return batch.Job{}, false
+	}
+	logging.FromContext(r.Context()).Info("Batch started", "batch_id", job.ID, "urls", job.Total, "sitemap", job.SitemapURL, "crawl", job.CrawlURL)
+	return job, true
+}
+
+// BatchRow is one URL of the batch summary table.
+type BatchRow struct {
+	Index             int    // Position in the batch, used for the drilldown link
+	URL               string // Analyzed URL
+	Status            string // Item status, see the batch package
+	Error             string // Why the analysis failed
+	Title             string // Page title
+	InternalLinks     int    // Number of internal links
+	ExternalLinks     int    // Number of external links
+	InaccessibleLinks int    // Number of inaccessible links
+	MissingAnchors    int    // Number of links to missing anchors
+	FailedAssertions  int    // Number of assertions that did not pass
+	DurationMS        int64  // Time taken to analyze the URL
+}
+
+// BatchColumn is a header of the batch summary table, linking to the table sorted by it.
+type BatchColumn struct {
+	Label string // Header text
+	Href  string // Summary sorted by this column; descending if it is already sorted ascending
+	Arrow string // "▲" or "▼" on the column the table is sorted by
+}
+
+// BatchPageData is passed to the batch template.
+type BatchPageData struct {
+	Job     *batch.Job    // The batch being shown; nil on the submission form
+	Columns []BatchColumn // Headers of the summary table
+	Rows    []BatchRow    // Items of the batch in the requested order
+	Sort    string        // Column the rows are sorted by
+	Desc    bool          // True if sorted in descending order
+	MaxURLs int           // URLs accepted per batch
+	Error   string        // Populated when there's an error to display
+
+	Query      string       // Search of the crawled pages table
+	CrawlPages []crawl.Page // Crawled pages matching Query
+	Exports    []string     // Formats the link graph can be downloaded in
+}
+
+// batchColumns lists the sort keys and labels of the summary table in display order.
+var batchColumns = []struct{ key, label string }{
+	{"index", "#"},
+	{"url", "URL"},
+	{"status", "Status"},
+	{"title", "Title"},
+	{"internal", "Internal"},
+	{"external", "External"},
+	{"inaccessible", "Inaccessible"},
+	{"anchors", "Missing Anchors"},
+	{"assertions", "Failed Assertions"},
+	{"duration", "Time (ms)"},
+}
+
+// batchSortKeys maps the sortable columns of the summary table to their comparisons.
+var batchSortKeys = map[string]func(a, b BatchRow) int{
+	"index":        func(a, b BatchRow) int { return a.Index - b.Index },
+	"url":          func(a, b BatchRow) int { return strings.Compare(a.URL, b.URL) },
+	"status":       func(a, b BatchRow) int { return strings.Compare(a.Status, b.Status) },
+	"title":        func(a, b BatchRow) int { return strings.Compare(a.Title, b.Title) },
+	"internal":     func(a, b BatchRow) int { return a.InternalLinks - b.InternalLinks },
+	"external":     func(a, b BatchRow) int { return a.ExternalLinks - b.ExternalLinks },
+	"inaccessible": func(a, b BatchRow) int { return a.InaccessibleLinks - b.InaccessibleLinks },
+	"anchors":      func(a, b BatchRow) int { return a.MissingAnchors - b.MissingAnchors },
+	"assertions":   func(a, b BatchRow) int { return a.FailedAssertions - b.FailedAssertions },
+	"duration":     func(a, b BatchRow) int { return int(a.DurationMS - b.DurationMS) },
+}
+
+// BatchHandler serves the batch UI. GET shows the submission form, or with an "id"
+// query parameter the batch's summary table sorted by "sort" and "order" and, for a
+// crawl, its pages matching the "q" search, or with an additional "item" parameter
+// the full result of one URL. POST starts a batch from the "urls" textarea, an
+// uploaded "urls_file" (one URL per line, CSV or JSON array), the sitemaps of the
+// "sitemap" site or a crawl from "crawl" bounded by "max_pages" and "max_depth", and
+// redirects to its summary.
+func BatchHandler(w http.ResponseWriter, r *http.Request) {
+	if batches == nil {
+		writeError(w, r, http.StatusNotFound, "batch analysis is disabled")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			renderBatch(w, r, http.StatusOK, BatchPageData{MaxURLs: batches.Config().MaxURLs})
+			return
+		}
+		job, err := batches.Get(id)
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		if item := r.URL.Query().Get("item"); item != "" {
+			batchItem(w, r, job, item)
+			return
+		}
+		data := batchSummary(job, r.URL.Query().Get("sort"), r.URL.Query().Get("order") == "desc")
+		if job.Crawl != nil {
+			data.Query = strings.TrimSpace(r.URL.Query().Get("q"))
+			data.CrawlPages = searchPages(job.Crawl.Pages, data.Query)
+			data.Exports = report.GraphFormats
+		}
+		renderBatch(w, r, http.StatusOK, data)
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize+1<<20)
+		opts := parser.Options{
+			Assertions:   assertions,
+			Render:       r.FormValue("render") != "",
+			WaitSelector: r.FormValue("wait_selector"),
+		}
+		var job batch.Job
+		var ok bool
+		if start := strings.TrimSpace(r.FormValue("crawl")); start != "" {
+			var cfg crawl.Config
+			cfg.MaxPages, _ = strconv.Atoi(r.FormValue("max_pages")) // Blank or invalid selects the default
+			cfg.MaxDepth, _ = strconv.Atoi(r.FormValue("max_depth"))
+			job, ok = submitCrawl(w, r, start, cfg, opts)
+		} else if site := strings.TrimSpace(r.FormValue("sitemap")); site != "" {
+			job, ok = submitSitemap(w, r, site, opts)
+		} else {
+			urls, err := batchURLs(r)
+			if err != nil {
+				renderBatch(w, r, http.StatusBadRequest, BatchPageData{MaxURLs: batches.Config().MaxURLs, Error: err.Error()})
+				return
+			}
+			job, ok = submitBatch(w, r, urls, opts)
+		}
+		if !ok {
+			return
+		}
+		http.Redirect(w, r, "/batch?id="+job.ID, http.StatusSeeOther)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// batchURLs reads the URL list from an uploaded file, falling back to the textarea.
+// Files ending in .csv are read as CSV and files ending in .json as a JSON array.
+func batchURLs(r *http.Request) ([]string, error) {
+	file, header, err := r.FormFile("urls_file")
+	switch {
+	case err == nil:
+		defer file.Close()
+		raw, err := io.ReadAll(io.LimitReader(file, maxUploadSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(raw) > maxUploadSize {
+			return nil, fmt.Errorf("file exceeds %d bytes", maxUploadSize)
+		}
+		switch strings.ToLower(filepath.Ext(header.Filename)) {
+		case ".csv":
+			return batch.ParseCSV(string(raw))
+		case ".json":
+			var urls []string
+			if err := json.Unmarshal(raw, &urls); err != nil {
+				return nil, fmt.Errorf("invalid JSON array of URLs: %w", err)
+			}
+			return batch.ParseURLs(strings.Join(urls, "\n")), nil
+		default:
+			return batch.ParseURLs(string(raw)), nil
+		}
+	case errors.Is(err, http.ErrMissingFile), errors.Is(err, http.ErrNotMultipart):
+		urls := batch.ParseURLs(r.FormValue("urls"))
+		if len(urls) == 0 {
+			return nil, errors.New("URLs are required: enter one per line or choose a file")
+		}
+		return urls, nil
+	default:
+		return nil, err
+	}
+}
+
+// batchSummary builds the summary table of a batch sorted by the given column.
+// Unknown columns keep the submission order.
+func batchSummary(job batch.Job, sortBy string, desc bool) BatchPageData {
+	rows := make([]BatchRow, len(job.Items))
+	for i, item := range job.Items {
+		row := BatchRow{Index: i, URL: item.URL, Status: item.Status, Error: item.Error, DurationMS: item.DurationMS}
+		if res := item.Result; res != nil {
+			row.Title = res.Title
+			row.InternalLinks = res.InternalLinks
+			row.ExternalLinks = res.ExternalLinks
+			row.InaccessibleLinks = res.InaccessibleLinks
+			row.MissingAnchors = res.MissingAnchors
+			for _, a := range res.Assertions {
+				if !a.Passed {
+					row.FailedAssertions++
+				}
+			}
+		}
+		rows[i] = row
+	}
+
+	cmp, ok := batchSortKeys[sortBy]
+	if !ok {
+		sortBy, cmp = "index", batchSortKeys["index"]
+	}
+	slices.SortStableFunc(rows, func(a, b BatchRow) int {
+		if desc {
+			return cmp(b, a)
+		}
+		return cmp(a, b)
+	})
+
+	columns := make([]BatchColumn, len(batchColumns))
+	for i, c := range batchColumns {
+		col := BatchColumn{Label: c.label, Href: "/batch?id=" + job.ID + "&sort=" + c.key}
+		if c.key == sortBy {
+			col.Arrow = "▲"
+			if desc {
+				col.Arrow = "▼"
+			} else {
+				col.Href += "&order=desc"
+			}
+		}
+		columns[i] = col
+	}
+	return BatchPageData{Job: &job, Columns: columns, Rows: rows, Sort: sortBy, Desc: desc, MaxURLs: batches.Config().MaxURLs}
+}
+
+// searchPages returns the crawled pages whose URL or title contains the query, ignoring
+// case, or all pages for an empty query.
+func searchPages(pages []crawl.Page, query string) []crawl.Page {
+	if query == "" {
+		return pages
+	}
+	query = strings.ToLower(query)
+	var found []crawl.Page
+	for _, p := range pages {
+		if strings.Contains(strings.ToLower(p.URL), query) || strings.Contains(strings.ToLower(p.Title), query) {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+// BatchGraphHandler downloads the internal link graph of a finished crawl batch, given
+// by the "id" parameter, in the "format" parameter's format (dot, graphml, csv or json).
+func BatchGraphHandler(w http.ResponseWriter, r *http.Request) {
+	if batches == nil {
+		writeError(w, r, http.StatusNotFound, "batch analysis is disabled")
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	job, err := batches.Get(r.URL.Query().Get("id"))
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	if job.Crawl == nil {
+		writeError(w, r, http.StatusNotFound, "the batch has no finished crawl")
+		return
+	}
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if report.GraphContentType(format) == "" {
+		writeError(w, r, http.StatusBadRequest, "format must be one of "+strings.Join(report.GraphFormats, ", "))
+		return
+	}
+
+	// Render into a buffer so a failure can still be reported with a proper status
+	var body bytes.Buffer
+	if err := report.WriteGraph(&body, format, job.Crawl); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to render link graph", "format", format, "error", err)
+		writeError(w, r, http.StatusInternalServerError, "unable to render link graph")
+		return
+	}
+	w.Header().Set("Content-Type", report.GraphContentType(format))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": report.GraphFilename(format, job.Crawl)}))
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	if _, err := body.WriteTo(w); err != nil {
+		logging.FromContext(r.Context()).Warn("Failed to send link graph", "format", format, "error", err)
+	}
+}
+
+// batchItem renders the full result of one URL of a batch with the single-page template.
+func batchItem(w http.ResponseWriter, r *http.Request, job batch.Job, index string) {
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i >= len(job.Items) {
+		writeError(w, r, http.StatusNotFound, "no such item in the batch")
+		return
+	}
+	item := job.Items[i]
+	data := PageData{Back: "/batch?id=" + job.ID}
+	switch {
+	case item.Result != nil:
+		data.Result = newResultData(r, item.Result, false)
+	case item.Error != "":
+		data.Error = item.URL + ": " + item.Error
+	default:
+		data.Error = item.URL + " has not been analyzed yet"
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to render batch item template", "error", err)
+	}
+}
+
+// renderBatch renders the batch template with the given status code.
+func renderBatch(w http.ResponseWriter, r *http.Request, status int, data BatchPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := batchTmpl.Execute(w, data); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to render batch template", "error", err)
+	}
+}
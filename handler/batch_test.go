@@ -0,0 +1,293 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"lucytech/auth"
+	"lucytech/batch"
+	"lucytech/crawl"
+	"lucytech/parser"
+	"lucytech/sitemap"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withBatches enables batch analysis with a stubbed analyzer that fails URLs containing "bad"
+// and stubbed sitemap audits and crawls that find two pages of the site
+func withBatches(t *testing.T, cfg batch.Config) *batch.Manager {
+	t.Helper()
+	m := batch.NewManager(cfg)
+	m.Analyze = func(ctx context.Context, rawURL string, opts parser.Options) (*parser.AnalysisResult, error) {
+		if strings.Contains(rawURL, "bad") {
+			return nil, errors.New("unreachable")
+		}
+		return &parser.AnalysisResult{Title: "Title of " + rawURL, InternalLinks: len(rawURL)}, nil
+	}
+	m.Audit = func(ctx context.Context, rawURL string, cfg sitemap.Config) (*sitemap.Report, error) {
+		return &sitemap.Report{Site: rawURL, Entries: []sitemap.Entry{{URL: rawURL + "/"}, {URL: rawURL + "/about"}}}, nil
+	}
+	m.Crawl = func(ctx context.Context, rawURL string, cfg crawl.Config, visit crawl.Visit) (*crawl.Graph, error) {
+		for _, u := range []string{rawURL + "/", rawURL + "/pricing"} {
+			if _, err := visit(ctx, u); err != nil {
+				return nil, err
+			}
+		}
+		return &crawl.Graph{Start: rawURL + "/", Pages: []crawl.Page{
+			{URL: rawURL + "/", StatusCode: http.StatusOK, Title: "Home", Outbound: []string{rawURL + "/pricing"}},
+			{URL: rawURL + "/pricing", Depth: 1, StatusCode: http.StatusOK, Title: "Pricing", Inbound: []string{rawURL + "/"}},
+		}}, nil
+	}
+	ConfigureBatches(m)
+	t.Cleanup(func() { batches = nil })
+	return m
+}
+
+// waitForBatch polls a batch until it is no longer running
+func waitForBatch(t *testing.T, m *batch.Manager, id string) batch.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("Get returned error: %v", err)
+		}
+		if job.Status != batch.StatusRunning {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("batch %s still running", id)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestAPIBatchHandler covers submitting a bare array and a request object and polling the result
+func TestAPIBatchHandler(t *testing.T) {
+	m := withBatches(t, batch.Config{})
+
+	for _, body := range []string{
+		`["https://example.com/a", "https://bad.example.com", "https://example.com/a"]`,
+		`{"urls": ["https://example.com/a", "https://bad.example.com"], "render": false}`,
+	} {
+		w := httptest.NewRecorder()
+		APIBatchHandler(w, httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader(body)))
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("POST %s: status %d: %s", body, w.Code, w.Body)
+		}
+		var job batch.Job
+		json.NewDecoder(w.Body).Decode(&job)
+		if w.Header().Get("Location") != "/api/batch?id="+job.ID || job.Total != 2 {
+			t.Errorf("POST %s: Location %q, total %d; want the batch URL and 2 deduplicated URLs", body, w.Header().Get("Location"), job.Total)
+		}
+		waitForBatch(t, m, job.ID)
+
+		w = httptest.NewRecorder()
+		APIBatchHandler(w, httptest.NewRequest(http.MethodGet, "/api/batch?id="+job.ID, nil))
+		json.NewDecoder(w.Body).Decode(&job)
+		if job.Status != batch.StatusDone || job.Completed != 2 || job.Failed != 1 {
+			t.Errorf("GET: status %q, %d completed, %d failed; want done, 2, 1", job.Status, job.Completed, job.Failed)
+		}
+		if job.Items[0].Result == nil || job.Items[1].Error != "unreachable" {
+			t.Errorf("items = %+v; want the failure isolated to the second URL", job.Items)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	APIBatchHandler(w, httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader(`[]`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("empty batch: status %d; want 400", w.Code)
+	}
+	w = httptest.NewRecorder()
+	APIBatchHandler(w, httptest.NewRequest(http.MethodGet, "/api/batch?id=missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown batch: status %d; want 404", w.Code)
+	}
+}
+
+// TestAPIBatchHandler_Limits covers the URL limit, the quota charge and disabled batches
+func TestAPIBatchHandler_Limits(t *testing.T) {
+	withBatches(t, batch.Config{MaxURLs: 2})
+	w := httptest.NewRecorder()
+	APIBatchHandler(w, httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader(`["https://a.example", "https://b.example", "https://c.example"]`)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "at most 2") {
+		t.Errorf("too many URLs: status %d body %q; want 400", w.Code, w.Body)
+	}
+
+	// A batch of two URLs does not fit in a daily quota of one analysis
+	withAuth(t, auth.LimitConfig{})
+	secret := createKey(t, `{"name":"ci","daily_quota":1}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader(`["https://a.example", "https://b.example"]`))
+	req.Header.Set("X-API-Key", secret)
+	w = httptest.NewRecorder()
+	RequireAPIKey(http.HandlerFunc(APIBatchHandler)).ServeHTTP(w, req)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("over quota: status %d; want 429 with Retry-After", w.Code)
+	}
+
+	batches = nil
+	w = httptest.NewRecorder()
+	APIBatchHandler(w, httptest.NewRequest(http.MethodGet, "/api/batch?id=x", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("disabled: status %d; want 404", w.Code)
+	}
+}
+
+// TestAPIBatchHandler_Sitemap covers batches seeded from a sitemap and their quota charge
+func TestAPIBatchHandler_Sitemap(t *testing.T) {
+	m := withBatches(t, batch.Config{})
+	withAuth(t, auth.LimitConfig{})
+	secret := createKey(t, `{"name":"ci","daily_quota":3}`)
+	h := RequireAPIKey(http.HandlerFunc(APIBatchHandler))
+
+	submit := func() batch.Job {
+		req := httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader(`{"sitemap": "https://example.com"}`))
+		req.Header.Set("X-API-Key", secret)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("POST: status %d: %s", w.Code, w.Body)
+		}
+		var job batch.Job
+		json.NewDecoder(w.Body).Decode(&job)
+		return waitForBatch(t, m, job.ID)
+	}
+
+	// The two listed pages use up two of the three analyses
+	if job := submit(); job.Status != batch.StatusDone || job.Total != 2 || job.Sitemap == nil || job.SitemapURL != "https://example.com" {
+		t.Errorf("first batch: %s with %d URLs, sitemap %v; want done with 2 URLs", job.Status, job.Total, job.Sitemap)
+	}
+	// The request itself takes the last one, so the listed pages no longer fit
+	if job := submit(); job.Status != batch.StatusFailed || !strings.Contains(job.Error, "quota") {
+		t.Errorf("second batch: %s (%s); want failed over quota", job.Status, job.Error)
+	}
+}
+
+// TestBatchHandler covers submitting the form, the sorted summary and the drilldown
+func TestBatchHandler(t *testing.T) {
+	m := withBatches(t, batch.Config{})
+
+	w := httptest.NewRecorder()
+	BatchHandler(w, httptest.NewRequest(http.MethodGet, "/batch", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("form: status %d; want 200", w.Code)
+	}
+
+	form := "urls=" + strings.Join([]string{"https://example.com/long-path", "https://bad.example", "https://ex.io"}, "%0A")
+	req := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	BatchHandler(w, req)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("POST: status %d; want 303: %s", w.Code, w.Body)
+	}
+	id := strings.TrimPrefix(w.Header().Get("Location"), "/batch?id=")
+	waitForBatch(t, m, id)
+
+	// Sorted by internal links, descending: the longest URL first, the failed one last
+	w = httptest.NewRecorder()
+	BatchHandler(w, httptest.NewRequest(http.MethodGet, "/batch?id="+id+"&sort=internal&order=desc", nil))
+	got := strings.Join(strings.Fields(w.Body.String()), " ")
+	want := "Row: https://example.com/long-path done Row: https://ex.io done Row: https://bad.example failed"
+	if got != want {
+		t.Errorf("summary = %q; want %q", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	BatchHandler(w, httptest.NewRequest(http.MethodGet, "/batch?id="+id+"&item=2", nil))
+	if !strings.Contains(w.Body.String(), "Title of https://ex.io") {
+		t.Errorf("drilldown = %q; want the item's result", w.Body)
+	}
+	w = httptest.NewRecorder()
+	BatchHandler(w, httptest.NewRequest(http.MethodGet, "/batch?id="+id+"&item=1", nil))
+	if !strings.Contains(w.Body.String(), "Error: https://bad.example: unreachable") {
+		t.Errorf("failed drilldown = %q; want the item's error", w.Body)
+	}
+	w = httptest.NewRecorder()
+	BatchHandler(w, httptest.NewRequest(http.MethodGet, "/batch?id="+id+"&item=9", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown item: status %d; want 404", w.Code)
+	}
+}
+
+// TestBatchHandler_Upload covers reading the URLs from an uploaded CSV file
+func TestBatchHandler_Upload(t *testing.T) {
+	m := withBatches(t, batch.Config{})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("urls_file", "urls.csv")
+	fw.Write([]byte("url,owner\nhttps://example.com/a,web\n\"https://example.com/b?x=1,2\",docs\n"))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/batch", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	BatchHandler(w, req)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("POST: status %d; want 303: %s", w.Code, w.Body)
+	}
+	job := waitForBatch(t, m, strings.TrimPrefix(w.Header().Get("Location"), "/batch?id="))
+	if job.Total != 2 || job.Items[1].URL != "https://example.com/b?x=1,2" {
+		t.Errorf("items = %+v; want the two URLs of the first column", job.Items)
+	}
+
+	// Nothing to analyze shows the form again with an error
+	req = httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader("urls="))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	BatchHandler(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Error: URLs are required") {
+		t.Errorf("empty form: status %d body %q; want 400 with an error", w.Code, w.Body)
+	}
+}
+
+// TestBatchHandler_Crawl covers starting a crawl, searching its pages and downloading its graph
+func TestBatchHandler_Crawl(t *testing.T) {
+	m := withBatches(t, batch.Config{})
+
+	req := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader("crawl=https://example.com&max_pages=20&max_depth="))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	BatchHandler(w, req)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("POST: status %d; want 303: %s", w.Code, w.Body)
+	}
+	id := strings.TrimPrefix(w.Header().Get("Location"), "/batch?id=")
+	if job := waitForBatch(t, m, id); job.Status != batch.StatusDone || job.Total != 2 || job.Crawl == nil || job.CrawlURL != "https://example.com" {
+		t.Fatalf("batch = %s with %d URLs, graph %v; want done with 2 URLs", job.Status, job.Total, job.Crawl)
+	}
+
+	w = httptest.NewRecorder()
+	BatchHandler(w, httptest.NewRequest(http.MethodGet, "/batch?id="+id+"&q=PRIC", nil))
+	if !strings.Contains(w.Body.String(), "Page: https://example.com/pricing") || strings.Count(w.Body.String(), "Page: ") != 1 {
+		t.Errorf("search = %q; want only /pricing", w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	BatchGraphHandler(w, httptest.NewRequest(http.MethodGet, "/batch/graph?id="+id+"&format=dot", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/vnd.graphviz; charset=utf-8" ||
+		w.Header().Get("Content-Disposition") != `attachment; filename=example.com-links.dot` ||
+		!strings.Contains(w.Body.String(), `"https://example.com/" -> "https://example.com/pricing";`) {
+		t.Errorf("DOT download: status %d, headers %v, body %q", w.Code, w.Header(), w.Body)
+	}
+	w = httptest.NewRecorder()
+	BatchGraphHandler(w, httptest.NewRequest(http.MethodGet, "/batch/graph?id="+id+"&format=png", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown format: status %d; want 400", w.Code)
+	}
+
+	// A batch of URLs has no graph
+	job, _ := m.Submit(t.Context(), []string{"https://example.com"}, parser.Options{})
+	waitForBatch(t, m, job.ID)
+	w = httptest.NewRecorder()
+	BatchGraphHandler(w, httptest.NewRequest(http.MethodGet, "/batch/graph?id="+job.ID+"&format=dot", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("batch without crawl: status %d; want 404", w.Code)
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"lucytech/logging"
+	"lucytech/parser"
+	"lucytech/report"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ExportHandler renders an analysis result as a downloadable report. The format is
+// taken from the "format" parameter (csv, json, markdown, html or pdf). The result is
+// read from the "result" form field, as posted by the export buttons of the result
+// page, or from a JSON request body, e.g. the response of /api/analyze.
+// Nothing is re-analyzed, so exporting is cheap and reproduces exactly what was shown.
+func ExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	var raw []byte
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		var err error
+		if raw, err = io.ReadAll(r.Body); err != nil {
+			writeError(w, r, http.StatusBadRequest, "unable to read request body: "+err.Error())
+			return
+		}
+	} else {
+		raw = []byte(r.FormValue("result"))
+	}
+
+	format := strings.ToLower(r.FormValue("format"))
+	if report.ContentType(format) == "" {
+		writeError(w, r, http.StatusBadRequest, "format must be one of "+strings.Join(report.Formats, ", "))
+		return
+	}
+
+	var result parser.AnalysisResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid analysis result: "+err.Error())
+		return
+	}
+
+	// Render into a buffer so a failure can still be reported with a proper status
+	var body bytes.Buffer
+	if err := report.Write(&body, format, &result); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to render report", "format", format, "error", err)
+		writeError(w, r, http.StatusInternalServerError, "unable to render report")
+		return
+	}
+
+	// The printable HTML report opens in the browser; the others are downloaded
+	disposition := "attachment"
+	if format == report.FormatHTML {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", report.ContentType(format))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": report.Filename(format, &result)}))
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	if _, err := body.WriteTo(w); err != nil {
+		logging.FromContext(r.Context()).Warn("Failed to send report", "format", format, "error", err)
+	}
+	logging.FromContext(r.Context()).Info("Report exported", "format", format, "url", result.URL)
+}
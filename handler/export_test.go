@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"lucytech/parser"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// exportResult is the analysis result posted to the export handler in tests
+var exportResult = parser.AnalysisResult{
+	URL:   "https://example.com",
+	Title: "Example",
+	Links: []parser.LinkResult{{URL: "https://example.com/gone", Internal: true, Checked: true, StatusCode: 404}},
+}
+
+// TestExportHandler_Form checks exporting the result posted by the result page buttons
+func TestExportHandler_Form(t *testing.T) {
+	raw, _ := json.Marshal(exportResult)
+	form := url.Values{"result": {string(raw)}, "format": {"csv"}}
+	req := httptest.NewRequest(http.MethodPost, "/export", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	ExportHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename=example.com-report.csv` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	if !strings.Contains(w.Body.String(), "https://example.com/gone,internal,true,false,404,") {
+		t.Errorf("CSV lacks the link row: %s", w.Body)
+	}
+}
+
+// TestExportHandler_JSON checks exporting an API response posted as the request body
+func TestExportHandler_JSON(t *testing.T) {
+	raw, _ := json.Marshal(exportResult)
+	req := httptest.NewRequest(http.MethodPost, "/api/export?format=markdown", strings.NewReader(string(raw)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	ExportHandler(w, req)
+
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/markdown") {
+		t.Fatalf("status %d, Content-Type %q; want 200, text/markdown", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "# Web Page Analysis Report") {
+		t.Errorf("unexpected body: %s", w.Body)
+	}
+}
+
+// TestExportHandler_Invalid checks rejection of unknown formats and malformed results
+func TestExportHandler_Invalid(t *testing.T) {
+	for _, tc := range []struct{ query, body string }{
+		{"format=docx", `{"url": "https://example.com"}`},
+		{"format=pdf", `{"url": `},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/export?"+tc.query, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		ExportHandler(w, req)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Header().Get("Content-Type"), "json") {
+			t.Errorf("%s %s: status %d (%s); want 400 JSON", tc.query, tc.body, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -1,33 +1,56 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
+	"io"
 	"log/slog"
-	"lucytech/metrics"
+	"lucytech/logging"
 	"lucytech/parser"
 	"net/http"
-	"time"
 )
 
 // ResultData holds the analysis results that will be passed to the template for rendering.
 type ResultData struct {
-	HTMLVersion       string         // Detected HTML version of the analyzed page
-	Title             string         // Page title
-	Headings          map[string]int // Counts of headings by level (e.g., H1, H2)
-	InternalLinks     int            // Number of internal links on the page
-	ExternalLinks     int            // Number of external links on the page
-	InaccessibleLinks int            // Number of links that were inaccessible (HTTP errors)
-	LoginForm         bool           // Whether a login form with password field was detected
+	URL               string                // Analyzed URL, or the base URL of uploaded HTML (empty if none was given)
+	DisplayURL        string                // Unicode form of URL for IDN hosts and non-ASCII paths
+	Uploaded          bool                  // True if the HTML was pasted or uploaded rather than fetched
+	HTMLVersion       string                // Detected HTML version of the analyzed page
+	Title             string                // Page title
+	Outline           []*parser.Heading     // Heading hierarchy of the page
+	OutlineIssues     []parser.OutlineIssue // Structural problems found in the heading outline
+	InternalLinks     int                   // Number of internal links on the page
+	ExternalLinks     int                   // Number of external links on the page
+	InaccessibleLinks int                   // Number of links that were inaccessible (HTTP errors)
+	MissingAnchors    int                   // Number of internal links whose fragment names no element of their target
+	OtherLinks        map[string]int        // Non-web links by scheme, e.g. mailto and tel
+	RelLinks          map[string]int        // Links by rel keyword, e.g. nofollow and sponsored
+	LoginForm         bool                  // Whether a login form with password field was detected
+
+	Assertions []parser.AssertionResult // Pass/fail outcome of each configured assertion
+
+	Mode       string             // "raw" or "rendered" depending on how the page was analyzed
+	RenderDiff *parser.RenderDiff // Raw vs rendered DOM counts when rendering was used
+
+	InsecureTLS bool // True if TLS certificates were not verified
+
+	Export string // The full analysis result as JSON, posted back by the export buttons
 }
 
 // PageData wraps ResultData or Error message to pass to the HTML template.
 type PageData struct {
 	Result *ResultData // Populated when analysis succeeds
 	Error  string      // Populated when there's an error to display
+	Back   string      // Link back to the batch a drilldown was opened from
 }
 
 var tmpl *template.Template
 
+// assertions holds the site-specific CSS selector checks applied to every analysis.
+var assertions []parser.Assertion
+
 // LoadTemplates loads HTML templates from disk and sets the global tmpl variable.
 // This should be called once during application startup to parse templates.
 func LoadTemplates(path string) {
@@ -36,80 +59,173 @@ func LoadTemplates(path string) {
 	slog.Info("Templates loaded successfully", "path", path)
 }
 
+// LoadAssertions loads the declarative assertions config from disk.
+// Like LoadTemplates, it panics on error so that a broken config fails startup.
+func LoadAssertions(path string) {
+	loaded, err := parser.LoadAssertions(path)
+	if err != nil {
+		panic(err)
+	}
+	assertions = loaded
+}
+
+// maxUploadSize caps the size of pasted or uploaded HTML documents.
+const maxUploadSize = 10 << 20 // 10 MiB
+
+// readUpload returns the HTML uploaded as a file, falling back to the pasted HTML field.
+func readUpload(r *http.Request) ([]byte, error) {
+	file, _, err := r.FormFile("html_file")
+	switch {
+	case err == nil:
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, maxUploadSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxUploadSize {
+			return nil, fmt.Errorf("file exceeds %d bytes", maxUploadSize)
+		}
+		return data, nil
+	case errors.Is(err, http.ErrMissingFile), errors.Is(err, http.ErrNotMultipart):
+		return []byte(r.FormValue("html")), nil
+	default:
+		return nil, err
+	}
+}
+
+// uploadOptions configures an analysis of uploaded HTML and returns the URL to analyze.
+// Without a base URL, links can only be classified, so link checks are skipped.
+func uploadOptions(html []byte, baseURL string, checkLinks bool, opts parser.Options) (string, parser.Options) {
+	opts.Fetcher = &parser.RawFetcher{HTML: html}
+	opts.Render = false // The browser would load the base URL, not the uploaded document
+	opts.SkipLinkChecks = !checkLinks || baseURL == ""
+	if baseURL == "" {
+		baseURL = parser.UploadBaseURL
+	}
+	return baseURL, opts
+}
+
 // HomeHandler serves the initial home page with the URL input form.
-// Tracks request count and duration metrics for the "/" endpoint.
 func HomeHandler(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	// Observe and record request duration for metrics
-	defer metrics.RequestDuration.WithLabelValues("/", r.Method).Observe(time.Since(start).Seconds())
-	// Increment request count metric for monitoring
-	metrics.RequestCount.WithLabelValues("/", r.Method).Inc()
-
-	slog.Debug("Serving home page")
+	logging.FromContext(r.Context()).Debug("Serving home page")
 
 	// Render template with empty PageData (no results or errors yet)
 	if err := tmpl.Execute(w, PageData{}); err != nil {
 		// Log template rendering errors with details
-		slog.Error("Failed to render home page template", "error", err)
+		logging.FromContext(r.Context()).Error("Failed to render home page template", "error", err)
 	}
 }
 
 // AnalyzeHandler processes the submitted URL from the form and returns analysis results.
-// Tracks metrics, validates input, handles errors, and renders results or error messages.
+// Validates input, handles errors, and renders results or error messages.
 func AnalyzeHandler(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	// Track duration of /analyze requests for metrics
-	defer metrics.RequestDuration.WithLabelValues("/analyze", r.Method).Observe(time.Since(start).Seconds())
-	metrics.RequestCount.WithLabelValues("/analyze", r.Method).Inc()
-
-	slog.Debug("AnalyzeHandler invoked", "method", r.Method)
+	logging.FromContext(r.Context()).Debug("AnalyzeHandler invoked", "method", r.Method)
 
 	// Only allow POST method for analysis submission
 	if r.Method != http.MethodPost {
-		slog.Warn("Invalid HTTP method for analyze endpoint", "method", r.Method)
+		logging.FromContext(r.Context()).Warn("Invalid HTTP method for analyze endpoint", "method", r.Method)
 		http.Redirect(w, r, "/", http.StatusSeeOther) // Redirect GET or other methods back to home page
 		return
 	}
 
-	// Extract submitted URL from form data
-	url := r.FormValue("url")
-	if url == "" {
-		slog.Warn("No URL provided in form submission")
-		// Render page with error message about missing URL
-		if err := tmpl.Execute(w, PageData{Error: "URL is required"}); err != nil {
-			slog.Error("Failed to render error message template", "error", err)
+	// Bound the request body so oversized uploads fail early; leave room for the other form fields
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize+1<<20)
+
+	// Rendering in a headless browser is opt-in through the form checkbox
+	opts := parser.Options{
+		Assertions:   assertions,
+		Render:       r.FormValue("render") != "",
+		WaitSelector: r.FormValue("wait_selector"),
+	}
+
+	// Extract the submitted URL, or the uploaded HTML and its optional base URL
+	var url string
+	if r.FormValue("mode") == "html" {
+		html, err := readUpload(r)
+		if err != nil || len(html) == 0 {
+			msg := "HTML is required: paste a document or choose a file"
+			if err != nil {
+				logging.FromContext(r.Context()).Warn("Failed to read uploaded HTML", "error", err)
+				msg = "Unable to read uploaded HTML: " + err.Error()
+			}
+			if err := tmpl.Execute(w, PageData{Error: msg}); err != nil {
+				logging.FromContext(r.Context()).Error("Failed to render error message template", "error", err)
+			}
+			return
+		}
+		url, opts = uploadOptions(html, r.FormValue("base_url"), r.FormValue("check_links") != "", opts)
+	} else {
+		url = r.FormValue("url")
+		if url == "" {
+			logging.FromContext(r.Context()).Warn("No URL provided in form submission")
+			// Render page with error message about missing URL
+			if err := tmpl.Execute(w, PageData{Error: "URL is required"}); err != nil {
+				logging.FromContext(r.Context()).Error("Failed to render error message template", "error", err)
+			}
+			return
 		}
+	}
+
+	// Valid submissions count against the API key's daily quota
+	if !chargeAnalyses(w, r, 1) {
 		return
 	}
 
-	slog.Info("Starting page analysis", "url", url)
+	logging.FromContext(r.Context()).Info("Starting page analysis", "url", url, "render", opts.Render)
 
 	// Call parser package to analyze the given URL
-	analysis, err := parser.AnalyzePage(url)
+	analysis, err := parser.AnalyzePage(r.Context(), url, opts)
 	if err != nil {
-		slog.Error("Page analysis failed", "url", url, "error", err)
+		logging.FromContext(r.Context()).Error("Page analysis failed", "url", url, "error", err)
 		// Render page showing error to user
 		if err := tmpl.Execute(w, PageData{Error: err.Error()}); err != nil {
-			slog.Error("Failed to render error page after analysis failure", "error", err)
+			logging.FromContext(r.Context()).Error("Failed to render error page after analysis failure", "error", err)
 		}
 		return
 	}
 
-	slog.Info("Page analysis successful", "url", url)
+	logging.FromContext(r.Context()).Info("Page analysis successful", "url", url)
+
+	// Render results page with analysis data
+	if err := tmpl.Execute(w, PageData{Result: newResultData(r, analysis, opts.Fetcher != nil)}); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to render analysis result template", "error", err)
+	}
+}
+
+// newResultData prepares an analysis result for rendering in the template.
+func newResultData(r *http.Request, analysis *parser.AnalysisResult, uploaded bool) *ResultData {
+	// The placeholder base of uploads without a base URL is not worth showing.
+	// The result is copied because it may be shared with the page cache.
+	shown := *analysis
+	if shown.URL == parser.UploadBaseURL {
+		shown.URL = ""
+	}
+
+	// The export buttons post the result back, so reports need no second analysis
+	export, err := json.Marshal(&shown)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to encode analysis result for export", "error", err)
+	}
 
-	// Prepare the results for rendering in template
-	data := &ResultData{
+	return &ResultData{
+		URL:               shown.URL,
+		DisplayURL:        shown.DisplayURL,
+		Uploaded:          uploaded,
 		HTMLVersion:       analysis.HTMLVersion,
 		Title:             analysis.Title,
-		Headings:          analysis.Headings,
+		Outline:           analysis.Outline,
+		OutlineIssues:     analysis.OutlineIssues,
 		InternalLinks:     analysis.InternalLinks,
 		ExternalLinks:     analysis.ExternalLinks,
 		InaccessibleLinks: analysis.InaccessibleLinks,
+		MissingAnchors:    analysis.MissingAnchors,
+		OtherLinks:        analysis.OtherLinks,
+		RelLinks:          analysis.RelLinks,
 		LoginForm:         analysis.LoginForm,
-	}
-
-	// Render results page with analysis data
-	if err := tmpl.Execute(w, PageData{Result: data}); err != nil {
-		slog.Error("Failed to render analysis result template", "error", err)
+		Assertions:        analysis.Assertions,
+		Mode:              analysis.Mode,
+		RenderDiff:        analysis.RenderDiff,
+		InsecureTLS:       analysis.InsecureTLS,
+		Export:            string(export),
 	}
 }
@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"html/template"
 	"lucytech/parser"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -17,6 +20,12 @@ func init() {
 	tmpl = template.Must(template.New("index").Parse(`
 		{{if .Error}}Error: {{.Error}}{{else}}Title: {{.Result.Title}}{{end}}
 	`))
+	// The batch template lists the rows of a batch summary and the pages of a crawl
+	batchTmpl = template.Must(template.New("batch").Parse(`
+		{{if .Error}}Error: {{.Error}}{{end}}{{range .Rows}}Row: {{.URL}} {{.Status}}
+		{{end}}{{range .CrawlPages}}Page: {{.URL}}
+		{{end}}
+	`))
 }
 
 // TestHomeHandler checks that the HomeHandler returns a successful HTTP 200 status
@@ -73,11 +82,11 @@ func TestAnalyzeHandler_EmptyURL(t *testing.T) {
 // TestAnalyzeHandler_ValidURL tests the handler behavior on a valid URL input with mocked parser
 func TestAnalyzeHandler_ValidURL(t *testing.T) {
 	// Mock the AnalyzePage function in parser package to return a fixed result without making HTTP calls
-	parser.AnalyzePage = func(url string) (*parser.AnalysisResult, error) {
+	parser.AnalyzePage = func(ctx context.Context, url string, opts parser.Options) (*parser.AnalysisResult, error) {
 		return &parser.AnalysisResult{
 			HTMLVersion:       "HTML5",
 			Title:             "Test Title",
-			Headings:          map[string]int{"h1": 1},
+			Outline:           []*parser.Heading{{Level: 1, Text: "Test Heading", Position: 1}},
 			InternalLinks:     2,
 			ExternalLinks:     3,
 			InaccessibleLinks: 0,
@@ -104,7 +113,7 @@ func TestAnalyzeHandler_ValidURL(t *testing.T) {
 // TestAnalyzeHandler_ErrorFromParser verifies the handler handles parser errors gracefully
 func TestAnalyzeHandler_ErrorFromParser(t *testing.T) {
 	// Mock AnalyzePage to return an error simulating a failure in parsing the URL
-	parser.AnalyzePage = func(url string) (*parser.AnalysisResult, error) {
+	parser.AnalyzePage = func(ctx context.Context, url string, opts parser.Options) (*parser.AnalysisResult, error) {
 		return nil, errors.New("mock parse error")
 	}
 
@@ -123,3 +132,184 @@ func TestAnalyzeHandler_ErrorFromParser(t *testing.T) {
 		t.Errorf("expected mock parse error, got %s", w.Body.String())
 	}
 }
+
+// TestAPIAnalyzeHandler verifies the JSON API returns the analysis result as JSON
+func TestAPIAnalyzeHandler(t *testing.T) {
+	// Mock AnalyzePage and capture the assertions passed by the handler
+	var gotAssertions []parser.Assertion
+	parser.AnalyzePage = func(ctx context.Context, url string, opts parser.Options) (*parser.AnalysisResult, error) {
+		gotAssertions = opts.Assertions
+		return &parser.AnalysisResult{Title: "API Title"}, nil
+	}
+
+	body := `{"url": "http://example.com", "assertions": [{"selector": "h1", "expect": "exists"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	APIAnalyzeHandler(w, req)
+
+	// Expect a JSON response containing the mocked title
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON content type, got %q", ct)
+	}
+	if !strings.Contains(w.Body.String(), `"title":"API Title"`) {
+		t.Errorf("expected title in JSON body, got %s", w.Body.String())
+	}
+	// The request assertion must be forwarded to the analyzer
+	if len(gotAssertions) != 1 || gotAssertions[0].Selector != "h1" {
+		t.Errorf("expected request assertion to be forwarded, got %+v", gotAssertions)
+	}
+}
+
+// TestAPIAnalyzeHandler_Accept checks that CI formats are served for their Accept types
+func TestAPIAnalyzeHandler_Accept(t *testing.T) {
+	parser.AnalyzePage = func(ctx context.Context, url string, opts parser.Options) (*parser.AnalysisResult, error) {
+		return &parser.AnalysisResult{URL: url, Links: []parser.LinkResult{{URL: url + "/gone", Internal: true, Checked: true, StatusCode: 404}}}, nil
+	}
+
+	for _, tc := range []struct{ accept, contentType, body string }{
+		{"application/sarif+json", "application/sarif+json", `"ruleId": "broken-link"`},
+		{"application/junit+xml", "application/xml; charset=utf-8", `<failure type="broken-link"`},
+		{"application/json;q=0.5, application/sarif+json;q=0.9", "application/sarif+json", `"version": "2.1.0"`},
+		{"text/html, */*", "application/json", `"url":"http://example.com"`},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(`{"url": "http://example.com"}`))
+		req.Header.Set("Accept", tc.accept)
+		w := httptest.NewRecorder()
+		APIAnalyzeHandler(w, req)
+
+		if ct := w.Header().Get("Content-Type"); w.Code != http.StatusOK || ct != tc.contentType {
+			t.Errorf("Accept %q: status %d, Content-Type %q; want 200, %q", tc.accept, w.Code, ct, tc.contentType)
+		}
+		if !strings.Contains(w.Body.String(), tc.body) {
+			t.Errorf("Accept %q: body lacks %q:\n%s", tc.accept, tc.body, w.Body)
+		}
+	}
+}
+
+// TestAPIAnalyzeHandler_WARC checks that archive lookups are never rendered, which would load the live site
+func TestAPIAnalyzeHandler_WARC(t *testing.T) {
+	var calls int
+	var gotOpts parser.Options
+	parser.AnalyzePage = func(ctx context.Context, url string, opts parser.Options) (*parser.AnalysisResult, error) {
+		calls++
+		gotOpts = opts
+		return &parser.AnalysisResult{URL: url}, nil
+	}
+
+	for _, body := range []string{
+		`{"url": "http://example.com", "warc": "crawl.warc.gz", "render": true}`,
+		`{"url": "http://example.com", "warc": "crawl.warc.gz", "wait_selector": "#app"}`,
+	} {
+		w := httptest.NewRecorder()
+		APIAnalyzeHandler(w, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest || calls != 0 {
+			t.Errorf("%s: status %d, %d analyses; want 400 without analysis", body, w.Code, calls)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	APIAnalyzeHandler(w, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(`{"url": "http://example.com", "warc": "crawl.warc.gz"}`)))
+	if w.Code != http.StatusOK || calls != 1 {
+		t.Fatalf("status %d, %d analyses; want 200 and one analysis", w.Code, calls)
+	}
+	if f, ok := gotOpts.Fetcher.(*parser.WARCFetcher); !ok || f.Path != "crawl.warc.gz" || gotOpts.Render || gotOpts.WaitSelector != "" {
+		t.Errorf("options = %+v; want the archive fetcher without rendering", gotOpts)
+	}
+}
+
+// TestAPIAnalyzeHandler_InvalidSelector checks that broken selectors are rejected with 400
+func TestAPIAnalyzeHandler_InvalidSelector(t *testing.T) {
+	body := `{"url": "http://example.com", "assertions": [{"selector": "h1[", "expect": "exists"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	APIAnalyzeHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+// TestAnalyzeHandler_UploadedHTML checks that an uploaded file is analyzed without fetching a URL
+func TestAnalyzeHandler_UploadedHTML(t *testing.T) {
+	// Capture the options passed to the analyzer
+	var gotURL string
+	var gotOpts parser.Options
+	parser.AnalyzePage = func(ctx context.Context, url string, opts parser.Options) (*parser.AnalysisResult, error) {
+		gotURL, gotOpts = url, opts
+		return &parser.AnalysisResult{URL: url, Title: "Uploaded Title"}, nil
+	}
+
+	// Build a multipart form with the HTML file and no base URL
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("mode", "html")
+	mw.WriteField("check_links", "1")
+	fw, _ := mw.CreateFormFile("html_file", "index.html")
+	fw.Write([]byte("<title>Uploaded Title</title>"))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/analyze", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	AnalyzeHandler(w, req)
+
+	if !strings.Contains(w.Body.String(), "Uploaded Title") {
+		t.Errorf("expected analysis result title, got %s", w.Body.String())
+	}
+	// Without a base URL, links are resolved against the placeholder and never checked
+	if gotURL != parser.UploadBaseURL {
+		t.Errorf("expected placeholder base URL, got %q", gotURL)
+	}
+	if !gotOpts.SkipLinkChecks {
+		t.Error("expected link checks to be skipped without a base URL")
+	}
+	if f, ok := gotOpts.Fetcher.(*parser.RawFetcher); !ok || string(f.HTML) != "<title>Uploaded Title</title>" {
+		t.Errorf("expected raw fetcher with uploaded HTML, got %#v", gotOpts.Fetcher)
+	}
+}
+
+// TestAnalyzeHandler_PastedHTMLWithBase checks that a base URL enables link checks for pasted HTML
+func TestAnalyzeHandler_PastedHTMLWithBase(t *testing.T) {
+	var gotURL string
+	var gotOpts parser.Options
+	parser.AnalyzePage = func(ctx context.Context, url string, opts parser.Options) (*parser.AnalysisResult, error) {
+		gotURL, gotOpts = url, opts
+		return &parser.AnalysisResult{URL: url, Title: "Pasted"}, nil
+	}
+
+	form := url.Values{}
+	form.Set("mode", "html")
+	form.Set("html", "<title>Pasted</title>")
+	form.Set("base_url", "https://staging.example.com/")
+	form.Set("check_links", "1")
+	req := httptest.NewRequest(http.MethodPost, "/analyze", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	AnalyzeHandler(w, req)
+
+	if gotURL != "https://staging.example.com/" || gotOpts.SkipLinkChecks {
+		t.Errorf("got url %q, SkipLinkChecks %v; want base URL with link checks", gotURL, gotOpts.SkipLinkChecks)
+	}
+}
+
+// TestAnalyzeHandler_EmptyHTML checks that the HTML mode requires a document
+func TestAnalyzeHandler_EmptyHTML(t *testing.T) {
+	form := url.Values{}
+	form.Set("mode", "html")
+	req := httptest.NewRequest(http.MethodPost, "/analyze", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	AnalyzeHandler(w, req)
+
+	if !strings.Contains(w.Body.String(), "HTML is required") {
+		t.Errorf("expected error for missing HTML, got %s", w.Body.String())
+	}
+}
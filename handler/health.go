@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"lucytech/logging"
+	"lucytech/version"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout bounds how long all readiness checks may take together.
+const readinessTimeout = 2 * time.Second
+
+// readinessCheck is a named dependency that must be available before traffic is accepted.
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// readinessChecks are evaluated by ReadyzHandler in registration order.
+// The templates check is built in; other components register theirs at startup.
+var readinessChecks = []readinessCheck{{name: "templates", check: templatesLoaded}}
+
+// RegisterReadinessCheck adds a dependency to /readyz. check should return an error
+// while the dependency cannot serve requests. It must be called before serving starts.
+func RegisterReadinessCheck(name string, check func(ctx context.Context) error) {
+	readinessChecks = append(readinessChecks, readinessCheck{name: name, check: check})
+}
+
+// templatesLoaded reports whether LoadTemplates and LoadBatchTemplate have run.
+func templatesLoaded(context.Context) error {
+	if tmpl == nil || batchTmpl == nil {
+		return errors.New("templates not loaded")
+	}
+	return nil
+}
+
+// HealthResponse is the JSON body returned by the health and readiness endpoints.
+type HealthResponse struct {
+	Status string            `json:"status"`           // "ok", "ready" or "not ready"
+	Checks map[string]string `json:"checks,omitempty"` // Outcome of each readiness check, "ok" or the error
+}
+
+// HealthzHandler reports liveness: the process is up and serving HTTP.
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// ReadyzHandler reports readiness: every registered check must pass, otherwise
+// it answers 503 so that the orchestrator stops routing traffic to the instance.
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	resp := HealthResponse{Status: "ready", Checks: make(map[string]string, len(readinessChecks))}
+	status := http.StatusOK
+	for _, c := range readinessChecks {
+		if err := c.check(ctx); err != nil {
+			logging.FromContext(r.Context()).Warn("Readiness check failed", "check", c.name, "error", err)
+			resp.Checks[c.name] = err.Error()
+			resp.Status = "not ready"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[c.name] = "ok"
+	}
+	writeJSON(w, status, resp)
+}
+
+// VersionHandler reports the build version, commit and Go version.
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, version.Get())
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"lucytech/version"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+// TestHealthzHandler checks that liveness always reports ok
+func TestHealthzHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	HealthzHandler(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+}
+
+// TestReadyzHandler checks that a failing dependency makes the instance unready
+func TestReadyzHandler(t *testing.T) {
+	origChecks := readinessChecks
+	defer func() { readinessChecks = origChecks }()
+
+	// Templates are loaded by init() in handler_test.go
+	w := httptest.NewRecorder()
+	ReadyzHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d: %s", w.Code, w.Body)
+	}
+
+	RegisterReadinessCheck("storage", func(context.Context) error { return errors.New("connection refused") })
+	w = httptest.NewRecorder()
+	ReadyzHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+	var resp HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Checks["storage"] != "connection refused" || resp.Checks["templates"] != "ok" {
+		t.Errorf("checks = %v; want failing storage and passing templates", resp.Checks)
+	}
+}
+
+// TestVersionHandler checks the reported build metadata
+func TestVersionHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	VersionHandler(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	var info version.Info
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if info.Version != version.Version || info.GoVersion != runtime.Version() || info.Commit == "" {
+		t.Errorf("version = %+v; want version %q and Go %s with a commit", info, version.Version, runtime.Version())
+	}
+}
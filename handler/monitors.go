@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"lucytech/logging"
+	"lucytech/monitor"
+	"net/http"
+	"time"
+)
+
+// monitors schedules the registered monitors; nil disables monitoring.
+var monitors *monitor.Scheduler
+
+// ConfigureMonitoring enables the monitors admin endpoint and makes the monitor
+// store a readiness check.
+func ConfigureMonitoring(s *monitor.Scheduler) {
+	monitors = s
+	RegisterReadinessCheck("storage", func(context.Context) error {
+		return s.Store().Ping()
+	})
+}
+
+// MonitorInfo describes a monitor with its schedule state.
+type MonitorInfo struct {
+	monitor.Monitor
+	NextRun *time.Time   `json:"next_run,omitempty"` // When the monitor runs next
+	LastRun *monitor.Run `json:"last_run,omitempty"` // Most recent run, if any
+}
+
+// MonitorHistory is the response for a single monitor: its details and stored runs.
+type MonitorHistory struct {
+	MonitorInfo
+	Runs []monitor.Run `json:"runs"` // Newest first
+}
+
+// MonitorsHandler manages scheduled monitors. GET lists them, or with an "id" query
+// parameter returns one monitor with its run history; POST registers a monitor from
+// a JSON body; DELETE removes the monitor given by "id".
+func MonitorsHandler(w http.ResponseWriter, r *http.Request) {
+	if monitors == nil {
+		writeJSON(w, http.StatusNotFound, APIError{Error: "monitoring is disabled"})
+		return
+	}
+	store := monitors.Store()
+	id := r.URL.Query().Get("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		if id == "" {
+			list := store.Monitors()
+			infos := make([]MonitorInfo, 0, len(list))
+			for _, m := range list {
+				infos = append(infos, monitorInfo(m))
+			}
+			writeJSON(w, http.StatusOK, infos)
+			return
+		}
+		m, err := store.Monitor(id)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, APIError{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, MonitorHistory{MonitorInfo: monitorInfo(m), Runs: store.Runs(id)})
+	case http.MethodPost:
+		var m monitor.Monitor
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&m); err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{Error: "invalid JSON body: " + err.Error()})
+			return
+		}
+		m, err := monitors.Add(m)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{Error: err.Error()})
+			return
+		}
+		logging.FromContext(r.Context()).Info("Monitor added", "monitor_id", m.ID, "url", m.URL, "schedule", m.Schedule)
+		writeJSON(w, http.StatusCreated, monitorInfo(m))
+	case http.MethodDelete:
+		if err := monitors.Remove(id); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, monitor.ErrMonitorNotFound) {
+				status = http.StatusNotFound
+			}
+			writeJSON(w, status, APIError{Error: err.Error()})
+			return
+		}
+		logging.FromContext(r.Context()).Info("Monitor removed", "monitor_id", id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{Error: "method not allowed"})
+	}
+}
+
+// monitorInfo adds the schedule state to a monitor.
+func monitorInfo(m monitor.Monitor) MonitorInfo {
+	info := MonitorInfo{Monitor: m, LastRun: monitors.Store().LastRun(m.ID)}
+	if next, ok := monitors.NextRun(m.ID); ok {
+		info.NextRun = &next
+	}
+	return info
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"lucytech/monitor"
+	"lucytech/parser"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestMonitorsHandler covers adding, inspecting and removing a monitor
+func TestMonitorsHandler(t *testing.T) {
+	store, _ := monitor.OpenStore("")
+	s := monitor.NewScheduler(store, nil)
+	s.Analyze = func(ctx context.Context, rawURL string, opts parser.Options) (*parser.AnalysisResult, error) {
+		return &parser.AnalysisResult{Title: "Home"}, nil
+	}
+	origChecks := readinessChecks
+	ConfigureMonitoring(s)
+	defer func() { monitors, readinessChecks = nil, origChecks }()
+
+	w := httptest.NewRecorder()
+	MonitorsHandler(w, httptest.NewRequest(http.MethodPost, "/admin/monitors",
+		strings.NewReader(`{"url": "https://example.com", "schedule": "@every 10m", "rules": ["inaccessible_links > 0"]}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST: status %d: %s", w.Code, w.Body)
+	}
+	var created MonitorInfo
+	json.NewDecoder(w.Body).Decode(&created)
+
+	if _, err := s.Run(context.Background(), created.ID); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	MonitorsHandler(w, httptest.NewRequest(http.MethodGet, "/admin/monitors?id="+created.ID, nil))
+	var history MonitorHistory
+	if err := json.NewDecoder(w.Body).Decode(&history); err != nil || len(history.Runs) != 1 || history.LastRun == nil {
+		t.Errorf("GET by id = %+v, %v; want one run", history, err)
+	}
+
+	// Invalid rules are rejected
+	w = httptest.NewRecorder()
+	MonitorsHandler(w, httptest.NewRequest(http.MethodPost, "/admin/monitors",
+		strings.NewReader(`{"url": "https://example.com", "schedule": "@hourly", "rules": ["title > 1"]}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("POST invalid rule: status %d; want 400", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	MonitorsHandler(w, httptest.NewRequest(http.MethodDelete, "/admin/monitors?id="+created.ID, nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("DELETE: status %d; want 204", w.Code)
+	}
+	w = httptest.NewRecorder()
+	MonitorsHandler(w, httptest.NewRequest(http.MethodGet, "/admin/monitors?id="+created.ID, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET removed: status %d; want 404", w.Code)
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"lucytech/logging"
+	"lucytech/metrics"
+	"lucytech/throttle"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+// overloadRetryAfter is the Retry-After sent when analyses are turned away because
+// the server is busy. Slots typically free up within a few seconds.
+const overloadRetryAfter = 5 * time.Second
+
+// analysisGate caps concurrent analyses; nil means no cap.
+var analysisGate *throttle.Gate
+
+// clientLimiter rate-limits clients by IP address; nil means no limit.
+var clientLimiter *throttle.IPLimiter
+
+// trustedProxies are the proxies whose forwarding headers identify the client.
+var trustedProxies []netip.Prefix
+
+// ConfigureThrottling sets up overload protection for the analysis routes. Either of
+// gate and limiter may be nil to disable it. A gate also becomes a readiness check,
+// so that a saturated instance stops receiving traffic.
+func ConfigureThrottling(gate *throttle.Gate, limiter *throttle.IPLimiter, trusted []netip.Prefix) {
+	analysisGate, clientLimiter, trustedProxies = gate, limiter, trusted
+	if gate != nil {
+		RegisterReadinessCheck("queue", func(context.Context) error {
+			if gate.Saturated() {
+				return errors.New("analysis queue is full")
+			}
+			return nil
+		})
+	}
+}
+
+// RateLimitClients rejects requests from clients that exceed their per-IP rate with
+// 429 and a Retry-After header. The client IP is added to the request's logger.
+func RateLimitClients(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := throttle.ClientIP(r, trustedProxies)
+		logger := logging.FromContext(r.Context()).With("client_ip", ip)
+		r = r.WithContext(logging.WithLogger(r.Context(), logger))
+
+		if clientLimiter != nil {
+			if ok, retryAfter := clientLimiter.Allow(ip); !ok {
+				metrics.ThrottledRequests.WithLabelValues("rate_limited").Inc()
+				logger.Warn("Rejected request over client rate limit")
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+				writeError(w, r, http.StatusTooManyRequests, "Too many requests from your address, please slow down")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LimitConcurrency runs the request only once it holds an analysis slot. Requests that
+// find the queue full or wait too long are rejected with 503 and a Retry-After header.
+func LimitConcurrency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only submissions start analyses
+		if analysisGate == nil || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		release, err := analysisGate.Acquire(r.Context())
+		if err != nil {
+			reason := "queue_timeout"
+			if errors.Is(err, throttle.ErrQueueFull) {
+				reason = "queue_full"
+			}
+			if r.Context().Err() == nil {
+				metrics.ThrottledRequests.WithLabelValues(reason).Inc()
+			}
+			logging.FromContext(r.Context()).Warn("Rejected analysis, server overloaded", "reason", reason, "error", err)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(overloadRetryAfter)))
+			writeError(w, r, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		defer release()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"lucytech/throttle"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withThrottling enables overload protection for the duration of a test
+func withThrottling(t *testing.T, gate *throttle.Gate, limiter *throttle.IPLimiter) {
+	t.Helper()
+	origChecks := readinessChecks
+	ConfigureThrottling(gate, limiter, nil)
+	t.Cleanup(func() {
+		analysisGate, clientLimiter, trustedProxies = nil, nil, nil
+		readinessChecks = origChecks
+	})
+}
+
+// TestRateLimitClients checks the 429 response in both the JSON API and the page
+func TestRateLimitClients(t *testing.T) {
+	withThrottling(t, nil, throttle.NewIPLimiter(1, 1))
+	h := RateLimitClients(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, path := range []string{"/api/analyze", "/analyze"} {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.RemoteAddr = "203.0.113.5:1234"
+		h.ServeHTTP(httptest.NewRecorder(), req) // Uses the only token on the first path
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: status %d, Retry-After %q; want 429 with Retry-After", path, w.Code, w.Header().Get("Retry-After"))
+		}
+		if isJSON := strings.HasPrefix(w.Header().Get("Content-Type"), "application/json"); isJSON != (path == "/api/analyze") {
+			t.Errorf("%s: Content-Type = %q", path, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+// TestLimitConcurrency checks that a busy server answers 503 and reports unready
+func TestLimitConcurrency(t *testing.T) {
+	gate := throttle.NewGate(1, 0, 10*time.Millisecond)
+	withThrottling(t, gate, nil)
+
+	// Occupy the only slot
+	release, err := gate.Acquire(t.Context())
+	if err != nil {
+		t.Fatalf("Acquire returned error: %v", err)
+	}
+	defer release()
+
+	h := LimitConcurrency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/analyze", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("status %d, Retry-After %q; want 503 with Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	w = httptest.NewRecorder()
+	ReadyzHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "analysis queue is full") {
+		t.Errorf("readyz: status %d body %q; want 503 naming the queue", w.Code, w.Body)
+	}
+}
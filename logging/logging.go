@@ -0,0 +1,109 @@
+// Package logging configures the structured logger and carries request-scoped
+// loggers through contexts, so that log lines can be correlated with the request
+// that caused them.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync/atomic"
+)
+
+// Supported log output formats.
+const (
+	FormatText = "text" // key=value lines, easy to read in a terminal
+	FormatJSON = "json" // One JSON object per line, for log shippers
+)
+
+// useJSON selects the output format of every logger created by Init. It is read on
+// each log call, so the format can be switched while the application is running.
+var useJSON atomic.Bool
+
+// switchHandler writes records with either the text or the JSON handler, depending on
+// the current format. Attributes and groups are applied to both, so loggers derived
+// with With keep working after a switch.
+type switchHandler struct {
+	text, json slog.Handler
+}
+
+// current returns the handler for the selected format.
+func (h *switchHandler) current() slog.Handler {
+	if useJSON.Load() {
+		return h.json
+	}
+	return h.text
+}
+
+// Enabled implements slog.Handler.
+func (h *switchHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.current().Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *switchHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.current().Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *switchHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &switchHandler{text: h.text.WithAttrs(attrs), json: h.json.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler.
+func (h *switchHandler) WithGroup(name string) slog.Handler {
+	return &switchHandler{text: h.text.WithGroup(name), json: h.json.WithGroup(name)}
+}
+
+// Init installs the default logger writing info-level records to w in the given format.
+func Init(w io.Writer, format string) error {
+	if err := SetFormat(format); err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{
+		Level: slog.LevelInfo, // Only logs info and above (info, warn, error)
+	}
+	slog.SetDefault(slog.New(&switchHandler{
+		text: slog.NewTextHandler(w, opts),
+		json: slog.NewJSONHandler(w, opts),
+	}))
+	return nil
+}
+
+// SetFormat switches the output format of all loggers created by Init.
+func SetFormat(format string) error {
+	switch format {
+	case FormatText:
+		useJSON.Store(false)
+	case FormatJSON:
+		useJSON.Store(true)
+	default:
+		return fmt.Errorf("unknown log format %q (want %s or %s)", format, FormatText, FormatJSON)
+	}
+	return nil
+}
+
+// Format returns the current output format.
+func Format() string {
+	if useJSON.Load() {
+		return FormatJSON
+	}
+	return FormatText
+}
+
+// ctxKey is the context key under which the request-scoped logger is stored.
+type ctxKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the logger stored in ctx, or the default logger if there is none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
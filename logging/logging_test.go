@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// TestSetFormat checks that derived loggers follow a runtime format switch
+func TestSetFormat(t *testing.T) {
+	origDefault := slog.Default()
+	defer func() {
+		slog.SetDefault(origDefault)
+		SetFormat(FormatText)
+	}()
+
+	var buf bytes.Buffer
+	if err := Init(&buf, FormatText); err != nil {
+		t.Fatalf("Init returned error: %v", err)
+	}
+	logger := slog.Default().With("request_id", "abc")
+
+	logger.Info("first")
+	if got := buf.String(); !strings.Contains(got, "msg=first request_id=abc") {
+		t.Errorf("text output = %q; want key=value line with request_id", got)
+	}
+
+	buf.Reset()
+	if err := SetFormat(FormatJSON); err != nil {
+		t.Fatalf("SetFormat returned error: %v", err)
+	}
+	logger.Info("second")
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("output %q is not JSON: %v", buf.String(), err)
+	}
+	if record["msg"] != "second" || record["request_id"] != "abc" {
+		t.Errorf("JSON record = %v; want msg second with request_id abc", record)
+	}
+
+	if err := SetFormat("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+// TestFromContext falls back to the default logger when the context has none
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("FromContext without logger should return the default logger")
+	}
+	logger := slog.New(slog.DiscardHandler)
+	if FromContext(WithLogger(context.Background(), logger)) != logger {
+		t.Error("FromContext should return the stored logger")
+	}
+}
@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the correlation ID of a request, in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the size of client-supplied request IDs.
+const maxRequestIDLength = 128
+
+// Middleware tags every request with a request ID, taken from the X-Request-ID header or
+// generated if absent or invalid. The ID is echoed in the response and attached, together
+// with the trace ID, to a logger stored in the request context for use by FromContext.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		logger := FromContext(r.Context()).With("request_id", id)
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			logger = logger.With("trace_id", sc.TraceID().String())
+		}
+		next.ServeHTTP(w, r.WithContext(WithLogger(r.Context(), logger)))
+	})
+}
+
+// validRequestID accepts non-empty IDs of printable ASCII, so that clients cannot inject
+// line breaks or huge values into the logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random 128-bit ID in hex.
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
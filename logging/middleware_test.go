@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveLogged runs a request through the middleware with a logger writing to buf
+// and returns the response and the request ID seen by the handler's logger.
+func serveLogged(t *testing.T, requestID string) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Info("handled")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if requestID != "" {
+		req.Header.Set(RequestIDHeader, requestID)
+	}
+	req = req.WithContext(WithLogger(req.Context(), slog.New(slog.NewTextHandler(&buf, nil))))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	_, logged, _ := strings.Cut(strings.TrimSpace(buf.String()), "request_id=")
+	return w, logged
+}
+
+// TestMiddleware_AcceptsRequestID checks that a valid client ID is echoed and logged
+func TestMiddleware_AcceptsRequestID(t *testing.T) {
+	w, logged := serveLogged(t, "client-42")
+	if got := w.Header().Get(RequestIDHeader); got != "client-42" {
+		t.Errorf("response %s = %q; want client-42", RequestIDHeader, got)
+	}
+	if logged != "client-42" {
+		t.Errorf("logged request_id = %q; want client-42", logged)
+	}
+}
+
+// TestMiddleware_GeneratesRequestID checks that missing and invalid IDs are replaced
+func TestMiddleware_GeneratesRequestID(t *testing.T) {
+	for _, id := range []string{"", "bad id\nwith newline", strings.Repeat("x", maxRequestIDLength+1)} {
+		w, logged := serveLogged(t, id)
+		got := w.Header().Get(RequestIDHeader)
+		if len(got) != 32 || got == id {
+			t.Errorf("request ID for %q = %q; want generated 32-char hex ID", id, got)
+		}
+		if logged != got {
+			t.Errorf("logged request_id = %q; want %q", logged, got)
+		}
+	}
+}
@@ -1,47 +1,246 @@
 package main
 
 import (
-	"log/slog"         // Structured logger
-	"lucytech/handler" // Custom package for request handlers
-	"lucytech/metrics" // Custom package for Prometheus metrics
-	"net/http"         // HTTP server
-	"os"               // For accessing stdout
+	"context"           // Flushing spans on shutdown
+	"flag"              // Command-line flags
+	"log/slog"          // Structured logger
+	"lucytech/auth"     // Custom package for API keys and quotas
+	"lucytech/batch"    // Custom package for batch analyses
+	"lucytech/handler"  // Custom package for request handlers
+	"lucytech/logging"  // Custom package for request-scoped logging
+	"lucytech/metrics"  // Custom package for Prometheus metrics
+	"lucytech/monitor"  // Custom package for scheduled monitoring and alerts
+	"lucytech/parser"   // Custom package for page analysis
+	"lucytech/throttle" // Custom package for overload protection
+	"lucytech/tracing"  // Custom package for OpenTelemetry tracing
+	"lucytech/version"  // Build metadata
+	"net/http"          // HTTP server
+	"os"                // For accessing stdout and exiting on startup errors
+	"time"              // Durations for cache and queue flags
 
 	"github.com/prometheus/client_golang/prometheus/promhttp" // Prometheus metrics
 )
 
-// initLogger initializes the structured logger using slog with info-level logging.
-func initLogger() {
-	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo, // Only logs info and above (info, warn, error)
-	})
-	slog.SetDefault(slog.New(handler)) // Set slog as the default logger
+// instrument wraps a public route with tracing, request-ID logging and request metrics.
+func instrument(path string, h http.Handler) http.Handler {
+	return tracing.Middleware(path, logging.Middleware(metrics.Middleware(path, h)))
+}
+
+// analysisRoute protects a route that starts analyses: clients are rate-limited by IP
+// before authentication, and only authenticated requests take a slot in the queue.
+func analysisRoute(path string, h http.HandlerFunc) http.Handler {
+	return instrument(path, handler.RateLimitClients(handler.RequireAPIKey(handler.LimitConcurrency(h))))
 }
 
-// main is the entry point of the application
+// main is the entry point of the application. "lucytech analyze URL..." analyzes
+// pages once for CI instead of starting the server.
 func main() {
-	initLogger() // Initialize logging
-	slog.Info("Logger initialized")
+	if len(os.Args) > 1 && os.Args[1] == "analyze" {
+		os.Exit(runCLI(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	assertionsPath := flag.String("assertions", "", "path to a JSON file with CSS selector assertions")
+	chromePath := flag.String("chrome", "", "path to the Chromium binary used for rendering mode (default: search PATH)")
+	sameSite := flag.String("same-site", parser.SiteHost, "links counted as internal: host, subdomains or domain (the registrable domain)")
+	sameSiteHosts := flag.String("same-site-hosts", "", "comma-separated further hosts counted as internal, e.g. cdn.example.net or *.example.org")
+	proxy := flag.String("proxy", "", "http, https or socks5 proxy URL for page fetches and link checks (default: HTTP_PROXY/HTTPS_PROXY)")
+	noProxy := flag.String("no-proxy", "", "comma-separated hosts, domains and CIDR ranges reached without the proxy (default: NO_PROXY)")
+	caFile := flag.String("ca-file", "", "PEM bundle of CA certificates to trust in addition to the system's")
+	clientCert := flag.String("client-cert", "", "PEM client certificate for mutual TLS")
+	clientKey := flag.String("client-key", "", "PEM private key of the client certificate")
+	insecure := flag.Bool("insecure-skip-verify", false, "do not verify TLS certificates; every result is flagged as insecure")
+	sortQuery := flag.Bool("sort-query", false, "sort query parameters by name when comparing and checking links")
+	stripTracking := flag.Bool("strip-tracking", false, "drop tracking query parameters such as utm_source, gclid and fbclid when comparing and checking links")
+	localRoot := flag.String("local-root", "", "directory that file:// URLs and WARC archives may be read from (default: disabled)")
+	pageTTL := flag.Duration("cache-ttl", 5*time.Minute, "how long page analysis results are cached before revalidation (0 disables)")
+	pageCacheSize := flag.Int("cache-size", 1000, "maximum number of cached page results (0 for no limit)")
+	linkTTL := flag.Duration("link-cache-ttl", 10*time.Minute, "how long link accessibility verdicts are cached (0 disables)")
+	linkNegativeTTL := flag.Duration("link-cache-negative-ttl", time.Minute, "how long failed link checks are cached")
+	linkCacheSize := flag.Int("link-cache-size", 10000, "maximum number of cached link verdicts (0 for no limit)")
+	traceExporter := flag.String("trace-exporter", tracing.ExporterNone, "where to export trace spans: none, stdout or otlp")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "OTLP/HTTP collector URL, e.g. http://localhost:4318 (default: OTEL_EXPORTER_OTLP_ENDPOINT or localhost)")
+	logFormat := flag.String("log-format", logging.FormatText, "log output format: text or json (switchable at runtime via /admin/log-format)")
+	apiKeysPath := flag.String("api-keys", "", "JSON file storing API keys; enables key authentication of /analyze and /api/analyze (default: disabled)")
+	rateLimit := flag.Float64("rate-limit", 60, "default requests per minute allowed per API key (0 for no limit)")
+	rateBurst := flag.Int("rate-burst", 10, "requests an API key may send at once before the rate limit applies")
+	dailyQuota := flag.Int("daily-quota", 0, "default analyses per UTC day allowed per API key (0 for no limit)")
+	maxAnalyses := flag.Int("max-analyses", 8, "maximum number of analyses running at once")
+	queueSize := flag.Int("queue-size", 32, "maximum number of analyses waiting for a free slot")
+	queueTimeout := flag.Duration("queue-timeout", 30*time.Second, "how long an analysis may wait for a free slot")
+	ipRateLimit := flag.Float64("ip-rate-limit", 30, "requests per minute allowed per client IP on the analysis routes (0 disables)")
+	ipBurst := flag.Int("ip-burst", 10, "requests a client IP may send at once before the rate limit applies")
+	trustedProxiesList := flag.String("trusted-proxies", "", "comma-separated IPs or CIDR ranges of proxies whose X-Forwarded-For/X-Real-IP headers are trusted")
+	monitorsPath := flag.String("monitors", "", "JSON file storing scheduled monitors and their runs; enables monitoring via /admin/monitors (default: disabled)")
+	alertSinksPath := flag.String("alert-sinks", "", "JSON file configuring the webhook and email sinks that monitor alerts are sent to")
+	batchParallelism := flag.Int("batch-parallelism", 4, "URLs analyzed at once per batch")
+	batchMaxURLs := flag.Int("batch-max-urls", 1000, "maximum number of URLs per batch")
+	maxBatches := flag.Int("max-batches", 4, "maximum number of batches running at once")
+	batchRetention := flag.Duration("batch-retention", time.Hour, "how long finished batches and their results are kept")
+	flag.Parse()
+
+	// Initialize logging to stdout
+	if err := logging.Init(os.Stdout, *logFormat); err != nil {
+		slog.Error("Failed to initialize logger", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Logger initialized", "format", *logFormat)
 
 	metrics.Init() // Register custom Prometheus metrics
-	slog.Info("Metrics initialized")
+	build := version.Get()
+	metrics.BuildInfo.WithLabelValues(build.Version, build.Commit, build.GoVersion).Set(1)
+	slog.Info("Metrics initialized", "version", build.Version, "commit", build.Commit)
+
+	// Set up distributed tracing; spans are flushed when main returns
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config{
+		Exporter:    *traceExporter,
+		Endpoint:    *otlpEndpoint,
+		ServiceName: "lucytech",
+	})
+	if err != nil {
+		slog.Error("Failed to initialize tracing", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+	slog.Info("Tracing initialized", "exporter", *traceExporter)
 
 	// Load the HTML template used by the handlers
 	handler.LoadTemplates("templates/index.html")
 	slog.Info("Templates loaded", "path", "templates/index.html")
+	handler.LoadBatchTemplate("templates/batch.html")
+
+	// Load the optional site-specific assertions applied to every analysis
+	if *assertionsPath != "" {
+		handler.LoadAssertions(*assertionsPath)
+	}
+
+	// Configure the headless browser used when an analysis asks for rendering
+	parser.Renderer = &parser.ChromeFetcher{ExecPath: *chromePath}
+
+	// Cache page results and link verdicts between analyses
+	parser.ConfigureCache(parser.CacheConfig{
+		PageTTL:         *pageTTL,
+		PageCacheSize:   *pageCacheSize,
+		LinkTTL:         *linkTTL,
+		LinkNegativeTTL: *linkNegativeTTL,
+		LinkCacheSize:   *linkCacheSize,
+	})
+
+	// Allow local build output and web archives to be analyzed from this directory only
+	parser.LocalRoot = *localRoot
+
+	// Reach sites behind proxies and with internal certificates
+	if err := parser.ConfigureTransport(parser.TransportConfig{
+		Proxy:              *proxy,
+		NoProxy:            *noProxy,
+		CAFile:             *caFile,
+		ClientCert:         *clientCert,
+		ClientKey:          *clientKey,
+		InsecureSkipVerify: *insecure,
+	}); err != nil {
+		slog.Error("Invalid HTTP transport settings", "error", err)
+		os.Exit(1)
+	}
+
+	// Decide which links count as internal and how links are compared
+	rules, err := parser.ParseSameSite(*sameSite, *sameSiteHosts)
+	if err != nil {
+		slog.Error("Invalid same-site rules", "error", err)
+		os.Exit(1)
+	}
+	parser.SameSiteRules = rules
+	parser.URLNormalizer = parser.Normalizer{SortQuery: *sortQuery, StripTracking: *stripTracking}
+
+	// Require API keys for analyses when a key store is configured
+	if *apiKeysPath != "" {
+		store, err := auth.OpenStore(*apiKeysPath)
+		if err != nil {
+			slog.Error("Failed to open API key store", "error", err)
+			os.Exit(1)
+		}
+		handler.ConfigureAuth(store, auth.LimitConfig{RateLimit: *rateLimit, Burst: *rateBurst, DailyQuota: *dailyQuota})
+		slog.Info("API key authentication enabled", "store", *apiKeysPath, "keys", len(store.List()))
+	}
+
+	// Protect the analysis routes from overload
+	trusted, err := throttle.ParseTrustedProxies(*trustedProxiesList)
+	if err != nil {
+		slog.Error("Invalid trusted proxies", "error", err)
+		os.Exit(1)
+	}
+	var clientLimiter *throttle.IPLimiter
+	if *ipRateLimit > 0 {
+		clientLimiter = throttle.NewIPLimiter(*ipRateLimit, *ipBurst)
+	}
+	handler.ConfigureThrottling(throttle.NewGate(max(*maxAnalyses, 1), *queueSize, *queueTimeout), clientLimiter, trusted)
 
-	// Start Prometheus metrics server in a separate goroutine
+	// Re-analyze registered monitors on their schedules and send alerts
+	if *monitorsPath != "" {
+		store, err := monitor.OpenStore(*monitorsPath)
+		if err != nil {
+			slog.Error("Failed to open monitor store", "error", err)
+			os.Exit(1)
+		}
+		var sinks map[string]monitor.Sink
+		if *alertSinksPath != "" {
+			if sinks, err = monitor.LoadSinks(*alertSinksPath); err != nil {
+				slog.Error("Failed to load alert sinks", "error", err)
+				os.Exit(1)
+			}
+		}
+		scheduler := monitor.NewScheduler(store, sinks)
+		handler.ConfigureMonitoring(scheduler)
+		scheduler.Start(context.Background())
+		slog.Info("Monitoring enabled", "store", *monitorsPath, "monitors", len(store.Monitors()), "sinks", len(sinks))
+	}
+
+	// Analyze lists of URLs in the background; batches bound their own parallelism
+	// instead of taking slots in the analysis queue, so they cannot starve single analyses
+	handler.ConfigureBatches(batch.NewManager(batch.Config{
+		Parallelism: *batchParallelism,
+		MaxURLs:     *batchMaxURLs,
+		MaxRunning:  *maxBatches,
+		Retention:   *batchRetention,
+	}))
+
+	// Start the internal metrics and admin server in a separate goroutine.
+	// It only listens on localhost, so operational endpoints are not exposed publicly.
 	go func() {
-		http.Handle("/metrics", promhttp.Handler()) // Metrics endpoint handler
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler()) // Metrics endpoint handler
+		// Inspect and purge cached link verdicts
+		mux.Handle("/admin/link-cache", metrics.Middleware("/admin/link-cache", http.HandlerFunc(handler.LinkCacheHandler)))
+		// Switch between text and JSON logs without a restart
+		mux.Handle("/admin/log-format", metrics.Middleware("/admin/log-format", http.HandlerFunc(handler.LogFormatHandler)))
+		// Create, list and revoke API keys
+		mux.Handle("/admin/api-keys", metrics.Middleware("/admin/api-keys", http.HandlerFunc(handler.APIKeysHandler)))
+		// Register, inspect and remove scheduled monitors
+		mux.Handle("/admin/monitors", metrics.Middleware("/admin/monitors", http.HandlerFunc(handler.MonitorsHandler)))
 		slog.Info("Starting metrics server", "addr", "localhost:6060/metrics")
-		if err := http.ListenAndServe("localhost:6060", nil); err != nil {
+		if err := http.ListenAndServe("localhost:6060", mux); err != nil {
 			slog.Error("Metrics server failed", "error", err)
 		}
 	}()
 
-	// Register the HTTP handlers for home and analyze routes
-	http.HandleFunc("/", handler.HomeHandler)
-	http.HandleFunc("/analyze", handler.AnalyzeHandler)
+	// Register the HTTP handlers for home and analyze routes, each traced, logged with a request ID and instrumented with request metrics.
+	// The analyze routes are also rate-limited, authenticated and queued.
+	http.Handle("/", instrument("/", http.HandlerFunc(handler.HomeHandler)))
+	http.Handle("/analyze", analysisRoute("/analyze", handler.AnalyzeHandler))
+	http.Handle("/api/analyze", analysisRoute("/api/analyze", handler.APIAnalyzeHandler))
+
+	// Submit and follow batches; each URL counts against the key's quota, and the
+	// batch manager bounds the analyses instead of the queue
+	http.Handle("/batch", instrument("/batch", handler.RateLimitClients(handler.RequireAPIKey(http.HandlerFunc(handler.BatchHandler)))))
+	http.Handle("/api/batch", instrument("/api/batch", handler.RateLimitClients(handler.RequireAPIKey(http.HandlerFunc(handler.APIBatchHandler)))))
+	http.Handle("/batch/graph", instrument("/batch/graph", handler.RateLimitClients(handler.RequireAPIKey(http.HandlerFunc(handler.BatchGraphHandler)))))
+
+	// Render results as CSV, JSON, Markdown, printable HTML or PDF; nothing is re-analyzed, so no key or queue slot is needed
+	http.Handle("/export", instrument("/export", http.HandlerFunc(handler.ExportHandler)))
+	http.Handle("/api/export", instrument("/api/export", http.HandlerFunc(handler.ExportHandler)))
+
+	// Probes for the orchestrator and build metadata; not traced to keep probe noise out of traces
+	http.Handle("/healthz", metrics.Middleware("/healthz", http.HandlerFunc(handler.HealthzHandler)))
+	http.Handle("/readyz", metrics.Middleware("/readyz", http.HandlerFunc(handler.ReadyzHandler)))
+	http.Handle("/version", metrics.Middleware("/version", http.HandlerFunc(handler.VersionHandler)))
 
 	// Start the main HTTP server
 	slog.Info("Starting application", "addr", ":8080")
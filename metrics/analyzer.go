@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Analyzer-level metrics. Labels never contain full URLs; domains are reduced to
+// their registrable domain so cardinality stays bounded.
+var (
+	FetchDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "analyzer_fetch_duration_seconds",
+			Help:    "Duration of page fetches by HTTP status class",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"status_class"},
+	)
+
+	FetchStatus = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "analyzer_fetch_responses_total",
+			Help: "Page fetches by HTTP status class (2xx, 3xx, 4xx, 5xx, error)",
+		},
+		[]string{"status_class"},
+	)
+
+	PageSize = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "analyzer_page_size_bytes",
+			Help:    "Size of fetched page bodies",
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 8), // 1 KiB to 16 MiB
+		},
+	)
+
+	LinksChecked = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "analyzer_links_checked",
+			Help:    "Number of links checked per analysis",
+			Buckets: []float64{0, 1, 5, 10, 25, 50, 100, 250, 500, 1000},
+		},
+	)
+
+	LinkCheckDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "analyzer_link_check_duration_seconds",
+			Help:    "Duration of link checks by outcome class (2xx, 3xx, 4xx, 5xx, error, file)",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"outcome"},
+	)
+
+	InaccessibleLinks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "analyzer_inaccessible_links_total",
+			Help: "Inaccessible links found, by registrable domain of the link",
+		},
+		[]string{"domain"},
+	)
+
+	AnalysesInFlight = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "analyzer_analyses_in_flight",
+			Help: "Number of page analyses currently running",
+		},
+	)
+
+	SemaphoreWait = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "analyzer_link_semaphore_wait_seconds",
+			Help:    "Time link checks wait for a concurrency slot",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 8), // 1ms to ~16s
+		},
+	)
+
+	AnalysisErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "analyzer_errors_total",
+			Help: "Failed analyses by error type",
+		},
+		[]string{"type"},
+	)
+)
+
+// analyzerCollectors lists the analyzer metrics registered by Init.
+var analyzerCollectors = []prometheus.Collector{
+	FetchDuration,
+	FetchStatus,
+	PageSize,
+	LinksChecked,
+	LinkCheckDuration,
+	InaccessibleLinks,
+	AnalysesInFlight,
+	SemaphoreWait,
+	AnalysisErrors,
+}
+
+// StatusClass maps an HTTP status code to a bounded label value such as "2xx";
+// a code of 0 (no response) maps to "error".
+func StatusClass(code int) string {
+	switch {
+	case code >= 100 && code < 600:
+		return string(rune('0'+code/100)) + "xx"
+	default:
+		return "error"
+	}
+}
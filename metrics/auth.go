@@ -0,0 +1,15 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AuthRejections counts requests rejected by API key authentication by reason:
+// missing_key, invalid_key, rate_limited or quota_exceeded.
+var AuthRejections = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "auth_rejections_total",
+		Help: "Requests rejected by API key authentication by reason",
+	},
+	[]string{"reason"},
+)
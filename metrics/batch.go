@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Batch analysis metrics.
+var (
+	BatchJobsRunning = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "batch_jobs_running",
+			Help: "Batch analyses currently running",
+		},
+	)
+
+	BatchItems = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "batch_items_total",
+			Help: "URLs analyzed as part of a batch by result (ok, error, canceled)",
+		},
+		[]string{"result"},
+	)
+)
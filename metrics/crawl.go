@@ -0,0 +1,22 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Crawl metrics.
+var (
+	CrawledPages = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "crawl_pages_total",
+			Help: "Pages added to internal link graphs by crawls",
+		},
+	)
+
+	CrawlBrokenLinks = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "crawl_broken_links_total",
+			Help: "Broken internal links found by crawls",
+		},
+	)
+)
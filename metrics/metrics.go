@@ -21,9 +21,36 @@ var (
 		},
 		[]string{"path", "method"},
 	)
+
+	CacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "analyzer_cache_requests_total",
+			Help: "Cache lookups by cache (page, link) and result (hit, miss, revalidated)",
+		},
+		[]string{"cache", "result"},
+	)
+
+	// BuildInfo is always 1; its labels identify the running build.
+	BuildInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "build_info",
+			Help: "Build version, commit and Go version of the running application",
+		},
+		[]string{"version", "commit", "goversion"},
+	)
 )
 
 func Init() {
 	prometheus.MustRegister(RequestCount)
 	prometheus.MustRegister(RequestDuration)
+	prometheus.MustRegister(CacheRequests)
+	prometheus.MustRegister(BuildInfo)
+	prometheus.MustRegister(AuthRejections)
+	prometheus.MustRegister(ThrottledRequests, QueueLength, QueueWait)
+	prometheus.MustRegister(MonitorRuns, MonitorAlerts, MonitorNotifications)
+	prometheus.MustRegister(BatchJobsRunning, BatchItems)
+	prometheus.MustRegister(SitemapsFetched, SitemapIssues)
+	prometheus.MustRegister(CrawledPages, CrawlBrokenLinks)
+	prometheus.MustRegister(ResponseCount, ResponseSize, RequestsInFlight)
+	prometheus.MustRegister(analyzerCollectors...)
 }
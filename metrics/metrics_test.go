@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TestStatusClass checks the mapping of status codes to bounded label values
+func TestStatusClass(t *testing.T) {
+	tests := map[int]string{0: "error", 200: "2xx", 304: "3xx", 404: "4xx", 503: "5xx", 999: "error"}
+	for code, want := range tests {
+		if got := StatusClass(code); got != want {
+			t.Errorf("StatusClass(%d) = %q; want %q", code, got, want)
+		}
+	}
+}
+
+// TestCollectorsRegister checks that all analyzer collectors can be registered together
+func TestCollectorsRegister(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	for _, c := range analyzerCollectors {
+		if err := reg.Register(c); err != nil {
+			t.Errorf("failed to register collector: %v", err)
+		}
+	}
+}
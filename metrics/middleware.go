@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HTTP metrics recorded by Middleware in addition to RequestCount and RequestDuration.
+var (
+	ResponseCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_responses_total",
+			Help: "Total number of HTTP responses by status code",
+		},
+		[]string{"path", "method", "code"},
+	)
+
+	ResponseSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_response_size_bytes",
+			Help:    "Histogram of response body sizes",
+			Buckets: prometheus.ExponentialBuckets(256, 4, 8), // 256 B to 4 MiB
+		},
+		[]string{"path", "method"},
+	)
+
+	RequestsInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests currently being served",
+		},
+		[]string{"path"},
+	)
+)
+
+// statusRecorder captures the status code and body size written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// WriteHeader records the status code before passing it on.
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the body size; a write without WriteHeader implies 200 OK.
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Middleware instruments a route: it counts requests and responses by status code and
+// records request durations, response sizes and in-flight requests. path is the route
+// pattern used as the label, which keeps cardinality independent of the requested URL.
+func Middleware(path string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		RequestCount.WithLabelValues(path, r.Method).Inc()
+		inFlight := RequestsInFlight.WithLabelValues(path)
+		inFlight.Inc()
+
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			// Evaluated when the handler returns, so durations are real
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			inFlight.Dec()
+			RequestDuration.WithLabelValues(path, r.Method).Observe(time.Since(start).Seconds())
+			ResponseCount.WithLabelValues(path, r.Method, strconv.Itoa(rec.status)).Inc()
+			ResponseSize.WithLabelValues(path, r.Method).Observe(float64(rec.bytes))
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
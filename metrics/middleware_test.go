@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// TestMiddleware checks that real durations, status codes, sizes and in-flight requests are recorded
+func TestMiddleware(t *testing.T) {
+	var inFlight float64
+	h := Middleware("/test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight = testutil.ToFloat64(RequestsInFlight.WithLabelValues("/test"))
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	if inFlight != 1 {
+		t.Errorf("in-flight during request = %v; want 1", inFlight)
+	}
+	if got := testutil.ToFloat64(RequestsInFlight.WithLabelValues("/test")); got != 0 {
+		t.Errorf("in-flight after request = %v; want 0", got)
+	}
+	if got := testutil.ToFloat64(ResponseCount.WithLabelValues("/test", http.MethodGet, "418")); got != 1 {
+		t.Errorf("418 responses = %v; want 1", got)
+	}
+	if got := testutil.ToFloat64(RequestCount.WithLabelValues("/test", http.MethodGet)); got != 1 {
+		t.Errorf("requests = %v; want 1", got)
+	}
+
+	// The duration must cover the handler's work rather than being evaluated up front
+	if sum := histogramSum(t, RequestDuration.WithLabelValues("/test", http.MethodGet)); sum < 0.02 {
+		t.Errorf("recorded duration = %vs; want at least 0.02s", sum)
+	}
+	if sum := histogramSum(t, ResponseSize.WithLabelValues("/test", http.MethodGet)); sum != float64(len("short and stout")) {
+		t.Errorf("recorded size = %v; want %d", sum, len("short and stout"))
+	}
+}
+
+// TestMiddleware_ImplicitOK checks that handlers which only write a body are counted as 200
+func TestMiddleware_ImplicitOK(t *testing.T) {
+	h := Middleware("/implicit", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/implicit", nil))
+
+	if got := testutil.ToFloat64(ResponseCount.WithLabelValues("/implicit", http.MethodPost, "200")); got != 1 {
+		t.Errorf("200 responses = %v; want 1", got)
+	}
+}
+
+// histogramSum returns the sum of observations of a single histogram
+func histogramSum(t *testing.T, o prometheus.Observer) float64 {
+	t.Helper()
+	m, ok := o.(prometheus.Metric)
+	if !ok {
+		t.Fatalf("observer %T cannot be written", o)
+	}
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		t.Fatalf("failed to read histogram: %v", err)
+	}
+	return pb.GetHistogram().GetSampleSum()
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Scheduled monitoring metrics.
+var (
+	MonitorRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "monitor_runs_total",
+			Help: "Scheduled analyses by result (ok, error)",
+		},
+		[]string{"result"},
+	)
+
+	MonitorAlerts = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "monitor_alerts_total",
+			Help: "Alerts raised by monitor rules",
+		},
+	)
+
+	MonitorNotifications = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "monitor_notifications_total",
+			Help: "Notifications sent by sink name and result (ok, error)",
+		},
+		[]string{"sink", "result"},
+	)
+)
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Sitemap audit metrics.
+var (
+	SitemapsFetched = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sitemap_fetches_total",
+			Help: "Sitemaps and sitemap indexes read by result (ok, error)",
+		},
+		[]string{"result"},
+	)
+
+	SitemapIssues = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sitemap_issues_total",
+			Help: "Problems found by sitemap audits by type",
+		},
+		[]string{"type"},
+	)
+)
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Overload protection metrics recorded by the throttle package and handlers.
+var (
+	ThrottledRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "analyzer_throttled_requests_total",
+			Help: "Requests rejected by overload protection by reason (rate_limited, queue_full, queue_timeout)",
+		},
+		[]string{"reason"},
+	)
+
+	QueueLength = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "analyzer_queue_length",
+			Help: "Analyses waiting for a free slot",
+		},
+	)
+
+	QueueWait = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "analyzer_queue_wait_seconds",
+			Help:    "Time analyses waited in the queue for a free slot",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 8), // 10ms to ~160s
+		},
+	)
+)
@@ -0,0 +1,104 @@
+// Package monitor re-analyzes registered URLs on a schedule, stores the results and
+// notifies sinks such as webhooks or email when alert rules fire.
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"lucytech/parser"
+	"net/url"
+	"time"
+)
+
+// Monitor is a URL that is analyzed on a schedule.
+type Monitor struct {
+	ID         string             `json:"id"`
+	URL        string             `json:"url"`                  // Page to analyze
+	Schedule   string             `json:"schedule"`             // When to analyze, see ParseSchedule
+	Rules      []Rule             `json:"rules"`                // Alert conditions checked after each run
+	Sinks      []string           `json:"sinks,omitempty"`      // Names of the sinks to notify; empty means all
+	Assertions []parser.Assertion `json:"assertions,omitempty"` // Extra assertions evaluated on each run
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+// Validate checks the URL, schedule and assertions of a monitor.
+func (m Monitor) Validate() error {
+	if m.URL == "" {
+		return errors.New("url is required")
+	}
+	if u, err := url.Parse(m.URL); err != nil || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("url %q must be an http or https URL", m.URL)
+	}
+	if _, err := ParseSchedule(m.Schedule); err != nil {
+		return err
+	}
+	return parser.ValidateAssertions(m.Assertions)
+}
+
+// Alert is a rule that fired in a run.
+type Alert struct {
+	Rule    Rule   `json:"rule"`
+	Message string `json:"message"` // What was observed, e.g. "inaccessible_links is 3 (rule: inaccessible_links > 0)"
+}
+
+// Run is the stored outcome of one scheduled analysis.
+type Run struct {
+	MonitorID  string    `json:"monitor_id"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	Summary    *Summary  `json:"summary,omitempty"` // Nil if the analysis failed
+	Error      string    `json:"error,omitempty"`   // Why the analysis failed
+	Alerts     []Alert   `json:"alerts,omitempty"`  // Rules firing in this run
+}
+
+// Summary holds the figures of an analysis that rules are evaluated on. Runs keep it
+// instead of the full result, whose link lists and outlines would make the store grow
+// with every run. Its JSON names are the rule fields.
+type Summary struct {
+	Title             string `json:"title"`
+	HTMLVersion       string `json:"html_version"`
+	InternalLinks     int    `json:"internal_links"`
+	ExternalLinks     int    `json:"external_links"`
+	InaccessibleLinks int    `json:"inaccessible_links"`
+	MissingAnchors    int    `json:"missing_anchors"`
+	OutlineIssues     int    `json:"outline_issues"`
+	FailedAssertions  int    `json:"failed_assertions"`
+	LoginForm         bool   `json:"login_form"`
+}
+
+// summarize returns the summary of an analysis result, or nil if there is none.
+func summarize(r *parser.AnalysisResult) *Summary {
+	if r == nil {
+		return nil
+	}
+	failed := 0
+	for _, a := range r.Assertions {
+		if !a.Passed {
+			failed++
+		}
+	}
+	return &Summary{
+		Title:             r.Title,
+		HTMLVersion:       r.HTMLVersion,
+		InternalLinks:     r.InternalLinks,
+		ExternalLinks:     r.ExternalLinks,
+		InaccessibleLinks: r.InaccessibleLinks,
+		MissingAnchors:    r.MissingAnchors,
+		OutlineIssues:     len(r.OutlineIssues),
+		FailedAssertions:  failed,
+		LoginForm:         r.LoginForm,
+	}
+}
+
+// firing reports whether the rule was firing in the run.
+func (r *Run) firing(rule Rule) bool {
+	if r == nil {
+		return false
+	}
+	for _, a := range r.Alerts {
+		if a.Rule == rule {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package monitor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rule operators. Comparisons apply to numeric fields; the others compare a run with
+// the previous one, or check the outcome of the analysis itself.
+const (
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpEqual        = "=="
+	OpNotEqual     = "!="
+	OpChanged      = "changed"     // The field differs from the previous run
+	OpAppeared     = "appeared"    // A boolean field became true
+	OpDisappeared  = "disappeared" // A boolean field became false
+	OpFailed       = "failed"      // Only for the "analysis" field: the analysis returned an error
+)
+
+// fieldKind tells which operators a field supports.
+type fieldKind int
+
+const (
+	numericField fieldKind = iota
+	stringField
+	boolField
+)
+
+// fields maps rule field names to their kind and how to read them from a run summary.
+var fields = map[string]struct {
+	kind  fieldKind
+	value func(s *Summary) any
+}{
+	"inaccessible_links": {numericField, func(s *Summary) any { return float64(s.InaccessibleLinks) }},
+	"missing_anchors":    {numericField, func(s *Summary) any { return float64(s.MissingAnchors) }},
+	"internal_links":     {numericField, func(s *Summary) any { return float64(s.InternalLinks) }},
+	"external_links":     {numericField, func(s *Summary) any { return float64(s.ExternalLinks) }},
+	"outline_issues":     {numericField, func(s *Summary) any { return float64(s.OutlineIssues) }},
+	"failed_assertions":  {numericField, func(s *Summary) any { return float64(s.FailedAssertions) }},
+	"title":              {stringField, func(s *Summary) any { return s.Title }},
+	"html_version":       {stringField, func(s *Summary) any { return s.HTMLVersion }},
+	"login_form":         {boolField, func(s *Summary) any { return s.LoginForm }},
+}
+
+// Rule is an alert condition on an analysis result, written as a short expression:
+// "<field> <comparison> <number>" for numeric fields (e.g. "inaccessible_links > 0"),
+// "<field> changed", "<boolean field> appeared|disappeared" (e.g. "login_form disappeared"),
+// or "analysis failed". Rules are stored in JSON as their expression.
+type Rule struct {
+	Field string
+	Op    string
+	Value float64 // Threshold of numeric comparisons
+}
+
+// ParseRule parses and validates a rule expression.
+func ParseRule(expr string) (Rule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) < 2 {
+		return Rule{}, fmt.Errorf("invalid rule %q: want \"<field> <operator> [value]\"", expr)
+	}
+	r := Rule{Field: parts[0], Op: parts[1]}
+
+	if r.Field == "analysis" {
+		if r.Op != OpFailed || len(parts) != 2 {
+			return Rule{}, fmt.Errorf("invalid rule %q: the analysis field only supports %q", expr, OpFailed)
+		}
+		return r, nil
+	}
+	f, ok := fields[r.Field]
+	if !ok {
+		return Rule{}, fmt.Errorf("invalid rule %q: unknown field %q", expr, r.Field)
+	}
+
+	switch r.Op {
+	case OpChanged:
+	case OpAppeared, OpDisappeared:
+		if f.kind != boolField {
+			return Rule{}, fmt.Errorf("invalid rule %q: %s only applies to login_form", expr, r.Op)
+		}
+	case OpGreater, OpGreaterEqual, OpLess, OpLessEqual, OpEqual, OpNotEqual:
+		if f.kind != numericField {
+			return Rule{}, fmt.Errorf("invalid rule %q: %s is not numeric", expr, r.Field)
+		}
+		if len(parts) != 3 {
+			return Rule{}, fmt.Errorf("invalid rule %q: %s needs a number", expr, r.Op)
+		}
+		v, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid rule %q: %w", expr, err)
+		}
+		r.Value = v
+		return r, nil
+	default:
+		return Rule{}, fmt.Errorf("invalid rule %q: unknown operator %q", expr, r.Op)
+	}
+	if len(parts) != 2 {
+		return Rule{}, fmt.Errorf("invalid rule %q: %s takes no value", expr, r.Op)
+	}
+	return r, nil
+}
+
+// event reports whether the rule describes a change between runs rather than a state.
+// Events are notified every time they occur; states only when they start firing.
+func (r Rule) event() bool {
+	return r.Op == OpChanged || r.Op == OpAppeared || r.Op == OpDisappeared
+}
+
+// String returns the rule expression.
+func (r Rule) String() string {
+	switch r.Op {
+	case OpChanged, OpAppeared, OpDisappeared, OpFailed:
+		return r.Field + " " + r.Op
+	}
+	return r.Field + " " + r.Op + " " + strconv.FormatFloat(r.Value, 'f', -1, 64)
+}
+
+// MarshalText implements encoding.TextMarshaler so rules are stored as expressions.
+func (r Rule) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (r *Rule) UnmarshalText(text []byte) error {
+	parsed, err := ParseRule(string(text))
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// Evaluate reports whether the rule fires for the current run, given the summary of the
+// previous run (nil on the first run) and the error of the current analysis. Rules about
+// the result do not fire when the analysis failed, and change rules need a previous result.
+// The returned message describes why the rule fired.
+func (r Rule) Evaluate(prev, cur *Summary, analysisErr error) (bool, string) {
+	if r.Field == "analysis" {
+		if analysisErr != nil {
+			return true, "analysis failed: " + analysisErr.Error()
+		}
+		return false, ""
+	}
+	if cur == nil || analysisErr != nil {
+		return false, ""
+	}
+
+	value := fields[r.Field].value(cur)
+	switch r.Op {
+	case OpChanged, OpAppeared, OpDisappeared:
+		if prev == nil {
+			return false, ""
+		}
+		old := fields[r.Field].value(prev)
+		if old == value {
+			return false, ""
+		}
+		if (r.Op == OpAppeared && value != true) || (r.Op == OpDisappeared && value != false) {
+			return false, ""
+		}
+		return true, fmt.Sprintf("%s %s: %v → %v", r.Field, r.Op, old, value)
+	}
+
+	n := value.(float64)
+	var fired bool
+	switch r.Op {
+	case OpGreater:
+		fired = n > r.Value
+	case OpGreaterEqual:
+		fired = n >= r.Value
+	case OpLess:
+		fired = n < r.Value
+	case OpLessEqual:
+		fired = n <= r.Value
+	case OpEqual:
+		fired = n == r.Value
+	case OpNotEqual:
+		fired = n != r.Value
+	}
+	if !fired {
+		return false, ""
+	}
+	return true, fmt.Sprintf("%s is %v (rule: %s)", r.Field, n, r)
+}
@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// TestParseRule checks valid and invalid rule expressions and their JSON form
+func TestParseRule(t *testing.T) {
+	for _, expr := range []string{"inaccessible_links > 0", "title changed", "login_form disappeared", "analysis failed", "failed_assertions >= 1.5"} {
+		r, err := ParseRule(expr)
+		if err != nil {
+			t.Errorf("ParseRule(%q) returned error: %v", expr, err)
+			continue
+		}
+		if r.String() != expr {
+			t.Errorf("ParseRule(%q).String() = %q", expr, r.String())
+		}
+	}
+
+	for _, expr := range []string{"", "title", "title > 3", "login_form > 0", "links > 0", "inaccessible_links >", "inaccessible_links > x", "title appeared", "analysis changed", "title changed now"} {
+		if _, err := ParseRule(expr); err == nil {
+			t.Errorf("ParseRule(%q) succeeded; want error", expr)
+		}
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal([]byte(`["inaccessible_links > 0", "title changed"]`), &rules); err != nil || len(rules) != 2 {
+		t.Fatalf("unmarshal rules = %v, %v", rules, err)
+	}
+	out, _ := json.Marshal(rules)
+	var exprs []string
+	if err := json.Unmarshal(out, &exprs); err != nil || len(exprs) != 2 || exprs[0] != "inaccessible_links > 0" {
+		t.Errorf("marshal rules = %s; want rule expressions", out)
+	}
+}
+
+// TestRuleEvaluate checks thresholds, changes between runs and failed analyses
+func TestRuleEvaluate(t *testing.T) {
+	prev := &Summary{Title: "Home", LoginForm: true}
+	cur := &Summary{Title: "Home", LoginForm: false, InaccessibleLinks: 2}
+	failure := errors.New("unreachable")
+
+	tests := []struct {
+		expr      string
+		prev, cur *Summary
+		err       error
+		want      bool
+	}{
+		{"inaccessible_links > 0", nil, cur, nil, true},
+		{"inaccessible_links > 2", nil, cur, nil, false},
+		{"title changed", prev, cur, nil, false},
+		{"title changed", prev, &Summary{Title: "Maintenance"}, nil, true},
+		{"title changed", nil, cur, nil, false}, // No baseline yet
+		{"login_form disappeared", prev, cur, nil, true},
+		{"login_form appeared", prev, cur, nil, false},
+		{"analysis failed", prev, nil, failure, true},
+		{"analysis failed", prev, cur, nil, false},
+		{"inaccessible_links > 0", prev, nil, failure, false},
+	}
+	for _, tt := range tests {
+		r, err := ParseRule(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseRule(%q) returned error: %v", tt.expr, err)
+		}
+		if got, msg := r.Evaluate(tt.prev, tt.cur, tt.err); got != tt.want {
+			t.Errorf("%q fired = %v (%q); want %v", tt.expr, got, msg, tt.want)
+		}
+	}
+}
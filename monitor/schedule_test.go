@@ -0,0 +1,43 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseSchedule checks next run times for intervals, shorthands and cron expressions
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2025, 6, 6, 10, 7, 30, 0, time.UTC) // A Friday
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"@every 15m", from.Add(15 * time.Minute)},
+		{"@hourly", time.Date(2025, 6, 6, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 6, 7, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 6, 6, 10, 15, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2025, 6, 9, 9, 30, 0, 0, time.UTC)}, // Next weekday morning is Monday
+		{"0 12 1 * *", time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, 6, 8, 0, 0, 0, 0, time.UTC)}, // 7 is Sunday
+		{"5,10 10 * * *", time.Date(2025, 6, 6, 10, 10, 0, 0, time.UTC)},
+		{"0 0 1 * 1", time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)},   // Either day field: Monday before the 1st
+		{"0 0 */2 * 1", time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)}, // "*/2" is unrestricted, so both must match: odd days that are Mondays
+		{"0 0 1 * */2", time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)}, // The 1st on an even weekday: Tuesday, July 1st
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q) returned error: %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("ParseSchedule(%q).Next = %s; want %s", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "@every 10s", "@every soon", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded; want error", spec)
+		}
+	}
+}
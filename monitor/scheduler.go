@@ -0,0 +1,288 @@
+package monitor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"lucytech/logging"
+	"lucytech/metrics"
+	"lucytech/parser"
+	"sync"
+	"time"
+)
+
+// Limits applied to scheduled work.
+const (
+	maxConcurrentRuns = 4                // Monitors analyzed at once
+	runTimeout        = 5 * time.Minute  // Upper bound on one analysis
+	notifyTimeout     = 30 * time.Second // Upper bound on delivering to one sink
+	maxSleep          = time.Minute      // Longest sleep between checks, to follow clock changes
+)
+
+// Scheduler runs monitors on their schedules, stores the runs and notifies sinks of alerts.
+type Scheduler struct {
+	store *Store
+	sinks map[string]Sink
+
+	// Analyze performs the analysis; replaceable in tests.
+	Analyze func(ctx context.Context, rawURL string, opts parser.Options) (*parser.AnalysisResult, error)
+
+	mu      sync.Mutex
+	next    map[string]time.Time // Next run of each monitor
+	running map[string]bool      // Monitors with a run in progress, which are not started again
+
+	slots chan struct{}    // Bounds concurrent runs
+	wake  chan struct{}    // Signals that the monitors changed
+	now   func() time.Time // Clock, replaceable in tests
+}
+
+// NewScheduler creates a scheduler for the monitors in store, notifying the given sinks by name.
+func NewScheduler(store *Store, sinks map[string]Sink) *Scheduler {
+	return &Scheduler{
+		store:   store,
+		sinks:   sinks,
+		Analyze: parser.AnalyzePage,
+		next:    make(map[string]time.Time),
+		running: make(map[string]bool),
+		slots:   make(chan struct{}, maxConcurrentRuns),
+		wake:    make(chan struct{}, 1),
+		now:     time.Now,
+	}
+}
+
+// Store returns the store holding the monitors and their runs.
+func (s *Scheduler) Store() *Store {
+	return s.store
+}
+
+// Add validates and registers a monitor, assigning its ID. It is first run right away.
+func (s *Scheduler) Add(m Monitor) (Monitor, error) {
+	if err := m.Validate(); err != nil {
+		return Monitor{}, err
+	}
+	for _, name := range m.Sinks {
+		if _, ok := s.sinks[name]; !ok {
+			return Monitor{}, fmt.Errorf("unknown sink %q", name)
+		}
+	}
+
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return Monitor{}, err
+	}
+	m.ID = hex.EncodeToString(id)
+	m.CreatedAt = s.now().UTC()
+	if err := s.store.AddMonitor(m); err != nil {
+		return Monitor{}, err
+	}
+	s.notifyChange()
+	return m, nil
+}
+
+// Remove deletes a monitor and its history.
+func (s *Scheduler) Remove(id string) error {
+	if err := s.store.RemoveMonitor(id); err != nil {
+		return err
+	}
+	s.notifyChange()
+	return nil
+}
+
+// NextRun returns when the monitor is scheduled to run next, if known.
+func (s *Scheduler) NextRun(id string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.next[id]
+	return t, ok
+}
+
+// notifyChange wakes the scheduling loop so that it picks up added or removed monitors.
+func (s *Scheduler) notifyChange() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the scheduling loop in the background until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		for {
+			due, wait := s.due()
+			for _, m := range due {
+				go s.runScheduled(ctx, m)
+			}
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-s.wake:
+			case <-timer.C:
+			}
+			timer.Stop()
+		}
+	}()
+}
+
+// due returns the monitors whose run time has come, advances their next run time,
+// and returns how long to sleep until the next one is due.
+func (s *Scheduler) due() ([]Monitor, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	wait := maxSleep
+
+	var due []Monitor
+	active := make(map[string]bool)
+	for _, m := range s.store.Monitors() {
+		active[m.ID] = true
+		schedule, err := ParseSchedule(m.Schedule)
+		if err != nil {
+			continue // Validated on Add; only a hand-edited store gets here
+		}
+		next, ok := s.next[m.ID]
+		if !ok {
+			// New monitors get a baseline right away; known ones wait for their slot
+			next = now
+			if s.store.LastRun(m.ID) != nil {
+				next = schedule.Next(now)
+			}
+		}
+		if !next.After(now) {
+			due = append(due, m)
+			next = schedule.Next(now)
+		}
+		if next.IsZero() {
+			delete(s.next, m.ID) // The schedule never fires again
+			continue
+		}
+		s.next[m.ID] = next
+		wait = min(wait, next.Sub(now))
+	}
+	for id := range s.next {
+		if !active[id] {
+			delete(s.next, id)
+		}
+	}
+	return due, max(wait, 0)
+}
+
+// runScheduled runs a due monitor unless its previous run is still in progress.
+func (s *Scheduler) runScheduled(ctx context.Context, m Monitor) {
+	logger := monitorLogger(m)
+	ctx = logging.WithLogger(ctx, logger)
+
+	s.mu.Lock()
+	if s.running[m.ID] {
+		s.mu.Unlock()
+		logger.Warn("Skipping monitor run, previous run still in progress")
+		return
+	}
+	s.running[m.ID] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, m.ID)
+		s.mu.Unlock()
+	}()
+
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-ctx.Done():
+		return
+	}
+	if _, err := s.Run(ctx, m.ID); err != nil && !errors.Is(err, ErrMonitorNotFound) {
+		logger.Error("Failed to store monitor run", "error", err)
+	}
+}
+
+// Run analyzes a monitor now, stores the run, and notifies the sinks of alerts that
+// started firing. Rules describing changes notify each time they fire; rules
+// describing a state only when they were not firing in the previous run.
+func (s *Scheduler) Run(ctx context.Context, id string) (Run, error) {
+	m, err := s.store.Monitor(id)
+	if err != nil {
+		return Run{}, err
+	}
+	logger := monitorLogger(m)
+	ctx = logging.WithLogger(ctx, logger)
+	prev := s.store.LastRun(m.ID)
+
+	runCtx, cancel := context.WithTimeout(ctx, runTimeout)
+	defer cancel()
+	run := Run{MonitorID: m.ID, StartedAt: s.now().UTC()}
+	result, analysisErr := s.Analyze(runCtx, m.URL, parser.Options{Assertions: m.Assertions})
+	run.DurationMS = s.now().Sub(run.StartedAt).Milliseconds()
+	run.Summary = summarize(result)
+	if analysisErr != nil {
+		run.Error = analysisErr.Error()
+		metrics.MonitorRuns.WithLabelValues("error").Inc()
+	} else {
+		metrics.MonitorRuns.WithLabelValues("ok").Inc()
+	}
+
+	var prevSummary *Summary
+	if prev != nil {
+		prevSummary = prev.Summary
+	}
+	var notify []Alert
+	for _, rule := range m.Rules {
+		fired, msg := rule.Evaluate(prevSummary, run.Summary, analysisErr)
+		if !fired {
+			continue
+		}
+		alert := Alert{Rule: rule, Message: msg}
+		run.Alerts = append(run.Alerts, alert)
+		if rule.event() || !prev.firing(rule) {
+			notify = append(notify, alert)
+		}
+	}
+	logger.Info("Monitor run complete", "duration_ms", run.DurationMS, "error", run.Error, "alerts", len(run.Alerts))
+
+	if err := s.store.AddRun(run); err != nil {
+		return run, err
+	}
+	if len(notify) > 0 {
+		metrics.MonitorAlerts.Add(float64(len(notify)))
+		s.notify(ctx, m, Notification{MonitorID: m.ID, URL: m.URL, At: run.StartedAt, Alerts: notify})
+	}
+	return run, nil
+}
+
+// monitorLogger returns the logger for the messages about a monitor and its runs.
+func monitorLogger(m Monitor) *slog.Logger {
+	return slog.Default().With("monitor_id", m.ID, "url", m.URL)
+}
+
+// notify sends a notification to the monitor's sinks, or to all sinks if it names none.
+// Delivery failures are logged and counted but do not fail the run.
+func (s *Scheduler) notify(ctx context.Context, m Monitor, n Notification) {
+	names := m.Sinks
+	if len(names) == 0 {
+		for name := range s.sinks {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		sink, ok := s.sinks[name]
+		if !ok {
+			continue
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		err := sink.Send(sendCtx, n)
+		cancel()
+		if err != nil {
+			metrics.MonitorNotifications.WithLabelValues(name, "error").Inc()
+			logging.FromContext(ctx).Error("Failed to send alert notification", "sink", name, "error", err)
+			continue
+		}
+		metrics.MonitorNotifications.WithLabelValues(name, "ok").Inc()
+		logging.FromContext(ctx).Info("Alert notification sent", "sink", name, "alerts", len(n.Alerts))
+	}
+}
@@ -0,0 +1,190 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"lucytech/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink collects the notifications it receives
+type recordingSink struct {
+	mu   sync.Mutex
+	sent []Notification
+}
+
+// Send implements Sink for recordingSink
+func (s *recordingSink) Send(ctx context.Context, n Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, n)
+	return nil
+}
+
+// count returns the number of notifications received
+func (s *recordingSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sent)
+}
+
+// mustRules parses rule expressions or fails the test
+func mustRules(t *testing.T, exprs ...string) []Rule {
+	t.Helper()
+	var rules []Rule
+	for _, e := range exprs {
+		r, err := ParseRule(e)
+		if err != nil {
+			t.Fatalf("ParseRule(%q) returned error: %v", e, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// TestScheduler_Run checks stored runs and that alerts notify when they start firing
+func TestScheduler_Run(t *testing.T) {
+	store, err := OpenStore(filepath.Join(t.TempDir(), "monitors.json"))
+	if err != nil {
+		t.Fatalf("OpenStore returned error: %v", err)
+	}
+	sink := &recordingSink{}
+	s := NewScheduler(store, map[string]Sink{"hook": sink})
+
+	// Each run analyzes the next page state
+	states := []struct {
+		result *parser.AnalysisResult
+		err    error
+	}{
+		{&parser.AnalysisResult{Title: "Shop", LoginForm: true}, nil},
+		{&parser.AnalysisResult{Title: "Shop", LoginForm: true, InaccessibleLinks: 2}, nil},
+		{&parser.AnalysisResult{Title: "Shop", LoginForm: true, InaccessibleLinks: 3}, nil},
+		{&parser.AnalysisResult{Title: "Maintenance", LoginForm: false}, nil},
+		{nil, errors.New("unreachable")},
+	}
+	calls := 0
+	s.Analyze = func(ctx context.Context, rawURL string, opts parser.Options) (*parser.AnalysisResult, error) {
+		st := states[calls]
+		calls++
+		return st.result, st.err
+	}
+
+	m, err := s.Add(Monitor{
+		URL:      "https://shop.example.com",
+		Schedule: "@every 5m",
+		Rules:    mustRules(t, "inaccessible_links > 0", "title changed", "login_form disappeared", "analysis failed"),
+	})
+	if err != nil {
+		t.Fatalf("Add returned error: %v", err)
+	}
+
+	// Notifications after each run: none, broken links start, still broken (no repeat),
+	// title change and lost login form together, analysis failure
+	wantNotified := []int{0, 1, 1, 2, 3}
+	for i, want := range wantNotified {
+		run, err := s.Run(context.Background(), m.ID)
+		if err != nil {
+			t.Fatalf("run %d returned error: %v", i, err)
+		}
+		if got := sink.count(); got != want {
+			t.Errorf("after run %d: %d notifications; want %d (alerts %v)", i, got, want, run.Alerts)
+		}
+	}
+	if got := len(sink.sent[1].Alerts); got != 2 {
+		t.Errorf("second notification has %d alerts; want title change and login form", got)
+	}
+
+	runs := store.Runs(m.ID)
+	if len(runs) != len(states) || runs[0].Error != "unreachable" {
+		t.Errorf("stored %d runs, newest error %q; want %d runs, newest failed", len(runs), runs[0].Error, len(states))
+	}
+
+	// Runs survive a restart
+	reopened, err := OpenStore(store.path)
+	if err != nil || len(reopened.Runs(m.ID)) != len(states) {
+		t.Errorf("reopened store has %d runs (%v); want %d", len(reopened.Runs(m.ID)), err, len(states))
+	}
+}
+
+// TestScheduler_Start checks that a new monitor gets a baseline run right away
+func TestScheduler_Start(t *testing.T) {
+	store, _ := OpenStore("")
+	s := NewScheduler(store, nil)
+	ran := make(chan string, 1)
+	s.Analyze = func(ctx context.Context, rawURL string, opts parser.Options) (*parser.AnalysisResult, error) {
+		ran <- rawURL
+		return &parser.AnalysisResult{}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+	m, err := s.Add(Monitor{URL: "https://example.com", Schedule: "@hourly"})
+	if err != nil {
+		t.Fatalf("Add returned error: %v", err)
+	}
+
+	select {
+	case got := <-ran:
+		if got != "https://example.com" {
+			t.Errorf("analyzed %q; want the monitor URL", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("monitor was not run after being added")
+	}
+
+	// The next run follows the schedule
+	time.Sleep(10 * time.Millisecond)
+	if next, ok := s.NextRun(m.ID); !ok || next.Minute() != 0 || next.Before(time.Now()) {
+		t.Errorf("NextRun = %s, %v; want the next full hour", next, ok)
+	}
+}
+
+// TestScheduler_AddValidates rejects bad schedules and unknown sinks
+func TestScheduler_AddValidates(t *testing.T) {
+	store, _ := OpenStore("")
+	s := NewScheduler(store, map[string]Sink{"hook": &recordingSink{}})
+	for _, m := range []Monitor{
+		{URL: "https://example.com", Schedule: "sometimes"},
+		{URL: "", Schedule: "@hourly"},
+		{URL: "ftp://example.com", Schedule: "@hourly"},
+		{URL: "https://example.com", Schedule: "@hourly", Sinks: []string{"pager"}},
+	} {
+		if _, err := s.Add(m); err == nil {
+			t.Errorf("Add(%+v) succeeded; want error", m)
+		}
+	}
+}
+
+// TestOpenStore_LegacyResults checks that full results stored by earlier versions become run summaries
+func TestOpenStore_LegacyResults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitors.json")
+	legacy := `{"monitors":[{"id":"m1","url":"https://example.com","schedule":"@hourly"}],
+"runs":{"m1":[{"monitor_id":"m1","result":{"title":"Home","inaccessible_links":2,"links":[{"url":"https://example.com/a"}],
+"assertions":[{"passed":false},{"passed":true}]}}]}}`
+	if err := os.WriteFile(path, []byte(legacy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore returned error: %v", err)
+	}
+	want := Summary{Title: "Home", InaccessibleLinks: 2, FailedAssertions: 1}
+	if run := store.LastRun("m1"); run == nil || run.Summary == nil || *run.Summary != want {
+		t.Fatalf("LastRun = %+v; want summary %+v", run, want)
+	}
+
+	// The next save drops the full results
+	if err := store.AddRun(Run{MonitorID: "m1", Summary: &Summary{Title: "Home"}}); err != nil {
+		t.Fatalf("AddRun returned error: %v", err)
+	}
+	raw, _ := os.ReadFile(path)
+	if strings.Contains(string(raw), `"result"`) || strings.Contains(string(raw), "https://example.com/a") {
+		t.Errorf("store = %s; want summaries only", raw)
+	}
+}
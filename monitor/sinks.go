@@ -0,0 +1,233 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Notification is sent to sinks when alert rules of a monitor start firing.
+type Notification struct {
+	MonitorID string    `json:"monitor_id"`
+	URL       string    `json:"url"`
+	At        time.Time `json:"at"`     // Start of the run that fired the alerts
+	Alerts    []Alert   `json:"alerts"` // Rules that started firing in the run
+}
+
+// Subject summarizes the notification in one line.
+func (n Notification) Subject() string {
+	return fmt.Sprintf("[lucytech] %d alert(s) for %s", len(n.Alerts), n.URL)
+}
+
+// Sink delivers notifications, e.g. to a webhook or a mailbox.
+type Sink interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// SinkConfig configures a named sink. Which fields apply depends on the type.
+type SinkConfig struct {
+	Name string `json:"name"` // Referenced by Monitor.Sinks
+	Type string `json:"type"` // "webhook", "smtp" or a type added with RegisterSinkType
+
+	URL     string            `json:"url,omitempty"`     // Webhook: endpoint receiving the JSON notification
+	Headers map[string]string `json:"headers,omitempty"` // Webhook: extra request headers, e.g. a token
+
+	Addr     string   `json:"addr,omitempty"`     // SMTP: server host:port
+	From     string   `json:"from,omitempty"`     // SMTP: sender address
+	To       []string `json:"to,omitempty"`       // SMTP: recipient addresses
+	Username string   `json:"username,omitempty"` // SMTP: optional PLAIN auth user
+	Password string   `json:"password,omitempty"` // SMTP: optional PLAIN auth password
+}
+
+// sinkTypes creates sinks from their config by type.
+var sinkTypes = map[string]func(SinkConfig) (Sink, error){
+	"webhook": newWebhookSink,
+	"smtp":    newSMTPSink,
+}
+
+// RegisterSinkType adds a sink type that can be used in the sinks config.
+// It must be called before LoadSinks.
+func RegisterSinkType(typ string, factory func(SinkConfig) (Sink, error)) {
+	sinkTypes[typ] = factory
+}
+
+// NewSink creates the sink described by cfg.
+func NewSink(cfg SinkConfig) (Sink, error) {
+	factory, ok := sinkTypes[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("sink %q: unknown type %q", cfg.Name, cfg.Type)
+	}
+	sink, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("sink %q: %w", cfg.Name, err)
+	}
+	return sink, nil
+}
+
+// LoadSinks reads a JSON config of the form {"sinks": [...]} and creates the sinks by name.
+func LoadSinks(path string) (map[string]Sink, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read sinks config: %w", err)
+	}
+	var cfg struct {
+		Sinks []SinkConfig `json:"sinks"`
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid sinks config %s: %w", path, err)
+	}
+
+	sinks := make(map[string]Sink, len(cfg.Sinks))
+	for _, c := range cfg.Sinks {
+		if c.Name == "" {
+			return nil, errors.New("every sink needs a name")
+		}
+		if _, dup := sinks[c.Name]; dup {
+			return nil, fmt.Errorf("duplicate sink name %q", c.Name)
+		}
+		if sinks[c.Name], err = NewSink(c); err != nil {
+			return nil, err
+		}
+	}
+	return sinks, nil
+}
+
+// WebhookSink POSTs notifications as JSON to a URL.
+type WebhookSink struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client // Nil uses a client with a 10 second timeout
+}
+
+// newWebhookSink creates a WebhookSink from its config.
+func newWebhookSink(cfg SinkConfig) (Sink, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("webhook url is required")
+	}
+	return &WebhookSink{URL: cfg.URL, Headers: cfg.Headers}, nil
+}
+
+// Send implements Sink. Any status other than 2xx is an error.
+func (s *WebhookSink) Send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "lucytech-monitor")
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// SMTPSink emails notifications as plain text. STARTTLS is used when the server offers it.
+type SMTPSink struct {
+	Addr     string
+	From     string
+	To       []string
+	Username string // Empty disables authentication
+	Password string
+}
+
+// newSMTPSink creates an SMTPSink from its config.
+func newSMTPSink(cfg SinkConfig) (Sink, error) {
+	if cfg.Addr == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return nil, errors.New("smtp addr, from and to are required")
+	}
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		return nil, fmt.Errorf("smtp addr must be host:port: %w", err)
+	}
+	return &SMTPSink{Addr: cfg.Addr, From: cfg.From, To: cfg.To, Username: cfg.Username, Password: cfg.Password}, nil
+}
+
+// Send implements Sink.
+func (s *SMTPSink) Send(ctx context.Context, n Notification) error {
+	conn, err := (&net.Dialer{Timeout: 10 * time.Second}).DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("unable to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	host, _, _ := net.SplitHostPort(s.Addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP handshake failed: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("SMTP STARTTLS failed: %w", err)
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM rejected: %w", err)
+	}
+	for _, to := range s.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("SMTP RCPT TO %s rejected: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA rejected: %w", err)
+	}
+	if _, err := w.Write(s.message(n)); err != nil {
+		return fmt.Errorf("unable to send email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("unable to send email: %w", err)
+	}
+	return c.Quit()
+}
+
+// message formats the notification as an RFC 5322 email.
+func (s *SMTPSink) message(n Notification) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", n.Subject())
+	fmt.Fprintf(&b, "Date: %s\r\n", n.At.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "Monitor %s of %s raised alerts at %s:\r\n\r\n", n.MonitorID, n.URL, n.At.Format(time.RFC3339))
+	for _, a := range n.Alerts {
+		fmt.Fprintf(&b, "- %s\r\n", a.Message)
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,176 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testNotification is a notification with one alert
+var testNotification = Notification{
+	MonitorID: "m1",
+	URL:       "https://example.com",
+	At:        time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+	Alerts:    []Alert{{Rule: Rule{Field: "inaccessible_links", Op: OpGreater}, Message: "inaccessible_links is 3 (rule: inaccessible_links > 0)"}},
+}
+
+// TestWebhookSink posts a notification to a local stand-in receiver
+func TestWebhookSink(t *testing.T) {
+	var got Notification
+	var token string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer ts.Close()
+
+	sink, err := NewSink(SinkConfig{Name: "hook", Type: "webhook", URL: ts.URL, Headers: map[string]string{"Authorization": "Bearer t0ken"}})
+	if err != nil {
+		t.Fatalf("NewSink returned error: %v", err)
+	}
+	if err := sink.Send(context.Background(), testNotification); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	if got.MonitorID != "m1" || len(got.Alerts) != 1 || token != "Bearer t0ken" {
+		t.Errorf("received %+v with token %q; want the notification and configured header", got, token)
+	}
+
+	// Non-2xx responses are delivery failures
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	if err := (&WebhookSink{URL: failing.URL}).Send(context.Background(), testNotification); err == nil {
+		t.Error("expected error for 500 response")
+	}
+}
+
+// smtpServer is a minimal local stand-in SMTP server that records one message
+type smtpServer struct {
+	addr     string
+	from     string
+	to       []string
+	data     string
+	received chan struct{}
+}
+
+// startSMTPServer listens on a random local port and serves a single session
+func startSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &smtpServer{addr: ln.Addr().String(), received: make(chan struct{})}
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 localhost ESMTP test")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimSpace(line)
+			upper := strings.ToUpper(cmd)
+			switch {
+			case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(upper, "MAIL FROM:"):
+				s.from = strings.Trim(cmd[len("MAIL FROM:"):], "<> ")
+				reply("250 OK")
+			case strings.HasPrefix(upper, "RCPT TO:"):
+				s.to = append(s.to, strings.Trim(cmd[len("RCPT TO:"):], "<> "))
+				reply("250 OK")
+			case upper == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var b strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					b.WriteString(l)
+				}
+				s.data = b.String()
+				reply("250 OK")
+			case upper == "QUIT":
+				reply("221 Bye")
+				close(s.received)
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+	return s
+}
+
+// TestSMTPSink emails a notification through a local stand-in SMTP server
+func TestSMTPSink(t *testing.T) {
+	srv := startSMTPServer(t)
+	sink, err := NewSink(SinkConfig{Name: "mail", Type: "smtp", Addr: srv.addr, From: "monitor@example.com", To: []string{"ops@example.com"}})
+	if err != nil {
+		t.Fatalf("NewSink returned error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sink.Send(ctx, testNotification); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	<-srv.received
+
+	if srv.from != "monitor@example.com" || len(srv.to) != 1 || srv.to[0] != "ops@example.com" {
+		t.Errorf("envelope from %q to %v; want monitor@example.com to ops@example.com", srv.from, srv.to)
+	}
+	if !strings.Contains(srv.data, "Subject: [lucytech] 1 alert(s) for https://example.com") || !strings.Contains(srv.data, "inaccessible_links is 3") {
+		t.Errorf("message lacks subject or alert:\n%s", srv.data)
+	}
+}
+
+// TestLoadSinks covers a valid config and configuration errors
+func TestLoadSinks(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "sinks.json")
+		os.WriteFile(path, []byte(content), 0o644)
+		return path
+	}
+
+	sinks, err := LoadSinks(write(`{"sinks": [
+		{"name": "hook", "type": "webhook", "url": "http://localhost:9000/alerts"},
+		{"name": "mail", "type": "smtp", "addr": "localhost:25", "from": "a@example.com", "to": ["b@example.com"]}
+	]}`))
+	if err != nil || len(sinks) != 2 {
+		t.Fatalf("LoadSinks = %v, %v; want two sinks", sinks, err)
+	}
+
+	for _, bad := range []string{
+		`{"sinks": [{"name": "x", "type": "pager"}]}`,
+		`{"sinks": [{"name": "x", "type": "webhook"}]}`,
+		`{"sinks": [{"name": "x", "type": "smtp", "addr": "localhost"}]}`,
+		`{"sinks": [{"type": "webhook", "url": "http://x"}]}`,
+		`{"sinks": [{"name": "x", "type": "webhook", "url": "http://x"}, {"name": "x", "type": "webhook", "url": "http://y"}]}`,
+	} {
+		if _, err := LoadSinks(write(bad)); err == nil {
+			t.Errorf("LoadSinks(%s) succeeded; want error", bad)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lucytech/parser"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// maxRunsPerMonitor bounds the stored history of each monitor; older runs are dropped.
+const maxRunsPerMonitor = 50
+
+// ErrMonitorNotFound is returned for operations on an unknown monitor ID.
+var ErrMonitorNotFound = errors.New("monitor not found")
+
+// storeData is the on-disk layout of the store.
+type storeData struct {
+	Monitors []Monitor        `json:"monitors"`
+	Runs     map[string][]Run `json:"runs"` // By monitor ID, oldest first
+}
+
+// Store keeps monitors and their run history in memory and persists them to a JSON file.
+type Store struct {
+	mu   sync.Mutex
+	path string // File the data is saved to; empty keeps it in memory only
+	data storeData
+}
+
+// OpenStore loads the store saved at path, starting empty if the file does not exist yet.
+// An empty path creates a store that is not persisted.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, data: storeData{Runs: make(map[string][]Run)}}
+	if path == "" {
+		return s, nil
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read monitor store: %w", err)
+	}
+	if err := json.Unmarshal(raw, &s.data); err != nil {
+		return nil, fmt.Errorf("invalid monitor store %s: %w", path, err)
+	}
+	if s.data.Runs == nil {
+		s.data.Runs = make(map[string][]Run)
+	}
+	if err := s.migrateResults(raw); err != nil {
+		return nil, fmt.Errorf("invalid monitor store %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// migrateResults summarizes the full results that runs of earlier versions stored, so
+// that change rules keep their baseline. They are dropped from the file on the next save.
+func (s *Store) migrateResults(raw []byte) error {
+	var legacy struct {
+		Runs map[string][]struct {
+			Result *parser.AnalysisResult `json:"result"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(raw, &legacy); err != nil {
+		return err
+	}
+	for id, runs := range legacy.Runs {
+		for i, run := range runs {
+			if run.Result != nil && s.data.Runs[id][i].Summary == nil {
+				s.data.Runs[id][i].Summary = summarize(run.Result)
+			}
+		}
+	}
+	return nil
+}
+
+// AddMonitor stores a new monitor.
+func (s *Store) AddMonitor(m Monitor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Monitors = append(s.data.Monitors, m)
+	if err := s.save(); err != nil {
+		s.data.Monitors = s.data.Monitors[:len(s.data.Monitors)-1]
+		return err
+	}
+	return nil
+}
+
+// RemoveMonitor deletes a monitor and its history.
+func (s *Store) RemoveMonitor(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, m := range s.data.Monitors {
+		if m.ID == id {
+			s.data.Monitors = append(s.data.Monitors[:i], s.data.Monitors[i+1:]...)
+			delete(s.data.Runs, id)
+			return s.save()
+		}
+	}
+	return ErrMonitorNotFound
+}
+
+// Monitors returns all monitors in creation order.
+func (s *Store) Monitors() []Monitor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Monitor(nil), s.data.Monitors...)
+}
+
+// Monitor returns the monitor with the given ID.
+func (s *Store) Monitor(id string) (Monitor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.data.Monitors {
+		if m.ID == id {
+			return m, nil
+		}
+	}
+	return Monitor{}, ErrMonitorNotFound
+}
+
+// AddRun appends a run to the history of its monitor, dropping the oldest runs
+// beyond maxRunsPerMonitor. Runs of removed monitors are discarded.
+func (s *Store) AddRun(run Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	known := false
+	for _, m := range s.data.Monitors {
+		known = known || m.ID == run.MonitorID
+	}
+	if !known {
+		return ErrMonitorNotFound
+	}
+	runs := append(s.data.Runs[run.MonitorID], run)
+	if len(runs) > maxRunsPerMonitor {
+		runs = runs[len(runs)-maxRunsPerMonitor:]
+	}
+	s.data.Runs[run.MonitorID] = runs
+	return s.save()
+}
+
+// Runs returns the stored runs of a monitor, newest first.
+func (s *Store) Runs(id string) []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.data.Runs[id]
+	runs := make([]Run, len(stored))
+	for i, r := range stored {
+		runs[len(stored)-1-i] = r
+	}
+	return runs
+}
+
+// LastRun returns the most recent run of a monitor, or nil if it has not run yet.
+func (s *Store) LastRun(id string) *Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := s.data.Runs[id]
+	if len(runs) == 0 {
+		return nil
+	}
+	last := runs[len(runs)-1]
+	return &last
+}
+
+// Ping checks that the store can be written, for readiness checks.
+func (s *Store) Ping() error {
+	if s.path == "" {
+		return nil
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.path), ".monitors-ping-*")
+	if err != nil {
+		return fmt.Errorf("monitor store is not writable: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// save writes the data to the store file, replacing it atomically. Callers hold s.mu.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	raw, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".monitors-*")
+	if err != nil {
+		return fmt.Errorf("unable to save monitor store: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op after a successful rename
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to save monitor store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to save monitor store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("unable to save monitor store: %w", err)
+	}
+	return nil
+}
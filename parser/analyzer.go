@@ -1,82 +1,206 @@
 package parser
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"log/slog"
+	"lucytech/logging"
+	"lucytech/metrics"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/net/html"
 )
 
 // AnalysisResult holds the data extracted from the analyzed web page.
 type AnalysisResult struct {
-	HTMLVersion       string         // Detected HTML version (e.g., HTML 5)
-	Title             string         // The page title
-	Headings          map[string]int // Count of heading tags (H1, H2, etc.)
-	InternalLinks     int            // Number of internal links found on the page
-	ExternalLinks     int            // Number of external links found on the page
-	InaccessibleLinks int            // Number of links that could not be reached (HTTP errors)
-	LoginForm         bool           // True if a password input is found (indicating a login form)
+	URL               string            `json:"url"`                    // Address of the analyzed page, or the base URL of uploaded HTML; IDN hosts in punycode
+	DisplayURL        string            `json:"display_url,omitempty"`  // Unicode form of URL, set if it has an IDN host or non-ASCII path
+	HTMLVersion       string            `json:"html_version"`           // Detected HTML version (e.g., HTML 5)
+	Title             string            `json:"title"`                  // The page title
+	Outline           []*Heading        `json:"outline"`                // Heading hierarchy in document order
+	OutlineIssues     []OutlineIssue    `json:"outline_issues"`         // Structural problems found in the outline
+	InternalLinks     int               `json:"internal_links"`         // Number of internal links found on the page
+	ExternalLinks     int               `json:"external_links"`         // Number of external links found on the page
+	OtherLinks        map[string]int    `json:"other_links,omitempty"`  // Number of non-web links by scheme, e.g. "mailto" or "tel"; neither internal nor external
+	RelLinks          map[string]int    `json:"rel_links,omitempty"`    // Number of links by rel keyword, e.g. "nofollow"
+	InaccessibleLinks int               `json:"inaccessible_links"`     // Number of links that could not be reached (HTTP errors)
+	MissingAnchors    int               `json:"missing_anchors"`        // Number of internal links whose fragment names no element of their target
+	Links             []LinkResult      `json:"links"`                  // Each distinct link in document order, with its check outcome
+	LoginForm         bool              `json:"login_form"`             // True if a password input is found (indicating a login form)
+	Assertions        []AssertionResult `json:"assertions,omitempty"`   // Outcome of the configured CSS selector assertions
+	Mode              string            `json:"mode"`                   // Whether the raw or the rendered DOM was analyzed
+	RenderDiff        *RenderDiff       `json:"render_diff,omitempty"`  // Raw vs rendered DOM counts, set in rendered mode
+	InsecureTLS       bool              `json:"insecure_tls,omitempty"` // True if TLS certificates were not verified, see TransportConfig
 }
 
+// LinkResult describes one distinct link found on the page.
+type LinkResult struct {
+	URL        string `json:"url"`                   // Absolute link URL, resolved against the page; data: URLs are shortened
+	DisplayURL string `json:"display_url,omitempty"` // Unicode form of URL, set if it has an IDN host or non-ASCII path
+	Internal   bool   `json:"internal"`              // True if the link points at the page's site, see SameSite
+	Scheme     string `json:"scheme,omitempty"`      // Set for non-web links, e.g. "mailto"; these are never checked
+	Rel        string `json:"rel,omitempty"`         // Recognized rel keywords, space-separated: nofollow, sponsored, ugc, noopener, noreferrer
+	Checked    bool   `json:"checked"`               // False when link checks were skipped
+	Accessible bool   `json:"accessible"`            // True if the link could be reached; only meaningful when checked
+	StatusCode int    `json:"status_code,omitempty"` // HTTP status of the check, 0 if no response was received
+	Error      string `json:"error,omitempty"`       // Transport error, if the check failed before a response
+
+	MissingAnchor bool `json:"missing_anchor,omitempty"` // The page loads, but has no element with the link's fragment as id or name
+}
+
+// Options tunes a single analysis run.
+type Options struct {
+	Assertions     []Assertion // Declarative checks evaluated against the parsed document
+	Render         bool        // Render the page in a headless browser before analyzing it
+	WaitSelector   string      // In render mode, wait for this selector instead of network idle
+	Fetcher        Fetcher     // Source of the document; nil selects one from the URL scheme
+	SkipLinkChecks bool        // Classify links without checking whether they are accessible
+}
+
+// UploadBaseURL stands in for the address of uploaded HTML that has no base URL.
+// The .invalid TLD is reserved, so links resolved against it can never be fetched.
+const UploadBaseURL = "http://upload.invalid/"
+
 // httpClient is reused for all HTTP requests with a timeout, facilitating test mocking.
+// Its transport is the one set up with ConfigureTransport.
 var httpClient = &http.Client{
-	Timeout: 10 * time.Second,
+	Timeout:   10 * time.Second,
+	Transport: SharedTransport,
 }
 
+// defaultFetcher retrieves the server HTML for every analysis.
+var defaultFetcher Fetcher = &HTTPFetcher{}
+
 // AnalyzePage function variable allows overriding for testing/mocking.
 var AnalyzePage = realAnalyzePage
 
 // realAnalyzePage performs full page analysis: fetching, parsing, and link checking.
-func realAnalyzePage(rawURL string) (*AnalysisResult, error) {
-	slog.Info("Starting page analysis", "url", rawURL)
+// It tracks in-flight analyses, counts failures by error type and traces the whole analysis.
+func realAnalyzePage(ctx context.Context, rawURL string, opts Options) (*AnalysisResult, error) {
+	metrics.AnalysesInFlight.Inc()
+	defer metrics.AnalysesInFlight.Dec()
+
+	ctx, span := startSpan(ctx, "analyze", rawURL, attribute.Bool("analysis.render", opts.Render))
+	result, err := analyze(ctx, rawURL, opts)
+	if err != nil {
+		metrics.AnalysisErrors.WithLabelValues(errorType(err)).Inc()
+		span.SetAttributes(attribute.String("error.type", errorType(err)))
+	}
+	endSpan(span, 0, err)
+	return result, err
+}
+
+// analyze normalizes the URL, fetches the document from the selected source and analyzes it.
+func analyze(ctx context.Context, rawURL string, opts Options) (*AnalysisResult, error) {
+	logging.FromContext(ctx).Info("Starting page analysis", "url", rawURL)
 
 	// Ensure URL has a scheme; default to https:// if missing.
-	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+	rawURL = strings.TrimSpace(rawURL)
+	if !hasScheme(rawURL, "http", "https", "file") {
 		rawURL = "https://" + rawURL
-		slog.Debug("Prepended https:// to URL", "updated_url", rawURL)
+		logging.FromContext(ctx).Debug("Prepended https:// to URL", "updated_url", rawURL)
 	}
 
 	// Validate the URL format and parse components.
 	parsedURL, err := url.ParseRequestURI(rawURL)
-	if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
-		slog.Error("Invalid URL format", "error", err, "rawURL", rawURL)
-		return nil, fmt.Errorf("invalid URL: %w", err)
+	if err != nil || parsedURL.Scheme == "" || (parsedURL.Host == "" && parsedURL.Scheme != "file") {
+		logging.FromContext(ctx).Error("Invalid URL format", "error", err, "rawURL", rawURL)
+		if err == nil {
+			err = errors.New("missing scheme or host")
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidURL, err)
+	}
+
+	// Fetch, cache and compare internationalized URLs in their ASCII form
+	if !isASCII(rawURL) {
+		if parsedURL, err = asciiURL(parsedURL); err != nil {
+			logging.FromContext(ctx).Error("Invalid internationalized domain name", "error", err, "rawURL", rawURL)
+			return nil, fmt.Errorf("%w: %w", ErrInvalidURL, err)
+		}
+		rawURL = parsedURL.String()
+	}
+
+	// Plain HTTP analyses are served from the result cache when possible.
+	if cacheable(parsedURL, opts) {
+		return analyzeCached(ctx, parsedURL, opts)
 	}
 
-	// Fetch the page content via HTTP GET.
-	resp, err := httpClient.Get(rawURL)
+	// Fetch the document from the requested source, by default the server HTML via HTTP GET.
+	fetcher := opts.Fetcher
+	if fetcher == nil {
+		fetcher = fetcherFor(parsedURL)
+	}
+	page, err := tracedFetch(ctx, "fetch", fetcher, rawURL)
 	if err != nil {
-		slog.Error("Failed to fetch URL", "error", err, "url", rawURL)
-		return nil, fmt.Errorf("unable to reach URL: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	slog.Debug("Fetched URL", "status_code", resp.StatusCode)
-	if resp.StatusCode >= 400 {
-		slog.Warn("Received HTTP error status from server", "status_code", resp.StatusCode)
-		return nil, fmt.Errorf("HTTP error: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	return analyzeDocument(ctx, parsedURL, page, opts)
+}
+
+// hasScheme reports whether rawURL starts with one of the schemes and "://", in any case.
+func hasScheme(rawURL string, schemes ...string) bool {
+	for _, scheme := range schemes {
+		if prefix := scheme + "://"; len(rawURL) >= len(prefix) && strings.EqualFold(rawURL[:len(prefix)], prefix) {
+			return true
+		}
 	}
+	return false
+}
 
-	// Parse the HTML document from response body.
-	doc, err := html.Parse(resp.Body)
+// analyzeDocument parses a fetched page and extracts the analysis result from it.
+func analyzeDocument(ctx context.Context, parsedURL *url.URL, page *Page, opts Options) (*AnalysisResult, error) {
+	rawURL := page.URL
+	doc, err := parseDocument(ctx, page)
 	if err != nil {
-		slog.Error("Failed to parse HTML document", "error", err)
-		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+		return nil, err
+	}
+
+	// The headless browser skips certificate verification exactly when the transport does,
+	// so the flag holds for raw and rendered analyses alike
+	result := &AnalysisResult{URL: rawURL, DisplayURL: unicodeURL(rawURL), Mode: ModeRaw, InsecureTLS: insecureTLS}
+
+	// Detect HTML version by examining the server document's doctype; the rendered DOM has none.
+	result.HTMLVersion = detectHTMLVersion(doc)
+
+	// Optionally render the page in a headless browser and analyze the resulting DOM instead,
+	// keeping the raw and rendered element counts for comparison.
+	if opts.Render {
+		renderer := Renderer
+		if w, ok := renderer.(selectorWaiter); ok && opts.WaitSelector != "" {
+			renderer = w.WithWaitSelector(opts.WaitSelector)
+		}
+		renderedPage, err := tracedFetch(ctx, "render", renderer, rawURL)
+		if err != nil {
+			return nil, err
+		}
+		rendered, err := parseDocument(ctx, renderedPage)
+		if err != nil {
+			return nil, err
+		}
+		result.Mode = ModeRendered
+		result.RenderDiff = &RenderDiff{Raw: countDOM(doc), Rendered: countDOM(rendered)}
+		doc = rendered
 	}
 
-	// Initialize result struct with empty headings map.
-	result := &AnalysisResult{Headings: make(map[string]int)}
-	var links []string
+	// Trace the DOM traversal separately from fetching and link checking.
+	_, traverseSpan := tracer.Start(ctx, "traverse")
+
+	var links []pageLink
+	var headings []*Heading          // Headings in document order, nested into the outline afterwards
+	anchors := make(map[string]bool) // Fragment targets of the page, for same-page links
 
 	// Recursive function to walk through the HTML nodes and extract info.
 	var f func(*html.Node)
 	f = func(n *html.Node) {
 		if n.Type == html.ElementNode {
+			addAnchor(anchors, n)
 			switch n.Data {
 			case "title":
 				// Extract page title from <title> tag text content.
@@ -91,16 +215,21 @@ func realAnalyzePage(rawURL string) (*AnalysisResult, error) {
 					}
 				}
 			case "a":
-				// Collect all href attributes from <a> tags as links.
+				// Collect all href attributes from <a> tags as links, with their rel keywords.
+				var link pageLink
 				for _, attr := range n.Attr {
-					if attr.Key == "href" {
-						links = append(links, attr.Val)
+					switch attr.Key {
+					case "href":
+						link.href = attr.Val
+					case "rel":
+						link.rel = relOf(attr.Val)
 					}
 				}
+				links = append(links, link)
 			default:
-				// Count heading tags like h1, h2,... h6 (case insensitive).
-				if strings.HasPrefix(n.Data, "h") && len(n.Data) == 2 && n.Data[1] >= '1' && n.Data[1] <= '6' {
-					result.Headings[strings.ToUpper(n.Data)]++
+				// Record heading tags like h1, h2,... h6 with their text for the outline.
+				if level := headingLevel(n); level > 0 {
+					headings = append(headings, &Heading{Level: level, Text: textContent(n), Position: len(headings) + 1})
 				}
 			}
 		}
@@ -111,23 +240,44 @@ func realAnalyzePage(rawURL string) (*AnalysisResult, error) {
 	}
 	f(doc)
 
-	// Detect HTML version by examining the document's doctype.
-	result.HTMLVersion = detectHTMLVersion(doc)
+	// Build the heading outline tree and flag structural problems.
+	result.Outline, result.OutlineIssues = buildOutline(headings)
+
+	// Evaluate the declarative CSS selector assertions that apply to this host.
+	result.Assertions = evaluateAssertions(doc, parsedURL.Hostname(), opts.Assertions)
+
+	traverseSpan.SetAttributes(attribute.Int("dom.links", len(links)), attribute.Int("dom.headings", len(headings)))
+	traverseSpan.End()
 
 	// Analyze links: count internal/external and check accessibility concurrently.
-	countLinks(result, parsedURL, links)
+	countLinks(ctx, result, parsedURL, links, !opts.SkipLinkChecks)
+
+	// Verify that link fragments name an element of the page they point at.
+	checkAnchors(ctx, result, parsedURL, anchors, !opts.SkipLinkChecks)
 
-	slog.Info("Page analysis complete",
+	logging.FromContext(ctx).Info("Page analysis complete",
+		"mode", result.Mode,
 		"html_version", result.HTMLVersion,
 		"title", result.Title,
 		"internal_links", result.InternalLinks,
 		"external_links", result.ExternalLinks,
 		"inaccessible_links", result.InaccessibleLinks,
+		"missing_anchors", result.MissingAnchors,
 		"login_form_detected", result.LoginForm)
 
 	return result, nil
 }
 
+// parseDocument parses the body of a fetched page into an HTML tree.
+func parseDocument(ctx context.Context, page *Page) (*html.Node, error) {
+	doc, err := html.Parse(bytes.NewReader(page.Body))
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to parse HTML document", "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrParse, err)
+	}
+	return doc, nil
+}
+
 // detectHTMLVersion examines the document's doctype to guess the HTML version.
 func detectHTMLVersion(doc *html.Node) string {
 	for c := doc.FirstChild; c != nil; c = c.NextSibling {
@@ -151,84 +301,179 @@ func detectHTMLVersion(doc *html.Node) string {
 
 const maxConcurrentRequests = 10 // Tune this value based on system capacity
 
-// countLinks counts internal vs external links and checks which links are inaccessible.
-// It performs concurrent HTTP HEAD requests to verify link accessibility.
-func countLinks(result *AnalysisResult, base *url.URL, links []string) {
-	seen := make(map[string]bool)                     // Track processed links to avoid duplicates
-	var wg sync.WaitGroup                             // WaitGroup to wait for all link checks
-	resultCh := make(chan bool, len(links))           // Buffered channel to collect accessibility results
-	sem := make(chan struct{}, maxConcurrentRequests) // Semaphore to limit concurrency
+// pageLink is the href of an <a> element with its recognized rel keywords.
+type pageLink struct {
+	href string
+	rel  string
+}
+
+// countLinks classifies links as internal, external or non-web by scheme, counts their
+// rel keywords and checks which web links are inaccessible. Every distinct link is
+// recorded in result.Links in document order, in canonical form (see Normalizer) but
+// keeping its fragment; the rel keywords of repeated links are merged. Links that only
+// differ in their fragment are checked once.
+func countLinks(ctx context.Context, result *AnalysisResult, base *url.URL, links []pageLink, check bool) {
+	seen := make(map[string]int) // Index in result.Links of each canonical link, to merge duplicates
+	result.Links = []LinkResult{}
 
 	for _, link := range links {
-		if link == "" || seen[link] {
-			continue // Skip empty or already processed links
+		if link.href == "" {
+			continue // Skip empty links
 		}
-		seen[link] = true
 
 		// Parse the link URL relative to base if it's not absolute
-		linkURL, err := url.Parse(link)
+		linkURL, err := url.Parse(strings.TrimSpace(link.href))
 		if err != nil {
-			slog.Warn("Skipping malformed link", "link", link, "error", err)
+			logging.FromContext(ctx).Warn("Skipping malformed link", "link", link.href, "error", err)
 			continue
 		}
 		if !linkURL.IsAbs() {
 			linkURL = base.ResolveReference(linkURL)
 		}
+		canonical := NormalizeURL(linkURL)
+		canonical.Fragment, canonical.RawFragment = linkURL.Fragment, linkURL.RawFragment
+		key := canonical.String()
+		if i, ok := seen[key]; ok {
+			result.Links[i].Rel = relOf(result.Links[i].Rel, link.rel)
+			continue
+		}
+		seen[key] = len(result.Links)
+
+		// Non-web links such as mailto: or javascript: are counted by scheme and never fetched
+		scheme := canonical.Scheme
+		if !webSchemes[scheme] {
+			if result.OtherLinks == nil {
+				result.OtherLinks = make(map[string]int)
+			}
+			result.OtherLinks[scheme]++
+			result.Links = append(result.Links, LinkResult{URL: displayLink(canonical), Scheme: scheme, Rel: link.rel})
+			continue
+		}
 
 		// Increment internal or external link counts
-		if linkURL.Host == base.Host {
+		internal := SameSiteRules.internal(base, canonical)
+		if internal {
 			result.InternalLinks++
 		} else {
 			result.ExternalLinks++
 		}
+		result.Links = append(result.Links, LinkResult{URL: key, DisplayURL: unicodeURL(key), Internal: internal, Rel: link.rel})
+	}
+	for _, lr := range result.Links {
+		for _, k := range strings.Fields(lr.Rel) {
+			if result.RelLinks == nil {
+				result.RelLinks = make(map[string]int)
+			}
+			result.RelLinks[k]++
+		}
+	}
 
-		// Concurrently check link accessibility via HTTP HEAD request
+	if !check {
+		metrics.LinksChecked.Observe(0)
+		return // Only classify links when accessibility checks are disabled
+	}
+
+	// Group the web links by the document they point at, i.e. without their fragment
+	var targets []string
+	entries := make(map[string][]int)
+	for i, lr := range result.Links {
+		if lr.Scheme != "" {
+			continue
+		}
+		// Checking the file:// links of a web page would tell its author which local files
+		// exist, so they are only checked when a local page is analyzed
+		if strings.HasPrefix(lr.URL, "file://") && base.Scheme != "file" {
+			continue
+		}
+		target := CanonicalURL(lr.URL)
+		if _, ok := entries[target]; !ok {
+			targets = append(targets, target)
+		}
+		entries[target] = append(entries[target], i)
+	}
+	metrics.LinksChecked.Observe(float64(len(targets)))
+
+	// Concurrently check link accessibility; each goroutine fills in the entries of its target
+	var wg sync.WaitGroup                             // WaitGroup to wait for all link checks
+	sem := make(chan struct{}, maxConcurrentRequests) // Semaphore to limit concurrency
+	for _, target := range targets {
 		wg.Add(1)
-		go func(link string) {
+		go func() {
 			defer wg.Done()
 
+			waitStart := time.Now()
 			sem <- struct{}{}        // Acquire a semaphore slot
 			defer func() { <-sem }() // Release the semaphore slot
+			metrics.SemaphoreWait.Observe(time.Since(waitStart).Seconds())
 
-			// Create a HEAD request to avoid downloading the whole content
-			req, err := http.NewRequest(http.MethodHead, link, nil)
-			if err != nil {
-				slog.Warn("Failed to create HEAD request", "link", link, "error", err)
-				resultCh <- false
-				return
+			verdict := checkLink(ctx, target)
+			for _, i := range entries[target] {
+				lr := &result.Links[i]
+				lr.Checked, lr.Accessible, lr.StatusCode, lr.Error = true, verdict.Accessible, verdict.StatusCode, verdict.Error
 			}
-
-			req.Header.Set("User-Agent", "Golang Link Checker")
-
-			resp, err := httpClient.Do(req)
-			if err != nil {
-				slog.Warn("HEAD request failed", "link", link, "error", err)
-				resultCh <- false
-				return
+			if !verdict.Accessible {
+				metrics.InaccessibleLinks.WithLabelValues(registrableDomain(target)).Inc()
 			}
-			defer resp.Body.Close()
+		}()
+	}
+	wg.Wait()
 
-			// Consider HTTP 400+ responses as inaccessible
-			if resp.StatusCode >= 400 {
-				slog.Warn("Link returned error status", "link", link, "status_code", resp.StatusCode)
-				resultCh <- false
-				return
-			}
-			// Link is accessible
-			resultCh <- true
-		}(linkURL.String())
+	// Count how many links were inaccessible
+	for _, lr := range result.Links {
+		if lr.Checked && !lr.Accessible {
+			result.InaccessibleLinks++
+		}
 	}
+}
 
-	// Close the channel after all goroutines finish
-	go func() {
-		wg.Wait()
-		close(resultCh)
+// probeLink checks whether a link is accessible. Web links are checked with an
+// HTTP HEAD request; file:// links must point at an existing file inside LocalRoot.
+func probeLink(ctx context.Context, link string) LinkVerdict {
+	verdict := LinkVerdict{CheckedAt: time.Now()}
+	outcome := "" // Outcome label for the latency metric, derived from the status unless set
+	defer func() {
+		if outcome == "" {
+			outcome = metrics.StatusClass(verdict.StatusCode)
+		}
+		metrics.LinkCheckDuration.WithLabelValues(outcome).Observe(time.Since(verdict.CheckedAt).Seconds())
 	}()
 
-	// Count how many links were inaccessible
-	for accessible := range resultCh {
-		if !accessible {
-			result.InaccessibleLinks++
+	if strings.HasPrefix(link, "file://") {
+		outcome = "file"
+		if _, err := (&FileFetcher{Root: LocalRoot}).resolve(link); err != nil {
+			logging.FromContext(ctx).Warn("Local link is not accessible", "link", link, "error", err)
+			verdict.Error = err.Error()
+			return verdict
 		}
+		verdict.Accessible = true
+		return verdict
+	}
+
+	// Create a HEAD request to avoid downloading the whole content
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, link, nil)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to create HEAD request", "link", link, "error", err)
+		verdict.Error = err.Error()
+		return verdict
+	}
+
+	req.Header.Set("User-Agent", "Golang Link Checker")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		logging.FromContext(ctx).Warn("HEAD request failed", "link", link, "error", err)
+		verdict.Error = err.Error()
+		return verdict
+	}
+	defer resp.Body.Close()
+	verdict.StatusCode = resp.StatusCode
+
+	// Consider HTTP 400+ responses as inaccessible
+	if resp.StatusCode >= 400 {
+		logging.FromContext(ctx).Warn("Link returned error status", "link", link, "status_code", resp.StatusCode)
+		return verdict
 	}
+	// Link is accessible
+	verdict.Accessible = true
+	return verdict
 }
@@ -1,9 +1,16 @@
 package parser
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"io"
+	"log/slog"
+	"lucytech/logging"
 	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -107,7 +114,7 @@ func TestRealAnalyzePage(t *testing.T) {
 	defer func() { httpClient = origClient }()
 
 	// Call the realAnalyzePage function using the mocked HTTP client and the test URL
-	result, err := realAnalyzePage(baseURL)
+	result, err := realAnalyzePage(context.Background(), baseURL, Options{})
 	if err != nil {
 		t.Fatalf("realAnalyzePage returned error: %v", err)
 	}
@@ -127,14 +134,22 @@ func TestRealAnalyzePage(t *testing.T) {
 		t.Error("LoginForm = false; want true")
 	}
 
-	// Confirm the counts of headings extracted from the HTML:
-	// h1 count should be 1
-	if got, want := result.Headings["H1"], 1; got != want {
-		t.Errorf("Headings[H1] = %d; want %d", got, want)
+	// Confirm the outline nests the h2 below the single h1
+	if got, want := len(result.Outline), 1; got != want {
+		t.Fatalf("len(Outline) = %d; want %d", got, want)
 	}
-	// h2 count should be 1
-	if got, want := result.Headings["H2"], 1; got != want {
-		t.Errorf("Headings[H2] = %d; want %d", got, want)
+	if got, want := result.Outline[0].Text, "Main Heading"; got != want {
+		t.Errorf("Outline[0].Text = %q; want %q", got, want)
+	}
+	if got, want := len(result.Outline[0].Children), 1; got != want {
+		t.Fatalf("len(Outline[0].Children) = %d; want %d", got, want)
+	}
+	if got, want := result.Outline[0].Children[0].Level, 2; got != want {
+		t.Errorf("Outline[0].Children[0].Level = %d; want %d", got, want)
+	}
+	// A well-formed outline has no issues
+	if len(result.OutlineIssues) != 0 {
+		t.Errorf("OutlineIssues = %v; want none", result.OutlineIssues)
 	}
 
 	// Check the number of internal links found (should be 1)
@@ -150,4 +165,70 @@ func TestRealAnalyzePage(t *testing.T) {
 	if got, want := result.InaccessibleLinks, 1; got != want {
 		t.Errorf("InaccessibleLinks = %d; want %d", got, want)
 	}
+
+	// Each link is listed in document order with its check outcome
+	wantLinks := []LinkResult{
+		{URL: baseURL + "/internal", Internal: true, Checked: true, Accessible: true, StatusCode: 200},
+		{URL: "https://external.com/page", Checked: true, StatusCode: 404},
+	}
+	if len(result.Links) != len(wantLinks) {
+		t.Fatalf("Links = %+v; want %+v", result.Links, wantLinks)
+	}
+	for i, want := range wantLinks {
+		if result.Links[i] != want {
+			t.Errorf("Links[%d] = %+v; want %+v", i, result.Links[i], want)
+		}
+	}
+}
+
+// TestRealAnalyzePage_RequestLogger checks that link-checking goroutines log with the request's logger
+func TestRealAnalyzePage_RequestLogger(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `<!DOCTYPE html><title>Logged</title><a href="/broken">Broken</a>`)
+	}))
+	defer ts.Close()
+
+	var buf syncBuffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil)).With("request_id", "req-1")
+	ctx := logging.WithLogger(context.Background(), logger)
+	if _, err := realAnalyzePage(ctx, ts.URL, Options{}); err != nil {
+		t.Fatalf("realAnalyzePage returned error: %v", err)
+	}
+
+	var found bool
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, "Link returned error status") {
+			found = true
+			if !strings.Contains(line, "request_id=req-1") {
+				t.Errorf("log line %q lacks the request ID", line)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no log line for the broken link in %q", buf.String())
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes from link-checking goroutines
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write implements io.Writer for syncBuffer
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// String returns the buffered output
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
 }
@@ -0,0 +1,145 @@
+package parser
+
+import (
+	"context"
+	"lucytech/logging"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// addAnchor records the fragment targets an element defines: its id, and the name
+// of an <a> element, as the HTML specification resolves fragments against both.
+func addAnchor(anchors map[string]bool, n *html.Node) {
+	for _, attr := range n.Attr {
+		if attr.Key == "id" || (attr.Key == "name" && n.Data == "a") {
+			if attr.Val != "" {
+				anchors[attr.Val] = true
+			}
+		}
+	}
+}
+
+// anchorsOf collects the fragment targets of a document.
+func anchorsOf(doc *html.Node) map[string]bool {
+	anchors := make(map[string]bool)
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			addAnchor(anchors, n)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	return anchors
+}
+
+// hasAnchor reports whether the fragment of u scrolls to a target among anchors.
+// Empty fragments, "top" and text fragments ("#:~:text=") need no target.
+func hasAnchor(anchors map[string]bool, u *url.URL) bool {
+	fragment := u.Fragment
+	if fragment == "" || strings.EqualFold(fragment, "top") || strings.HasPrefix(fragment, ":~:") {
+		return true
+	}
+	return anchors[fragment] || anchors[u.EscapedFragment()]
+}
+
+// htmlDocument reports whether a fetched page is an HTML document whose fragments name
+// elements. Without a Content-Type, e.g. for local files, the extension decides.
+func htmlDocument(page *Page) bool {
+	contentType := page.ContentType
+	if contentType == "" {
+		u, err := url.Parse(page.URL)
+		if err != nil {
+			return false
+		}
+		ext := path.Ext(u.Path)
+		if ext == "" {
+			return true // Directories and extensionless routes serve HTML
+		}
+		contentType = mime.TypeByExtension(ext)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// checkAnchors flags internal links whose fragment names no element of their target.
+// Fragments of the analyzed page are looked up in its own anchors, so they are checked
+// even when link checks are skipped. Other internal pages are fetched once each, if
+// check is set and the link itself is accessible; pages that cannot be fetched or are
+// not HTML are given the benefit of the doubt.
+func checkAnchors(ctx context.Context, result *AnalysisResult, base *url.URL, own map[string]bool, check bool) {
+	self := CanonicalURL(base.String())
+	targets := make(map[string][]int) // Other documents by canonical URL, with the links into them
+	for i := range result.Links {
+		lr := &result.Links[i]
+		u, err := url.Parse(lr.URL)
+		if err != nil || !lr.Internal || hasAnchor(nil, u) {
+			continue
+		}
+		switch key := CanonicalURL(lr.URL); {
+		case key == self:
+			lr.MissingAnchor = !hasAnchor(own, u)
+		case check && lr.Checked && lr.Accessible:
+			targets[key] = append(targets[key], i)
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, maxConcurrentRequests)
+	for key, indexes := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			anchors, ok := fetchAnchors(ctx, key)
+			if !ok {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, i := range indexes {
+				u, _ := url.Parse(result.Links[i].URL)
+				result.Links[i].MissingAnchor = !hasAnchor(anchors, u)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, lr := range result.Links {
+		if lr.MissingAnchor {
+			result.MissingAnchors++
+		}
+	}
+}
+
+// fetchAnchors fetches a linked document and collects its fragment targets. It returns
+// false if the document cannot be fetched or is not HTML.
+func fetchAnchors(ctx context.Context, rawURL string) (map[string]bool, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, false
+	}
+	page, err := tracedFetch(ctx, "fetch_anchors", fetcherFor(u), rawURL)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Unable to fetch linked page for anchor checks", "url", rawURL, "error", err)
+		return nil, false
+	}
+	if !htmlDocument(page) {
+		return nil, false
+	}
+	doc, err := parseDocument(ctx, page)
+	if err != nil {
+		return nil, false
+	}
+	return anchorsOf(doc), true
+}
@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newAnchorSite serves a page linking to fragments of itself, of another page and of a PDF
+func newAnchorSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body>
+<h2 id="intro">Intro</h2>
+<a href="#intro">ok</a> <a href="#missing">missing</a> <a href="#top">top</a> <a href="#:~:text=Intro">text</a>
+<a href="/docs#install">ok</a> <a href="/docs#gone">missing</a> <a href="/docs#caf%C3%A9">ok</a> <a href="/docs">no fragment</a>
+<a href="/guide.pdf#page=2">pdf</a>
+</body></html>`)
+	})
+	mux.HandleFunc("/docs", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><a name="install"></a><p id="café">Café</p></body></html>`)
+	})
+	mux.HandleFunc("/guide.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		fmt.Fprint(w, "%PDF-1.7")
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// missingAnchors lists the paths and fragments of the links flagged as missing their anchor
+func missingAnchors(result *AnalysisResult, base string) string {
+	var missing []string
+	for _, l := range result.Links {
+		if l.MissingAnchor {
+			missing = append(missing, strings.TrimPrefix(l.URL, base))
+		}
+	}
+	return strings.Join(missing, " ")
+}
+
+// TestCheckAnchors checks fragments against ids and <a name> on the page and on linked pages
+func TestCheckAnchors(t *testing.T) {
+	ts := newAnchorSite(t)
+	result, err := realAnalyzePage(context.Background(), ts.URL, Options{})
+	if err != nil {
+		t.Fatalf("realAnalyzePage returned error: %v", err)
+	}
+	if got := missingAnchors(result, ts.URL); got != "/#missing /docs#gone" || result.MissingAnchors != 2 {
+		t.Errorf("missing anchors = %q (%d); want #missing and /docs#gone", got, result.MissingAnchors)
+	}
+	if result.InaccessibleLinks != 0 {
+		t.Errorf("inaccessible links = %d; want missing anchors reported separately", result.InaccessibleLinks)
+	}
+}
+
+// TestCheckAnchors_SkipLinkChecks checks that only same-page fragments are verified without link checks
+func TestCheckAnchors_SkipLinkChecks(t *testing.T) {
+	ts := newAnchorSite(t)
+	result, err := realAnalyzePage(context.Background(), ts.URL, Options{SkipLinkChecks: true})
+	if err != nil {
+		t.Fatalf("realAnalyzePage returned error: %v", err)
+	}
+	if got := missingAnchors(result, ts.URL); got != "/#missing" {
+		t.Errorf("missing anchors = %q; want only #missing", got)
+	}
+}
@@ -0,0 +1,161 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// Supported assertion expectations.
+const (
+	ExpectExists = "exists" // At least one element must match the selector
+	ExpectAbsent = "absent" // No element may match the selector
+	ExpectCount  = "count"  // Exactly Count elements must match the selector
+	ExpectMin    = "min"    // At least Count elements must match the selector
+	ExpectMax    = "max"    // At most Count elements may match the selector
+)
+
+// Assertion is a declarative, site-specific check expressed as a CSS selector
+// and an expectation about how many elements in the document match it.
+type Assertion struct {
+	Name     string   `json:"name"`            // Human readable label shown in the report
+	Selector string   `json:"selector"`        // CSS selector evaluated against the parsed document
+	Expect   string   `json:"expect"`          // One of exists, absent, count, min or max
+	Count    int      `json:"count,omitempty"` // Reference count for count, min and max expectations
+	Hosts    []string `json:"hosts,omitempty"` // Restrict the assertion to these hosts; empty means all hosts
+}
+
+// AssertionResult reports the outcome of a single assertion for an analyzed page.
+type AssertionResult struct {
+	Assertion
+	Matches int    `json:"matches"`           // Number of elements matching the selector
+	Passed  bool   `json:"passed"`            // True if the expectation was satisfied
+	Message string `json:"message,omitempty"` // Explanation of a failure
+}
+
+// assertionConfig is the on-disk format of an assertions file.
+type assertionConfig struct {
+	Assertions []Assertion `json:"assertions"`
+}
+
+// LoadAssertions reads and validates assertions from a JSON file of the form
+// {"assertions": [{"name": "...", "selector": "...", "expect": "..."}]}.
+func LoadAssertions(path string) ([]Assertion, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read assertions file: %w", err)
+	}
+
+	var cfg assertionConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("decode assertions file: %w", err)
+	}
+
+	if err := ValidateAssertions(cfg.Assertions); err != nil {
+		return nil, err
+	}
+	slog.Info("Assertions loaded", "path", path, "count", len(cfg.Assertions))
+	return cfg.Assertions, nil
+}
+
+// ValidateAssertions checks that every assertion has a known expectation and a selector that compiles.
+func ValidateAssertions(assertions []Assertion) error {
+	for i, a := range assertions {
+		if _, err := cascadia.Compile(a.Selector); err != nil {
+			return fmt.Errorf("assertion %d (%s): invalid selector %q: %w", i, a.label(), a.Selector, err)
+		}
+		switch a.Expect {
+		case ExpectExists, ExpectAbsent, ExpectCount, ExpectMin, ExpectMax:
+		default:
+			return fmt.Errorf("assertion %d (%s): unknown expectation %q", i, a.label(), a.Expect)
+		}
+		if a.Count < 0 {
+			return fmt.Errorf("assertion %d (%s): count must not be negative", i, a.label())
+		}
+	}
+	return nil
+}
+
+// label returns the assertion name, falling back to its selector.
+func (a Assertion) label() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return a.Selector
+}
+
+// appliesTo reports whether the assertion should run for the given host.
+func (a Assertion) appliesTo(host string) bool {
+	if len(a.Hosts) == 0 {
+		return true
+	}
+	for _, h := range a.Hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateAssertions runs every applicable assertion against the document.
+func evaluateAssertions(doc *html.Node, host string, assertions []Assertion) []AssertionResult {
+	var results []AssertionResult
+	for _, a := range assertions {
+		if !a.appliesTo(host) {
+			continue
+		}
+		results = append(results, evaluateAssertion(doc, a))
+	}
+	return results
+}
+
+// evaluateAssertion counts selector matches and compares them against the expectation.
+func evaluateAssertion(doc *html.Node, a Assertion) AssertionResult {
+	res := AssertionResult{Assertion: a}
+	if a.Name == "" {
+		res.Name = a.label()
+	}
+
+	sel, err := cascadia.Compile(a.Selector)
+	if err != nil {
+		res.Message = fmt.Sprintf("invalid selector: %v", err)
+		return res
+	}
+	res.Matches = len(cascadia.QueryAll(doc, sel))
+
+	switch a.Expect {
+	case ExpectExists:
+		res.Passed = res.Matches > 0
+		if !res.Passed {
+			res.Message = "no element matches the selector"
+		}
+	case ExpectAbsent:
+		res.Passed = res.Matches == 0
+		if !res.Passed {
+			res.Message = fmt.Sprintf("expected no matches, found %d", res.Matches)
+		}
+	case ExpectCount:
+		res.Passed = res.Matches == a.Count
+		if !res.Passed {
+			res.Message = fmt.Sprintf("expected exactly %d matches, found %d", a.Count, res.Matches)
+		}
+	case ExpectMin:
+		res.Passed = res.Matches >= a.Count
+		if !res.Passed {
+			res.Message = fmt.Sprintf("expected at least %d matches, found %d", a.Count, res.Matches)
+		}
+	case ExpectMax:
+		res.Passed = res.Matches <= a.Count
+		if !res.Passed {
+			res.Message = fmt.Sprintf("expected at most %d matches, found %d", a.Count, res.Matches)
+		}
+	default:
+		res.Message = fmt.Sprintf("unknown expectation %q", a.Expect)
+	}
+	return res
+}
@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// TestEvaluateAssertions checks each expectation type against a small document
+func TestEvaluateAssertions(t *testing.T) {
+	const testHTML = `<!DOCTYPE html>
+<html>
+<head><meta name="description" content="A page"></head>
+<body>
+<h1>Only Heading</h1>
+<div class="debug-banner">debug</div>
+<footer><a href="/privacy-policy">Privacy</a></footer>
+</body>
+</html>`
+
+	doc, err := html.Parse(strings.NewReader(testHTML))
+	if err != nil {
+		t.Fatalf("failed to parse test HTML: %v", err)
+	}
+
+	assertions := []Assertion{
+		{Name: "single h1", Selector: "h1", Expect: ExpectCount, Count: 1},
+		{Name: "description", Selector: "meta[name=description]", Expect: ExpectExists},
+		{Name: "no debug banner", Selector: ".debug-banner", Expect: ExpectAbsent},
+		{Name: "privacy link", Selector: "footer a[href*=privacy]", Expect: ExpectExists},
+		{Name: "other site only", Selector: "h2", Expect: ExpectExists, Hosts: []string{"other.com"}},
+	}
+
+	results := evaluateAssertions(doc, "example.com", assertions)
+
+	// The host-restricted assertion must be skipped
+	if got, want := len(results), 4; got != want {
+		t.Fatalf("len(results) = %d; want %d", got, want)
+	}
+
+	// Only the debug banner assertion is expected to fail
+	for _, r := range results {
+		wantPassed := r.Name != "no debug banner"
+		if r.Passed != wantPassed {
+			t.Errorf("%s: Passed = %v; want %v (matches %d, message %q)", r.Name, r.Passed, wantPassed, r.Matches, r.Message)
+		}
+	}
+}
+
+// TestValidateAssertions ensures broken selectors and unknown expectations are rejected
+func TestValidateAssertions(t *testing.T) {
+	if err := ValidateAssertions([]Assertion{{Selector: "h1[", Expect: ExpectExists}}); err == nil {
+		t.Error("expected error for invalid selector")
+	}
+	if err := ValidateAssertions([]Assertion{{Selector: "h1", Expect: "sometimes"}}); err == nil {
+		t.Error("expected error for unknown expectation")
+	}
+	if err := ValidateAssertions([]Assertion{{Selector: "h1", Expect: ExpectCount, Count: 1}}); err != nil {
+		t.Errorf("unexpected error for valid assertion: %v", err)
+	}
+}
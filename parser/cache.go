@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"lucytech/cache"
+	"lucytech/logging"
+	"lucytech/metrics"
+	"maps"
+	"net/url"
+	"slices"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheConfig sets how long analysis data is reused. Zero durations disable the respective cache.
+type CacheConfig struct {
+	PageTTL         time.Duration // How long a page result is served without contacting the page's server
+	PageCacheSize   int           // Maximum number of cached page results; 0 means unbounded
+	LinkTTL         time.Duration // How long a successful link check is reused
+	LinkNegativeTTL time.Duration // How long a failed link check is reused; 0 means LinkTTL
+	LinkCacheSize   int           // Maximum number of cached link verdicts; 0 means unbounded
+}
+
+// cachedPage is an analysis result together with the document it was computed from,
+// which is reused when the server confirms the document has not changed.
+type cachedPage struct {
+	page   *Page
+	result *AnalysisResult
+}
+
+// revalidator is implemented by fetchers that support conditional requests.
+type revalidator interface {
+	Revalidate(ctx context.Context, rawURL, etag, lastModified string) (*Page, error)
+}
+
+var (
+	pageCache       = cache.New[string, *cachedPage](0, 0) // Page results keyed by URL and options
+	linkCache       = cache.New[string, LinkVerdict](0, 0) // Link check outcomes keyed by normalized URL, shared by all analyses
+	linkNegativeTTL time.Duration                          // Lifetime of failed link verdicts
+
+	analyses   singleflight.Group // Coalesces concurrent identical analyses
+	linkChecks singleflight.Group // Coalesces concurrent checks of the same link
+)
+
+// ConfigureCache sets up the page result and link verdict caches.
+// It is called once at startup, before any analysis runs.
+func ConfigureCache(cfg CacheConfig) {
+	pageCache = cache.New[string, *cachedPage](cfg.PageTTL, cfg.PageCacheSize)
+	linkCache = cache.New[string, LinkVerdict](cfg.LinkTTL, cfg.LinkCacheSize)
+	linkNegativeTTL = cfg.LinkNegativeTTL
+	if linkNegativeTTL <= 0 {
+		linkNegativeTTL = cfg.LinkTTL
+	}
+	slog.Info("Analysis cache configured",
+		"page_ttl", cfg.PageTTL, "page_cache_size", cfg.PageCacheSize,
+		"link_ttl", cfg.LinkTTL, "link_negative_ttl", linkNegativeTTL, "link_cache_size", cfg.LinkCacheSize)
+}
+
+// cacheable reports whether an analysis may be served from the page cache.
+// Only plain HTTP fetches qualify; uploaded, local and rendered documents are always analyzed afresh.
+func cacheable(u *url.URL, opts Options) bool {
+	return pageCache.Enabled() && opts.Fetcher == nil && !opts.Render && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// cacheKey identifies an analysis by its URL and every option that influences the result.
+func cacheKey(rawURL string, opts Options) string {
+	key, _ := json.Marshal(struct {
+		URL            string
+		SkipLinkChecks bool
+		Assertions     []Assertion
+	}{rawURL, opts.SkipLinkChecks, opts.Assertions})
+	return string(key)
+}
+
+// analyzeCached serves an analysis from the page cache. Stale entries are revalidated with
+// If-None-Match / If-Modified-Since; on 304 the cached document is analyzed again, so only
+// link verdicts that expired are rechecked. Concurrent identical requests share one analysis.
+func analyzeCached(ctx context.Context, parsedURL *url.URL, opts Options) (*AnalysisResult, error) {
+	rawURL := parsedURL.String()
+	key := cacheKey(rawURL, opts)
+
+	// The shared analysis must not be cancelled when the first caller goes away
+	sharedCtx := context.WithoutCancel(ctx)
+	v, err, shared := analyses.Do(key, func() (any, error) {
+		entry, fresh, ok := pageCache.Get(key)
+		if ok && fresh {
+			metrics.CacheRequests.WithLabelValues("page", "hit").Inc()
+			logging.FromContext(ctx).Debug("Serving analysis from cache", "url", rawURL)
+			return entry.result, nil
+		}
+
+		var page *Page
+		var err error
+		if r, canRevalidate := defaultFetcher.(revalidator); canRevalidate && ok {
+			fetchCtx, span := startSpan(sharedCtx, "revalidate", rawURL)
+			page, err = r.Revalidate(fetchCtx, rawURL, entry.page.ETag, entry.page.LastModified)
+			status := 0
+			if page != nil {
+				status = page.StatusCode
+			}
+			endSpan(span, status, err)
+		} else {
+			page, err = tracedFetch(sharedCtx, "fetch", defaultFetcher, rawURL)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if page.NotModified {
+			metrics.CacheRequests.WithLabelValues("page", "revalidated").Inc()
+			logging.FromContext(ctx).Debug("Cached page not modified", "url", rawURL)
+			page = entry.page
+		} else {
+			metrics.CacheRequests.WithLabelValues("page", "miss").Inc()
+		}
+
+		result, err := analyzeDocument(sharedCtx, parsedURL, page, opts)
+		if err != nil {
+			return nil, err
+		}
+		pageCache.Set(key, &cachedPage{page: page, result: result})
+		return result, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		logging.FromContext(ctx).Debug("Shared result of concurrent analysis", "url", rawURL)
+	}
+
+	// Hand out a copy so callers cannot modify the cached result
+	return cloneResult(v.(*AnalysisResult)), nil
+}
+
+// cloneResult returns a deep copy of a result, down to its links, outline and assertions.
+func cloneResult(r *AnalysisResult) *AnalysisResult {
+	c := *r
+	c.Outline = cloneHeadings(r.Outline)
+	c.OutlineIssues = slices.Clone(r.OutlineIssues)
+	c.OtherLinks = maps.Clone(r.OtherLinks)
+	c.RelLinks = maps.Clone(r.RelLinks)
+	c.Links = slices.Clone(r.Links)
+	c.Assertions = slices.Clone(r.Assertions)
+	for i := range c.Assertions {
+		c.Assertions[i].Hosts = slices.Clone(c.Assertions[i].Hosts)
+	}
+	if r.RenderDiff != nil {
+		diff := *r.RenderDiff
+		c.RenderDiff = &diff
+	}
+	return &c
+}
+
+// cloneHeadings returns a deep copy of an outline.
+func cloneHeadings(headings []*Heading) []*Heading {
+	if headings == nil {
+		return nil
+	}
+	c := make([]*Heading, len(headings))
+	for i, h := range headings {
+		copied := *h
+		copied.Children = cloneHeadings(h.Children)
+		c[i] = &copied
+	}
+	return c
+}
@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingServer serves a page with an ETag and a link, counting full GETs, 304s and HEADs
+type countingServer struct {
+	gets, notModified, heads atomic.Int32
+}
+
+// ServeHTTP implements http.Handler for countingServer
+func (s *countingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodHead:
+		s.heads.Add(1)
+	case r.Header.Get("If-None-Match") == `"v1"`:
+		s.notModified.Add(1)
+		w.WriteHeader(http.StatusNotModified)
+	default:
+		s.gets.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `<!DOCTYPE html><title>Cached</title><a href="/about">About</a>`)
+	}
+}
+
+// withCache enables the caches for the duration of a test
+func withCache(t *testing.T, cfg CacheConfig) {
+	t.Helper()
+	origPage, origLink := pageCache, linkCache
+	ConfigureCache(cfg)
+	t.Cleanup(func() { pageCache, linkCache = origPage, origLink })
+}
+
+// TestAnalyzeCached_Hit checks that fresh results and link verdicts are reused without network traffic
+func TestAnalyzeCached_Hit(t *testing.T) {
+	srv := &countingServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	withCache(t, CacheConfig{PageTTL: time.Hour, LinkTTL: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		result, err := realAnalyzePage(context.Background(), ts.URL, Options{})
+		if err != nil {
+			t.Fatalf("realAnalyzePage returned error: %v", err)
+		}
+		if result.Title != "Cached" {
+			t.Errorf("Title = %q; want %q", result.Title, "Cached")
+		}
+	}
+
+	if got := srv.gets.Load(); got != 1 {
+		t.Errorf("GET requests = %d; want 1", got)
+	}
+	if got := srv.heads.Load(); got != 1 {
+		t.Errorf("HEAD requests = %d; want 1", got)
+	}
+}
+
+// TestCloneResult checks that changes to a handed out result do not reach the cached one
+func TestCloneResult(t *testing.T) {
+	cached := &AnalysisResult{
+		Outline:       []*Heading{{Level: 1, Text: "Home", Children: []*Heading{{Level: 2, Text: "News"}}}},
+		OutlineIssues: []OutlineIssue{{Message: "skipped level"}},
+		OtherLinks:    map[string]int{"mailto": 1},
+		Links:         []LinkResult{{URL: "https://example.com/", Accessible: true}},
+		Assertions:    []AssertionResult{{Assertion: Assertion{Hosts: []string{"example.com"}}, Passed: true}},
+		RenderDiff:    &RenderDiff{},
+	}
+	want, _ := json.Marshal(cached)
+
+	c := cloneResult(cached)
+	c.Outline[0].Text = "Changed"
+	c.Outline[0].Children[0].Level = 3
+	c.OutlineIssues[0].Message = "changed"
+	c.OtherLinks["tel"] = 1
+	c.Links[0].Accessible = false
+	c.Assertions[0].Passed = false
+	c.Assertions[0].Hosts[0] = "changed.example"
+	c.RenderDiff.Raw.Links = 5
+
+	if got, _ := json.Marshal(cached); string(got) != string(want) {
+		t.Errorf("cached result = %s; want it unchanged: %s", got, want)
+	}
+}
+
+// TestAnalyzeCached_Revalidate checks that stale results are revalidated with If-None-Match
+func TestAnalyzeCached_Revalidate(t *testing.T) {
+	srv := &countingServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	// Page results go stale immediately while link verdicts stay fresh
+	withCache(t, CacheConfig{PageTTL: time.Nanosecond, LinkTTL: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		result, err := realAnalyzePage(context.Background(), ts.URL, Options{})
+		if err != nil {
+			t.Fatalf("realAnalyzePage returned error: %v", err)
+		}
+		if result.Title != "Cached" || result.InternalLinks != 1 {
+			t.Errorf("result = %+v; want cached document analyzed again", result)
+		}
+	}
+
+	if got := srv.gets.Load(); got != 1 {
+		t.Errorf("full GET requests = %d; want 1", got)
+	}
+	if got := srv.notModified.Load(); got != 2 {
+		t.Errorf("304 responses = %d; want 2", got)
+	}
+	if got := srv.heads.Load(); got != 1 {
+		t.Errorf("HEAD requests = %d; want 1", got)
+	}
+}
+
+// TestAnalyzeCached_Coalesce checks that concurrent identical analyses share a single fetch
+func TestAnalyzeCached_Coalesce(t *testing.T) {
+	release := make(chan struct{})
+	var gets atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+			<-release // Hold the first request until all callers are waiting on it
+		}
+		fmt.Fprint(w, `<title>Slow</title>`)
+	}))
+	defer ts.Close()
+	withCache(t, CacheConfig{PageTTL: time.Hour})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := realAnalyzePage(context.Background(), ts.URL, Options{}); err != nil {
+				t.Errorf("realAnalyzePage returned error: %v", err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := gets.Load(); got != 1 {
+		t.Errorf("GET requests = %d; want 1", got)
+	}
+}
@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Same-site modes, from the narrowest to the widest.
+const (
+	SiteHost       = "host"       // Only the page's own host, with or without "www."
+	SiteSubdomains = "subdomains" // The page's host and its subdomains, e.g. docs.example.com for example.com
+	SiteDomain     = "domain"     // Every host of the page's registrable domain per the public suffix list
+)
+
+// SameSite decides which links count as internal. Hosts are compared case-insensitively
+// and a leading "www." is ignored, so example.com and www.example.com are always the
+// same site.
+type SameSite struct {
+	Mode  string   // One of the Site constants; empty means SiteHost
+	Hosts []string // Further hosts treated as internal; "*.example.org" includes its subdomains
+}
+
+// SameSiteRules classify the links of every analysis; set once at startup.
+var SameSiteRules SameSite
+
+// ParseSameSite builds same-site rules from a mode and a comma-separated list of hosts,
+// as given on the command line.
+func ParseSameSite(mode, hosts string) (SameSite, error) {
+	s := SameSite{Mode: mode}
+	switch mode {
+	case "", SiteHost, SiteSubdomains, SiteDomain:
+	default:
+		return SameSite{}, fmt.Errorf("unknown same-site mode %q: want %s, %s or %s", mode, SiteHost, SiteSubdomains, SiteDomain)
+	}
+	for _, h := range strings.Split(hosts, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		wildcard, name := "", h
+		if rest, ok := strings.CutPrefix(h, "*."); ok {
+			wildcard, name = "*.", rest
+		}
+		ascii, err := asciiHost(name)
+		if err != nil || strings.ContainsAny(ascii, "/:*@") {
+			return SameSite{}, fmt.Errorf("invalid same-site host %q: want a host name such as docs.example.com or *.example.com", h)
+		}
+		s.Hosts = append(s.Hosts, wildcard+ascii)
+	}
+	return s, nil
+}
+
+// internal reports whether u belongs to the same site as the page at base. Non-default
+// ports must match for the page's own host; the wider rules compare host names only.
+// IDN hosts are compared in their ASCII form.
+func (s SameSite) internal(base, u *url.URL) bool {
+	if siteKey(u) == siteKey(base) {
+		return true
+	}
+	host := strings.TrimPrefix(siteHost(u), "www.")
+	own := strings.TrimPrefix(siteHost(base), "www.")
+	if host == "" {
+		return false
+	}
+
+	switch s.Mode {
+	case SiteSubdomains:
+		if strings.HasSuffix(host, "."+own) {
+			return true
+		}
+	case SiteDomain:
+		if net.ParseIP(host) == nil {
+			domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+			if err == nil && (own == domain || strings.HasSuffix(own, "."+domain)) {
+				return true
+			}
+		}
+	}
+	return slices.ContainsFunc(s.Hosts, func(h string) bool {
+		if suffix, ok := strings.CutPrefix(h, "*."); ok {
+			return host == suffix || strings.HasSuffix(host, "."+suffix)
+		}
+		return host == strings.TrimPrefix(h, "www.")
+	})
+}
+
+// siteKey identifies the host of a URL for same-host comparisons: in ASCII, without
+// a leading "www." and with the port only if it is not the scheme's default.
+func siteKey(u *url.URL) string {
+	host := strings.TrimPrefix(siteHost(u), "www.")
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	return host
+}
+
+// siteHost returns the lower-cased ASCII host name of u, or its lower-cased host name
+// if that is not a valid IDN.
+func siteHost(u *url.URL) string {
+	if host, err := asciiHost(u.Hostname()); err == nil {
+		return host
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// webSchemes are the link schemes that are classified as internal or external and checked.
+var webSchemes = map[string]bool{"http": true, "https": true, "file": true}
+
+// relKeywords are the rel attribute values recorded for links.
+var relKeywords = []string{"nofollow", "sponsored", "ugc", "noopener", "noreferrer"}
+
+// relOf returns the recognized keywords of rel attributes, space-separated in
+// relKeywords order.
+func relOf(rels ...string) string {
+	tokens := strings.Fields(strings.ToLower(strings.Join(rels, " ")))
+	var found []string
+	for _, k := range relKeywords {
+		if slices.Contains(tokens, k) {
+			found = append(found, k)
+		}
+	}
+	return strings.Join(found, " ")
+}
+
+// displayLink shortens data: URLs, which can embed whole files, to their media type.
+func displayLink(u *url.URL) string {
+	if u.Scheme == "data" {
+		if mediaType, _, ok := strings.Cut(u.Opaque, ","); ok {
+			return "data:" + mediaType + ",…"
+		}
+	}
+	return u.String()
+}
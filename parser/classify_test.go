@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+// TestSameSite checks each same-site mode and the extra hosts
+func TestSameSite(t *testing.T) {
+	base, _ := url.Parse("https://www.example.co.uk/pricing")
+	tests := []struct {
+		mode, hosts, link string
+		want              bool
+	}{
+		{SiteHost, "", "https://EXAMPLE.co.uk/", true},
+		{SiteHost, "", "http://www.example.co.uk:80/", true},
+		{SiteHost, "", "https://www.example.co.uk:8443/", false},
+		{SiteHost, "", "https://docs.example.co.uk/", false},
+		{SiteSubdomains, "", "https://docs.example.co.uk/", true},
+		{SiteSubdomains, "", "https://shop.co.uk/", false},
+		{SiteDomain, "", "https://static.cdn.example.co.uk/", true},
+		{SiteDomain, "", "https://other.co.uk/", false},
+		{SiteHost, "cdn.example.net, *.example.org", "https://cdn.example.net/app.js", true},
+		{SiteHost, "cdn.example.net, *.example.org", "https://a.b.example.org/", true},
+		{SiteHost, "cdn.example.net, *.example.org", "https://example.org.evil.com/", false},
+	}
+	for _, tt := range tests {
+		s, err := ParseSameSite(tt.mode, tt.hosts)
+		if err != nil {
+			t.Fatalf("ParseSameSite(%q, %q) returned error: %v", tt.mode, tt.hosts, err)
+		}
+		u, _ := url.Parse(tt.link)
+		if got := s.internal(base, u); got != tt.want {
+			t.Errorf("%s %q: internal(%s) = %v; want %v", tt.mode, tt.hosts, tt.link, got, tt.want)
+		}
+	}
+
+	for _, bad := range [][2]string{{"site", ""}, {SiteHost, "https://example.com"}, {SiteHost, "a.*.example.com"}} {
+		if _, err := ParseSameSite(bad[0], bad[1]); err == nil {
+			t.Errorf("ParseSameSite(%q, %q) accepted invalid rules", bad[0], bad[1])
+		}
+	}
+}
+
+// TestCountLinks_SchemesAndRel checks that non-web links are counted by scheme without
+// being requested, and that rel keywords are merged across repeated links
+func TestCountLinks_SchemesAndRel(t *testing.T) {
+	var requests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { requests.Add(1) }))
+	defer ts.Close()
+	base, _ := url.Parse(ts.URL + "/")
+
+	result := &AnalysisResult{}
+	countLinks(context.Background(), result, base, []pageLink{
+		{href: "/about", rel: relOf("NoFollow external")},
+		{href: "/about", rel: relOf("ugc")},
+		{href: "mailto:team@example.com"},
+		{href: "MAILTO:sales@example.com"},
+		{href: "tel:+441234567890"},
+		{href: "javascript:void(0)"},
+		{href: "data:text/plain;base64,SGVsbG8sIFdvcmxkIQ==", rel: relOf("noopener")},
+	}, true)
+
+	if requests.Load() != 1 || result.InternalLinks != 1 || result.ExternalLinks != 0 || result.InaccessibleLinks != 0 {
+		t.Errorf("%d requests, %d internal, %d external, %d inaccessible; want only /about checked",
+			requests.Load(), result.InternalLinks, result.ExternalLinks, result.InaccessibleLinks)
+	}
+	if got := result.OtherLinks; got["mailto"] != 2 || got["tel"] != 1 || got["javascript"] != 1 || got["data"] != 1 {
+		t.Errorf("other links = %v; want 2 mailto and one each of tel, javascript and data", got)
+	}
+	if l := result.Links[0]; l.Rel != "nofollow ugc" || !l.Checked {
+		t.Errorf("/about = %+v; want the merged rel keywords", l)
+	}
+	if l := result.Links[len(result.Links)-1]; l.URL != "data:text/plain;base64,…" || l.Scheme != "data" || l.Checked {
+		t.Errorf("data link = %+v; want it shortened and unchecked", l)
+	}
+	if got := result.RelLinks; len(got) != 3 || got["nofollow"] != 1 || got["ugc"] != 1 || got["noopener"] != 1 {
+		t.Errorf("rel links = %v; want nofollow, ugc and noopener once each", got)
+	}
+}
@@ -0,0 +1,31 @@
+package parser
+
+import "errors"
+
+// Sentinel errors wrapped by analysis failures, so callers can tell failure kinds apart
+// with errors.Is while the messages stay human readable.
+var (
+	ErrInvalidURL  = errors.New("invalid URL")           // The submitted URL could not be parsed
+	ErrUnreachable = errors.New("unable to reach URL")   // The request failed before a response arrived
+	ErrHTTPStatus  = errors.New("HTTP error")            // The server answered with a 400+ status
+	ErrParse       = errors.New("failed to parse HTML")  // The document could not be parsed
+	ErrRender      = errors.New("unable to render page") // The headless browser failed
+)
+
+// errorType returns a bounded label describing the kind of an analysis error.
+func errorType(err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidURL):
+		return "invalid_url"
+	case errors.Is(err, ErrUnreachable):
+		return "unreachable"
+	case errors.Is(err, ErrHTTPStatus):
+		return "http_status"
+	case errors.Is(err, ErrParse):
+		return "parse"
+	case errors.Is(err, ErrRender):
+		return "render"
+	default:
+		return "other"
+	}
+}
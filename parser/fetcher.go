@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"lucytech/logging"
+	"lucytech/metrics"
+	"net/http"
+	"time"
+)
+
+// maxPageSize caps how many bytes of a fetched document are read into memory.
+const maxPageSize = 10 << 20 // 10 MiB
+
+// Page is a fetched document ready to be parsed.
+type Page struct {
+	URL         string // URL the document was fetched from
+	StatusCode  int    // HTTP status code, 0 if the source has no notion of status
+	Body        []byte // Raw HTML of the document
+	ContentType string // Content-Type header, if the source has one
+
+	ETag         string // Validator for conditional requests, if the server sent one
+	LastModified string // Last-Modified header, if the server sent one
+	NotModified  bool   // True if a conditional request was answered with 304 and Body is empty
+}
+
+// Fetcher retrieves the HTML document for a URL. Implementations decide how the
+// document is obtained, e.g. a plain HTTP GET or a headless browser rendering.
+type Fetcher interface {
+	Fetch(ctx context.Context, rawURL string) (*Page, error)
+}
+
+// HTTPFetcher fetches documents with a plain HTTP GET request.
+type HTTPFetcher struct {
+	Client *http.Client // Client used for the request; nil means the package httpClient
+}
+
+// Fetch performs the GET request and treats HTTP 400+ responses as errors.
+func (f *HTTPFetcher) Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	return f.Revalidate(ctx, rawURL, "", "")
+}
+
+// Revalidate performs a conditional GET using the validators of a previously fetched page.
+// If the server answers 304 Not Modified, the returned page has NotModified set and no body.
+// Empty validators make it a plain GET.
+func (f *HTTPFetcher) Revalidate(ctx context.Context, rawURL, etag, lastModified string) (*Page, error) {
+	client := f.Client
+	if client == nil {
+		client = httpClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		metrics.FetchStatus.WithLabelValues("error").Inc()
+		metrics.FetchDuration.WithLabelValues("error").Observe(time.Since(start).Seconds())
+		logging.FromContext(ctx).Error("Failed to fetch URL", "error", err, "url", rawURL)
+		return nil, fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+	defer resp.Body.Close()
+
+	statusClass := metrics.StatusClass(resp.StatusCode)
+	metrics.FetchStatus.WithLabelValues(statusClass).Inc()
+	defer func() {
+		// Latency includes reading the body
+		metrics.FetchDuration.WithLabelValues(statusClass).Observe(time.Since(start).Seconds())
+	}()
+
+	logging.FromContext(ctx).Debug("Fetched URL", "status_code", resp.StatusCode)
+	if resp.StatusCode == http.StatusNotModified && (etag != "" || lastModified != "") {
+		return &Page{URL: rawURL, StatusCode: resp.StatusCode, ETag: etag, LastModified: lastModified, NotModified: true}, nil
+	}
+	if resp.StatusCode >= 400 {
+		logging.FromContext(ctx).Warn("Received HTTP error status from server", "status_code", resp.StatusCode)
+		return nil, fmt.Errorf("%w: %d %s", ErrHTTPStatus, resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to read response body", "error", err, "url", rawURL)
+		return nil, fmt.Errorf("%w: unable to read response: %w", ErrUnreachable, err)
+	}
+	metrics.PageSize.Observe(float64(len(body)))
+
+	return &Page{
+		URL:          rawURL,
+		StatusCode:   resp.StatusCode,
+		Body:         body,
+		ContentType:  resp.Header.Get("Content-Type"),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
@@ -0,0 +1,192 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// asciiHost returns the lower-cased ASCII form of a host name: internationalized
+// domain names such as bücher.example become their punycode form, xn--bcher-kva.example.
+func asciiHost(host string) (string, error) {
+	if isASCII(host) {
+		return strings.ToLower(host), nil
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(ascii), nil
+}
+
+// asciiURL returns u with an ASCII host and a percent-encoded path and query, the form
+// in which it is fetched and compared. It fails for host names that are not valid IDNs.
+func asciiURL(u *url.URL) (*url.URL, error) {
+	c := *u
+	host, err := asciiHost(c.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+	if port := c.Port(); port != "" {
+		host += ":" + port
+	}
+	c.Host = host
+	setEscapedPath(&c, normalizeEscapes(c.EscapedPath()))
+	c.RawQuery = normalizeEscapes(escapeNonASCII(c.RawQuery))
+	return &c, nil
+}
+
+// setEscapedPath sets the path of u from its percent-encoded form, keeping escapes
+// such as %2F that decode to reserved characters.
+func setEscapedPath(u *url.URL, escaped string) {
+	if path, err := url.PathUnescape(escaped); err == nil {
+		u.Path, u.RawPath = path, escaped
+	}
+}
+
+// normalizeEscapes puts percent-encoding into its canonical form (RFC 3986, 6.2.2):
+// escapes of unreserved characters are decoded and the hex digits of the others are
+// upper-cased, so "%7Efoo%2f" becomes "~foo%2F".
+func normalizeEscapes(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			c := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if unreserved(c) {
+				b.WriteByte(c)
+			} else {
+				b.WriteString("%" + strings.ToUpper(s[i+1:i+3]))
+			}
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// escapeNonASCII percent-encodes the bytes of s outside ASCII, e.g. in a query that a
+// page wrote with raw UTF-8.
+func escapeNonASCII(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			fmt.Fprintf(&b, "%%%02X", s[i])
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// unicodeURL returns the form of a URL for people to read: the Unicode form of its IDN
+// host and its percent-encoded non-ASCII text decoded. It returns "" if that is the same
+// as the URL, so it is only shown when it helps.
+func unicodeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Opaque != "" {
+		return ""
+	}
+	if host := u.Hostname(); strings.Contains(host, "xn--") {
+		if display, err := idna.Display.ToUnicode(host); err == nil {
+			if port := u.Port(); port != "" {
+				display += ":" + port
+			}
+			u.Host = display
+		}
+	}
+	display := decodeUnicode(u.String())
+	if display == rawURL {
+		return ""
+	}
+	return display
+}
+
+// decodeUnicode decodes runs of percent-escapes that spell printable non-ASCII text,
+// leaving escaped ASCII, spaces and invalid UTF-8 as they are.
+func decodeUnicode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		// Collect a run of escaped bytes outside ASCII
+		var run []byte
+		j := i
+		for j+2 < len(s) && s[j] == '%' && isHex(s[j+1]) && isHex(s[j+2]) {
+			c := unhex(s[j+1])<<4 | unhex(s[j+2])
+			if c < utf8.RuneSelf {
+				break
+			}
+			run = append(run, c)
+			j += 3
+		}
+		if len(run) == 0 {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		if printable(run) {
+			b.Write(run)
+		} else {
+			b.WriteString(s[i:j])
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// printable reports whether b is valid UTF-8 of visible characters only.
+func printable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsGraphic(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isASCII reports whether s contains only ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// unreserved reports whether c may appear in a URL without escaping (RFC 3986, 2.3).
+func unreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// isHex reports whether c is a hexadecimal digit.
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// unhex returns the value of the hexadecimal digit c.
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestNormalizer_IDN checks that IDN hosts become punycode and escapes are made uniform
+func TestNormalizer_IDN(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://Bücher.example/Straße?q=café", "https://xn--bcher-kva.example/Stra%C3%9Fe?q=caf%C3%A9"},
+		{"https://xn--bcher-kva.example/Stra%c3%9fe", "https://xn--bcher-kva.example/Stra%C3%9Fe"},
+		{"https://example.com/%7euser/a%2fb?x=%41%2f", "https://example.com/~user/a%2Fb?x=A%2F"},
+		{"https://bad_host.example/", "https://bad_host.example/"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.in)
+		if got := NormalizeURL(u).String(); got != tt.want {
+			t.Errorf("NormalizeURL(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestUnicodeURL checks the readable form of ASCII URLs
+func TestUnicodeURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://xn--bcher-kva.example/Stra%C3%9Fe?q=caf%C3%A9", "https://bücher.example/Straße?q=café"},
+		{"https://xn--bcher-kva.example:8443/", "https://bücher.example:8443/"},
+		{"https://example.com/a%20b%2F%E2%80%8B%FF", ""}, // Spaces, reserved, invisible and invalid bytes stay escaped
+		{"mailto:team@example.com", ""},
+	}
+	for _, tt := range tests {
+		if got := unicodeURL(tt.in); got != tt.want {
+			t.Errorf("unicodeURL(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestSameSite_IDN checks that Unicode and punycode spellings of a host are one site
+func TestSameSite_IDN(t *testing.T) {
+	base, _ := url.Parse("https://bücher.example/")
+	for _, link := range []string{"https://xn--bcher-kva.example/a", "https://BÜCHER.example/b"} {
+		u, _ := url.Parse(link)
+		if !SameSiteRules.internal(base, u) {
+			t.Errorf("internal(%s) = false; want true", link)
+		}
+	}
+
+	s, err := ParseSameSite(SiteHost, "*.münchen.example")
+	if err != nil || len(s.Hosts) != 1 || s.Hosts[0] != "*.xn--mnchen-3ya.example" {
+		t.Errorf("ParseSameSite = %+v, %v; want the punycode host", s, err)
+	}
+}
+
+// TestRealAnalyzePage_NonASCII checks that a Unicode URL is fetched percent-encoded and
+// reported in both forms, and that the scheme is recognized in any case
+func TestRealAnalyzePage_NonASCII(t *testing.T) {
+	var requested string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.EscapedPath()
+		io.WriteString(w, `<!DOCTYPE html><title>Café</title><a href="/men%c3%bc">Menü</a>`)
+	}))
+	defer ts.Close()
+
+	result, err := realAnalyzePage(context.Background(), " "+strings.ToUpper(ts.URL[:4])+ts.URL[4:]+"/café", Options{SkipLinkChecks: true})
+	if err != nil {
+		t.Fatalf("realAnalyzePage returned error: %v", err)
+	}
+	if requested != "/caf%C3%A9" || result.URL != ts.URL+"/caf%C3%A9" || result.DisplayURL != ts.URL+"/café" {
+		t.Errorf("requested %q; URL = %q, display URL = %q", requested, result.URL, result.DisplayURL)
+	}
+	if l := result.Links[0]; l.URL != ts.URL+"/men%C3%BC" || l.DisplayURL != ts.URL+"/menü" || !l.Internal {
+		t.Errorf("link = %+v; want both forms of /menü", l)
+	}
+}
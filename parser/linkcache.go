@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"context"
+	"lucytech/metrics"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/net/publicsuffix"
+)
+
+// LinkVerdict is the outcome of checking a single link.
+type LinkVerdict struct {
+	Accessible bool      `json:"accessible"`            // True if the link could be reached without an HTTP error
+	StatusCode int       `json:"status_code,omitempty"` // HTTP status of the check, 0 if no response was received
+	Error      string    `json:"error,omitempty"`       // Transport error, if the check failed before a response
+	CheckedAt  time.Time `json:"checked_at"`            // When the check was performed
+}
+
+// LinkCacheEntry describes a cached link verdict for inspection.
+type LinkCacheEntry struct {
+	URL       string      `json:"url"`        // Normalized link URL
+	Verdict   LinkVerdict `json:"verdict"`    // Cached outcome
+	ExpiresAt time.Time   `json:"expires_at"` // When the verdict goes stale
+	Fresh     bool        `json:"fresh"`      // False once the verdict has expired
+}
+
+// LinkCacheStats summarizes the shared link verdict cache.
+type LinkCacheStats struct {
+	Enabled    bool `json:"enabled"`     // False if link caching is turned off
+	Size       int  `json:"size"`        // Number of cached verdicts
+	MaxEntries int  `json:"max_entries"` // Size limit, 0 meaning unbounded
+}
+
+// checkLink checks a link, reusing a fresh verdict from the process-wide link cache when available.
+// Failed checks are cached with the shorter negative TTL so broken links are retried sooner.
+func checkLink(ctx context.Context, link string) LinkVerdict {
+	ctx, span := startSpan(ctx, "check_link", link)
+	key := CanonicalURL(link)
+	if verdict, fresh, ok := linkCache.Get(key); ok && fresh {
+		metrics.CacheRequests.WithLabelValues("link", "hit").Inc()
+		span.SetAttributes(attribute.Bool("link.cached", true), attribute.Bool("link.accessible", verdict.Accessible))
+		endSpan(span, verdict.StatusCode, nil)
+		return verdict
+	}
+	if linkCache.Enabled() {
+		metrics.CacheRequests.WithLabelValues("link", "miss").Inc()
+	}
+
+	// The shared check must not be cancelled when the first caller goes away
+	v, _, _ := linkChecks.Do(key, func() (any, error) {
+		verdict := probeLink(context.WithoutCancel(ctx), link)
+		if verdict.Accessible {
+			linkCache.Set(key, verdict)
+		} else {
+			linkCache.SetWithTTL(key, verdict, linkNegativeTTL)
+		}
+		return verdict, nil
+	})
+	verdict := v.(LinkVerdict)
+	span.SetAttributes(attribute.Bool("link.cached", false), attribute.Bool("link.accessible", verdict.Accessible))
+	endSpan(span, verdict.StatusCode, nil)
+	return verdict
+}
+
+// LinkCacheEntries returns a snapshot of the cached link verdicts, most recently used first.
+func LinkCacheEntries() []LinkCacheEntry {
+	entries := linkCache.Entries()
+	out := make([]LinkCacheEntry, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, LinkCacheEntry{URL: e.Key, Verdict: e.Value, ExpiresAt: e.Expires, Fresh: e.Fresh})
+	}
+	return out
+}
+
+// LinkCacheInfo reports the size and limits of the link cache.
+func LinkCacheInfo() LinkCacheStats {
+	return LinkCacheStats{Enabled: linkCache.Enabled(), Size: linkCache.Len(), MaxEntries: linkCache.MaxEntries()}
+}
+
+// PurgeLinkCache removes the verdict for link, or every verdict if link is empty,
+// and returns the number of removed entries.
+func PurgeLinkCache(link string) int {
+	if link == "" {
+		return linkCache.Purge()
+	}
+	if linkCache.Delete(CanonicalURL(link)) {
+		return 1
+	}
+	return 0
+}
+
+// registrableDomain reduces a link to its registrable domain (e.g. "example.co.uk")
+// so it can be used as a metric label without unbounded cardinality.
+func registrableDomain(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.Hostname() == "" {
+		return "none"
+	}
+	host := strings.ToLower(u.Hostname())
+	if net.ParseIP(host) != nil {
+		return "ip"
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host // e.g. "localhost" or a bare public suffix
+	}
+	return domain
+}
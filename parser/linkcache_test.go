@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestCheckLink_SharedCache checks that verdicts are shared across spellings and can be purged
+func TestCheckLink_SharedCache(t *testing.T) {
+	var heads atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		heads.Add(1)
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	withCache(t, CacheConfig{LinkTTL: time.Hour, LinkNegativeTTL: time.Hour, LinkCacheSize: 10})
+
+	checkLink(context.Background(), ts.URL+"/ok")
+	checkLink(context.Background(), ts.URL+"/ok#section")
+	if got := heads.Load(); got != 1 {
+		t.Errorf("HEAD requests = %d; want 1", got)
+	}
+
+	// Failures are cached as well, with their status code
+	if v := checkLink(context.Background(), ts.URL+"/broken"); v.Accessible || v.StatusCode != http.StatusNotFound {
+		t.Errorf("verdict = %+v; want inaccessible 404", v)
+	}
+	if got := len(LinkCacheEntries()); got != 2 {
+		t.Errorf("len(LinkCacheEntries()) = %d; want 2", got)
+	}
+
+	// Purging a single link forces it to be checked again
+	if n := PurgeLinkCache(ts.URL + "/ok"); n != 1 {
+		t.Errorf("PurgeLinkCache = %d; want 1", n)
+	}
+	checkLink(context.Background(), ts.URL+"/ok")
+	if got := heads.Load(); got != 3 {
+		t.Errorf("HEAD requests = %d; want 3", got)
+	}
+}
+
+// TestCheckLink_NegativeTTL checks that failed checks expire sooner than successful ones
+func TestCheckLink_NegativeTTL(t *testing.T) {
+	var heads atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		heads.Add(1)
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+	withCache(t, CacheConfig{LinkTTL: time.Hour, LinkNegativeTTL: time.Nanosecond})
+
+	for i := 0; i < 2; i++ {
+		checkLink(context.Background(), ts.URL+"/ok")
+		checkLink(context.Background(), ts.URL+"/broken")
+	}
+
+	// The broken link is checked twice, the working one only once
+	if got := heads.Load(); got != 3 {
+		t.Errorf("HEAD requests = %d; want 3", got)
+	}
+}
@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"context"
+	"lucytech/metrics"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestRegistrableDomain checks that metric labels are reduced to registrable domains
+func TestRegistrableDomain(t *testing.T) {
+	tests := map[string]string{
+		"https://www.example.com/a":     "example.com",
+		"https://shop.example.co.uk/":   "example.co.uk",
+		"http://127.0.0.1:8080/":        "ip",
+		"http://localhost/":             "localhost",
+		"mailto:someone@example.com":    "none",
+		"https://deep.sub.example.org/": "example.org",
+	}
+	for link, want := range tests {
+		if got := registrableDomain(link); got != want {
+			t.Errorf("registrableDomain(%q) = %q; want %q", link, got, want)
+		}
+	}
+}
+
+// TestRealAnalyzePage_Metrics checks that failures are counted by type and fetch status is recorded
+func TestRealAnalyzePage_Metrics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	httpErrors := testutil.ToFloat64(metrics.AnalysisErrors.WithLabelValues("http_status"))
+	fetch4xx := testutil.ToFloat64(metrics.FetchStatus.WithLabelValues("4xx"))
+
+	if _, err := realAnalyzePage(context.Background(), ts.URL, Options{}); err == nil {
+		t.Fatal("expected error for 404 page")
+	}
+
+	if got := testutil.ToFloat64(metrics.AnalysisErrors.WithLabelValues("http_status")) - httpErrors; got != 1 {
+		t.Errorf("http_status errors increased by %v; want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.FetchStatus.WithLabelValues("4xx")) - fetch4xx; got != 1 {
+		t.Errorf("4xx fetches increased by %v; want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.AnalysesInFlight); got != 0 {
+		t.Errorf("analyses in flight = %v; want 0", got)
+	}
+}
@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Normalizer puts URLs into the canonical form in which links are compared, checked,
+// cached and crawled, so that different spellings of one address are treated as one.
+// The scheme and host are lower-cased, IDN hosts are converted to punycode, default
+// ports and fragments are dropped, percent-encoding is made uniform, dot segments are
+// resolved and an empty path becomes "/". Reordering and stripping query
+// parameters can change what some servers return, so they are opt-in.
+type Normalizer struct {
+	SortQuery     bool // Sort query parameters by name, e.g. "?b=2&a=1" becomes "?a=1&b=2"
+	StripTracking bool // Drop tracking parameters such as utm_source, gclid and fbclid
+}
+
+// URLNormalizer is applied wherever links are compared; set once at startup.
+var URLNormalizer Normalizer
+
+// trackingParams are the query parameters removed with StripTracking, besides every
+// parameter starting with "utm_".
+var trackingParams = []string{
+	"gclid", "gbraid", "wbraid", "dclid", "fbclid", "msclkid", "yclid", "twclid", "igshid",
+	"mc_cid", "mc_eid", "_ga", "_gl", "mkt_tok",
+}
+
+// Normalize returns the canonical form of u without modifying it. URLs without a host,
+// such as mailto: links, only have their scheme lower-cased and fragment dropped.
+func (n Normalizer) Normalize(u *url.URL) *url.URL {
+	c := *u
+	c.Scheme = strings.ToLower(c.Scheme)
+	c.Fragment, c.RawFragment = "", ""
+	if c.Opaque != "" {
+		return &c
+	}
+
+	// IDN hosts become punycode and percent-encoding is made uniform; hosts that are not
+	// valid IDNs are only lower-cased, and fail when they are checked
+	if ascii, err := asciiURL(&c); err == nil {
+		c = *ascii
+	} else {
+		c.Host = strings.ToLower(c.Host)
+	}
+	if port := c.Port(); (c.Scheme == "http" && port == "80") || (c.Scheme == "https" && port == "443") {
+		c.Host = strings.TrimSuffix(c.Host, ":"+port)
+	}
+
+	// Resolving an empty reference removes the dot segments of an absolute path
+	if strings.HasPrefix(c.Path, "/") {
+		c = *c.ResolveReference(&url.URL{})
+	}
+	if c.Path == "" && c.Host != "" {
+		c.Path, c.RawPath = "/", ""
+	}
+
+	c.RawQuery = n.query(c.RawQuery)
+	if c.RawQuery == "" {
+		c.ForceQuery = false
+	}
+	return &c
+}
+
+// query applies the query options to a raw query, keeping the original encoding of
+// the remaining parameters and the order of repeated ones.
+func (n Normalizer) query(raw string) string {
+	if raw == "" || (!n.SortQuery && !n.StripTracking) {
+		return raw
+	}
+	var params []string
+	for _, p := range strings.Split(raw, "&") {
+		if p == "" || (n.StripTracking && tracking(paramName(p))) {
+			continue
+		}
+		params = append(params, p)
+	}
+	if n.SortQuery {
+		slices.SortStableFunc(params, func(a, b string) int { return strings.Compare(paramName(a), paramName(b)) })
+	}
+	return strings.Join(params, "&")
+}
+
+// paramName returns the decoded name of a raw "name=value" query parameter.
+func paramName(p string) string {
+	name, _, _ := strings.Cut(p, "=")
+	if decoded, err := url.QueryUnescape(name); err == nil {
+		return decoded
+	}
+	return name
+}
+
+// tracking reports whether a query parameter only identifies a campaign or click.
+func tracking(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasPrefix(name, "utm_") || slices.Contains(trackingParams, name)
+}
+
+// NormalizeURL returns the canonical form of u under URLNormalizer.
+func NormalizeURL(u *url.URL) *url.URL {
+	return URLNormalizer.Normalize(u)
+}
+
+// CanonicalURL returns the canonical form of a link under URLNormalizer, or the link
+// unchanged if it cannot be parsed.
+func CanonicalURL(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return NormalizeURL(u).String()
+}
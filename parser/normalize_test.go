@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+// TestNormalizer checks that equivalent spellings of a link normalize to the same URL
+func TestNormalizer(t *testing.T) {
+	tests := []struct {
+		n        Normalizer
+		in, want string
+	}{
+		{Normalizer{}, "HTTPS://Example.COM/a", "https://example.com/a"},
+		{Normalizer{}, "https://example.com:443/a#top", "https://example.com/a"},
+		{Normalizer{}, "http://example.com:80", "http://example.com/"},
+		{Normalizer{}, "http://example.com:8080/a", "http://example.com:8080/a"},
+		{Normalizer{}, "http://[::1]:80/a", "http://[::1]/a"},
+		{Normalizer{}, "https://example.com/docs/./guide/../a/?", "https://example.com/docs/a/"},
+		{Normalizer{}, "https://example.com/a?b=2&a=1&utm_source=x", "https://example.com/a?b=2&a=1&utm_source=x"},
+		{Normalizer{SortQuery: true}, "https://example.com/a?b=2&a=1&b=1", "https://example.com/a?a=1&b=2&b=1"},
+		{Normalizer{StripTracking: true}, "https://example.com/a?utm_source=x&id=7&UTM_Medium=y&fbclid=z", "https://example.com/a?id=7"},
+		{Normalizer{StripTracking: true}, "https://example.com/a?gclid=1", "https://example.com/a"},
+		{Normalizer{}, "MAILTO:team@example.com", "mailto:team@example.com"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.in)
+		before := u.String()
+		if got := tt.n.Normalize(u).String(); got != tt.want {
+			t.Errorf("%+v.Normalize(%q) = %q; want %q", tt.n, tt.in, got, tt.want)
+		}
+		if u.String() != before {
+			t.Errorf("Normalize(%q) modified its argument", tt.in)
+		}
+	}
+}
+
+// TestCountLinks_Normalized checks that spellings of one link are merged and that links
+// differing only in their fragment are checked once
+func TestCountLinks_Normalized(t *testing.T) {
+	var heads atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { heads.Add(1) }))
+	defer ts.Close()
+	base, _ := url.Parse(ts.URL + "/docs/")
+
+	result := &AnalysisResult{}
+	countLinks(context.Background(), result, base, []pageLink{
+		{href: "../a"},
+		{href: "./../a"},
+		{href: ts.URL + "/a"},
+		{href: "/a#install"},
+		{href: "/x/../a#install"},
+	}, true)
+
+	if len(result.Links) != 2 || result.Links[0].URL != ts.URL+"/a" || result.Links[1].URL != ts.URL+"/a#install" {
+		t.Fatalf("links = %+v; want /a and /a#install", result.Links)
+	}
+	if heads.Load() != 1 || !result.Links[1].Checked || !result.Links[1].Accessible {
+		t.Errorf("%d requests, links = %+v; want one check shared by both links", heads.Load(), result.Links)
+	}
+}
@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxHeadingLength is the number of characters above which a heading is flagged as too long.
+const maxHeadingLength = 120
+
+// Heading is a node in the document outline.
+type Heading struct {
+	Level    int        `json:"level"`              // Heading level from 1 (H1) to 6 (H6)
+	Text     string     `json:"text"`               // Whitespace-normalized text content
+	Position int        `json:"position"`           // 1-based position of the heading in document order
+	Children []*Heading `json:"children,omitempty"` // Headings nested below this one
+}
+
+// Tag returns the heading tag name, e.g. "H2".
+func (h *Heading) Tag() string {
+	return fmt.Sprintf("H%d", h.Level)
+}
+
+// OutlineIssue describes a structural problem found in the heading outline.
+type OutlineIssue struct {
+	Position int    `json:"position,omitempty"` // Position of the offending heading; 0 for document-wide issues
+	Message  string `json:"message"`            // Human readable description of the problem
+}
+
+// headingLevel returns the level of an h1-h6 element, or 0 if n is not a heading.
+func headingLevel(n *html.Node) int {
+	if n.Type == html.ElementNode && len(n.Data) == 2 && n.Data[0] == 'h' && n.Data[1] >= '1' && n.Data[1] <= '6' {
+		return int(n.Data[1] - '0')
+	}
+	return 0
+}
+
+// textContent returns the concatenated text of n and its descendants with whitespace collapsed.
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+			sb.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// buildOutline nests the headings (given in document order) into a tree and
+// reports skipped levels, empty or overly long headings and H1 problems.
+func buildOutline(headings []*Heading) ([]*Heading, []OutlineIssue) {
+	var roots []*Heading
+	var issues []OutlineIssue
+	var stack []*Heading // Open ancestors of the next heading, lowest level first
+	h1Count := 0
+	prevLevel := 0
+
+	for _, h := range headings {
+		if h.Level == 1 {
+			h1Count++
+		}
+		if prevLevel > 0 && h.Level > prevLevel+1 {
+			issues = append(issues, OutlineIssue{
+				Position: h.Position,
+				Message:  fmt.Sprintf("%s %q skips from H%d to H%d", h.Tag(), h.Text, prevLevel, h.Level),
+			})
+		}
+		if h.Text == "" {
+			issues = append(issues, OutlineIssue{Position: h.Position, Message: fmt.Sprintf("%s is empty", h.Tag())})
+		} else if len([]rune(h.Text)) > maxHeadingLength {
+			issues = append(issues, OutlineIssue{
+				Position: h.Position,
+				Message:  fmt.Sprintf("%s is longer than %d characters", h.Tag(), maxHeadingLength),
+			})
+		}
+		prevLevel = h.Level
+
+		// Pop headings that are not ancestors of the current one
+		for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, h)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, h)
+		}
+		stack = append(stack, h)
+	}
+
+	switch {
+	case h1Count == 0:
+		issues = append(issues, OutlineIssue{Message: "page has no H1"})
+	case h1Count > 1:
+		issues = append(issues, OutlineIssue{Message: fmt.Sprintf("page has %d H1 headings", h1Count)})
+	}
+	return roots, issues
+}
@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// collectHeadings returns the headings of the parsed HTML in document order
+func collectHeadings(t *testing.T, src string) []*Heading {
+	t.Helper()
+	doc, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("failed to parse test HTML: %v", err)
+	}
+	var headings []*Heading
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if level := headingLevel(n); level > 0 {
+			headings = append(headings, &Heading{Level: level, Text: textContent(n), Position: len(headings) + 1})
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	return headings
+}
+
+// TestBuildOutline checks nesting of headings into a tree
+func TestBuildOutline(t *testing.T) {
+	headings := collectHeadings(t, `<h1>Guide</h1><h2>Install</h2><h3>Linux</h3><h2>Usage <em>basics</em></h2><h1>Other</h1>`)
+
+	roots, _ := buildOutline(headings)
+
+	// Two H1 roots, the first with two H2 children
+	if got, want := len(roots), 2; got != want {
+		t.Fatalf("len(roots) = %d; want %d", got, want)
+	}
+	if got, want := len(roots[0].Children), 2; got != want {
+		t.Fatalf("len(roots[0].Children) = %d; want %d", got, want)
+	}
+	if got, want := roots[0].Children[0].Children[0].Text, "Linux"; got != want {
+		t.Errorf("nested H3 text = %q; want %q", got, want)
+	}
+	// Text of nested inline elements is included
+	if got, want := roots[0].Children[1].Text, "Usage basics"; got != want {
+		t.Errorf("H2 text = %q; want %q", got, want)
+	}
+	if got, want := roots[1].Position, 5; got != want {
+		t.Errorf("second H1 position = %d; want %d", got, want)
+	}
+}
+
+// TestBuildOutline_Issues checks that structural problems are reported
+func TestBuildOutline_Issues(t *testing.T) {
+	long := strings.Repeat("x", maxHeadingLength+1)
+	headings := collectHeadings(t, `<h2>Intro</h2><h4>Skipped</h4><h3> </h3><h3>`+long+`</h3>`)
+
+	_, issues := buildOutline(headings)
+
+	want := []string{"skips from H2 to H4", "H3 is empty", "longer than", "no H1"}
+	if len(issues) != len(want) {
+		t.Fatalf("issues = %v; want %d issues", issues, len(want))
+	}
+	for i, w := range want {
+		if !strings.Contains(issues[i].Message, w) {
+			t.Errorf("issues[%d] = %q; want it to contain %q", i, issues[i].Message, w)
+		}
+	}
+}
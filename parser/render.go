@@ -0,0 +1,307 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lucytech/logging"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"golang.org/x/net/html"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Analysis modes reported in AnalysisResult.Mode.
+const (
+	ModeRaw      = "raw"      // The server HTML was analyzed as-is
+	ModeRendered = "rendered" // The DOM produced by a headless browser was analyzed
+)
+
+// defaultRenderTimeout bounds a headless rendering when ChromeFetcher.Timeout is unset.
+const defaultRenderTimeout = 30 * time.Second
+
+// ChromeFetcher renders pages in a locally installed headless Chromium driven over
+// the DevTools protocol and returns the resulting DOM as HTML.
+type ChromeFetcher struct {
+	ExecPath     string        // Path to the Chromium binary; empty searches the usual install locations
+	WaitSelector string        // Wait until this CSS selector is visible instead of waiting for network idle
+	Timeout      time.Duration // Upper bound for the whole rendering; zero means defaultRenderTimeout
+}
+
+// Renderer is the headless browser used when an analysis requests rendering.
+// It is configured once at startup and can be replaced for testing.
+var Renderer Fetcher = &ChromeFetcher{}
+
+// selectorWaiter is implemented by renderers that can wait for a CSS selector.
+type selectorWaiter interface {
+	WithWaitSelector(selector string) Fetcher
+}
+
+// WithWaitSelector returns a copy of the fetcher that waits for selector instead of network idle.
+func (f *ChromeFetcher) WithWaitSelector(selector string) Fetcher {
+	c := *f
+	c.WaitSelector = selector
+	return &c
+}
+
+// Fetch starts a headless browser, navigates to the URL, waits for network idle
+// (or for WaitSelector) and returns the serialized DOM.
+func (f *ChromeFetcher) Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	timeout := f.Timeout
+	if timeout <= 0 {
+		timeout = defaultRenderTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// The browser reaches sites through the proxy and TLS settings of ConfigureTransport
+	flags, err := browserFlags(browserConfig)
+	if err != nil {
+		logging.FromContext(ctx).Error("Headless rendering unavailable", "url", rawURL, "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrRender, err)
+	}
+	allocOpts := chromedp.DefaultExecAllocatorOptions[:]
+	for name, value := range flags {
+		allocOpts = append(allocOpts, chromedp.Flag(name, value))
+	}
+	if f.ExecPath != "" {
+		allocOpts = append(allocOpts, chromedp.ExecPath(f.ExecPath))
+	}
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, allocOpts...)
+	defer cancelAlloc()
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	defer cancelBrowser()
+
+	// Only network idle events that follow our navigation count; the initial blank tab is ignored
+	var navigating atomic.Bool
+	idle := make(chan struct{})
+	var once sync.Once
+	chromedp.ListenTarget(browserCtx, func(ev any) {
+		if e, ok := ev.(*page.EventLifecycleEvent); ok && e.Name == "networkIdle" && navigating.Load() {
+			once.Do(func() { close(idle) })
+		}
+	})
+
+	wait := chromedp.ActionFunc(func(ctx context.Context) error {
+		select {
+		case <-idle:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if f.WaitSelector != "" {
+		wait = chromedp.ActionFunc(func(ctx context.Context) error {
+			return chromedp.WaitVisible(f.WaitSelector, chromedp.ByQuery).Do(ctx)
+		})
+	}
+
+	var dom string
+	err = chromedp.Run(browserCtx,
+		page.SetLifecycleEventsEnabled(true),
+		chromedp.ActionFunc(func(context.Context) error {
+			navigating.Store(true)
+			return nil
+		}),
+		chromedp.Navigate(rawURL),
+		wait,
+		chromedp.OuterHTML("html", &dom, chromedp.ByQuery),
+	)
+	if err != nil {
+		logging.FromContext(ctx).Error("Headless rendering failed", "url", rawURL, "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrRender, err)
+	}
+
+	logging.FromContext(ctx).Debug("Rendered URL", "url", rawURL, "dom_bytes", len(dom))
+	return &Page{URL: rawURL, Body: []byte(dom)}, nil
+}
+
+// browserConfig holds the transport settings the headless browser is started with.
+var browserConfig TransportConfig
+
+// errBrowserTLS rejects rendering when the browser could not verify sites like the
+// transport does: Chromium takes neither a CA bundle nor a client certificate as flags.
+var errBrowserTLS = errors.New("rendering is not supported with a custom CA bundle or client certificate")
+
+// browserFlags returns the Chromium command-line flags that apply the transport settings,
+// so rendered pages are loaded through the same proxy and with the same certificate
+// checks as fetched ones.
+func browserFlags(cfg TransportConfig) (map[string]any, error) {
+	if cfg.CAFile != "" || cfg.ClientCert != "" || cfg.ClientKey != "" {
+		return nil, errBrowserTLS
+	}
+	flags := make(map[string]any)
+	if cfg.InsecureSkipVerify {
+		flags["ignore-certificate-errors"] = true
+	}
+
+	proxies := httpproxy.FromEnvironment()
+	if cfg.Proxy != "" {
+		proxies.HTTPProxy, proxies.HTTPSProxy = cfg.Proxy, cfg.Proxy
+	}
+	if cfg.NoProxy != "" {
+		proxies.NoProxy = cfg.NoProxy
+	}
+	httpProxy, err := browserProxy(proxies.HTTPProxy)
+	if err != nil {
+		return nil, err
+	}
+	httpsProxy, err := browserProxy(proxies.HTTPSProxy)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case httpProxy == "" && httpsProxy == "":
+		return flags, nil
+	case httpProxy == httpsProxy:
+		flags["proxy-server"] = httpProxy
+	default:
+		var rules []string
+		if httpProxy != "" {
+			rules = append(rules, "http="+httpProxy)
+		}
+		if httpsProxy != "" {
+			rules = append(rules, "https="+httpsProxy)
+		}
+		flags["proxy-server"] = strings.Join(rules, ";")
+	}
+	if proxies.NoProxy != "" {
+		flags["proxy-bypass-list"] = bypassList(proxies.NoProxy)
+	}
+	return flags, nil
+}
+
+// browserProxy converts a proxy URL to the form Chromium expects. Chromium cannot send
+// proxy credentials given on its command line, so such proxies rule out rendering.
+func browserProxy(proxy string) (string, error) {
+	if proxy == "" {
+		return "", nil
+	}
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy // As accepted in HTTP_PROXY
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid proxy URL %q", redactProxy(proxy))
+	}
+	if u.User != nil {
+		return "", errors.New("rendering is not supported through a proxy that requires credentials")
+	}
+	if u.Scheme == "socks5h" {
+		u.Scheme = "socks5" // Chromium resolves host names through SOCKS5 proxies anyway
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// bypassList converts NO_PROXY rules to a Chromium proxy bypass list. A domain such as
+// "example.com" covers its subdomains, as in NO_PROXY, and ".example.com" only those.
+func bypassList(noProxy string) string {
+	var rules []string
+	for _, rule := range strings.Split(noProxy, ",") {
+		switch rule = strings.TrimSpace(rule); {
+		case rule == "":
+		case rule == "*":
+			rules = append(rules, rule)
+		case strings.HasPrefix(rule, "."):
+			rules = append(rules, "*"+rule)
+		case strings.Contains(rule, "/") || strings.Contains(rule, ":") || strings.Trim(rule, "0123456789.") == "":
+			rules = append(rules, rule) // IP addresses, CIDR ranges and host:port
+		default:
+			rules = append(rules, rule, "*."+rule)
+		}
+	}
+	return strings.Join(rules, ";")
+}
+
+// DOMCounts summarizes a parsed document so raw and rendered DOMs can be compared.
+type DOMCounts struct {
+	Elements int  `json:"elements"` // Total number of element nodes
+	Headings int  `json:"headings"` // h1-h6 elements
+	Links    int  `json:"links"`    // a elements with an href
+	Forms    int  `json:"forms"`    // form elements
+	Images   int  `json:"images"`   // img elements
+	Scripts  int  `json:"scripts"`  // script elements
+	Title    bool `json:"title"`    // True if the document has a non-empty title
+}
+
+// RenderDiff compares the DOM served by the server with the DOM after rendering.
+type RenderDiff struct {
+	Raw      DOMCounts `json:"raw"`      // Counts for the server HTML
+	Rendered DOMCounts `json:"rendered"` // Counts for the rendered DOM
+}
+
+// DiffRow is one line of a RenderDiff table.
+type DiffRow struct {
+	Name     string // Counted item
+	Raw      int    // Count in the server HTML
+	Rendered int    // Count in the rendered DOM
+	Delta    int    // Rendered minus raw
+}
+
+// Rows flattens the diff into table rows for display.
+func (d *RenderDiff) Rows() []DiffRow {
+	row := func(name string, raw, rendered int) DiffRow {
+		return DiffRow{Name: name, Raw: raw, Rendered: rendered, Delta: rendered - raw}
+	}
+	b2i := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	return []DiffRow{
+		row("Elements", d.Raw.Elements, d.Rendered.Elements),
+		row("Headings", d.Raw.Headings, d.Rendered.Headings),
+		row("Links", d.Raw.Links, d.Rendered.Links),
+		row("Forms", d.Raw.Forms, d.Rendered.Forms),
+		row("Images", d.Raw.Images, d.Rendered.Images),
+		row("Scripts", d.Raw.Scripts, d.Rendered.Scripts),
+		row("Title", b2i(d.Raw.Title), b2i(d.Rendered.Title)),
+	}
+}
+
+// countDOM walks the document and counts the elements compared in a RenderDiff.
+func countDOM(doc *html.Node) DOMCounts {
+	var c DOMCounts
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			c.Elements++
+			switch {
+			case headingLevel(n) > 0:
+				c.Headings++
+			case n.Data == "a" && hasAttr(n, "href"):
+				c.Links++
+			case n.Data == "form":
+				c.Forms++
+			case n.Data == "img":
+				c.Images++
+			case n.Data == "script":
+				c.Scripts++
+			case n.Data == "title":
+				c.Title = c.Title || textContent(n) != ""
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			f(child)
+		}
+	}
+	f(doc)
+	return c
+}
+
+// hasAttr reports whether the element has the named attribute.
+func hasAttr(n *html.Node, key string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,259 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"lucytech/logging"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LocalRoot is the directory that file:// URLs and WARC archives must live in.
+// Local sources are disabled while it is empty so that remote users cannot read arbitrary files.
+var LocalRoot string
+
+// errLocalDisabled is returned when a local source is requested but LocalRoot is not configured.
+var errLocalDisabled = errors.New("local file sources are disabled")
+
+// resolveLocal maps p to an absolute path and ensures it stays inside root. Symbolic
+// links are resolved first, so that a link inside root cannot lead to a file outside it.
+func resolveLocal(root, p string) (string, error) {
+	if root == "" {
+		return "", errLocalDisabled
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid local root: %w", err)
+	}
+	if absRoot, err = filepath.EvalSymlinks(absRoot); err != nil {
+		return "", fmt.Errorf("invalid local root: %w", err)
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(absRoot, p)
+	}
+	p, err = filepath.EvalSymlinks(filepath.Clean(p))
+	if err != nil {
+		return "", fmt.Errorf("file not found: %w", err)
+	}
+
+	rel, err := filepath.Rel(absRoot, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside the local root", p)
+	}
+	return p, nil
+}
+
+// fetcherFor selects the fetcher for a URL based on its scheme.
+func fetcherFor(u *url.URL) Fetcher {
+	if u.Scheme == "file" {
+		return &FileFetcher{Root: LocalRoot}
+	}
+	return defaultFetcher
+}
+
+// FileFetcher reads documents from the local filesystem using file:// URLs.
+// A URL pointing at a directory serves the index.html inside it, like a static web server.
+type FileFetcher struct {
+	Root string // Directory the files must live in
+}
+
+// Fetch reads the file addressed by the file:// URL.
+func (f *FileFetcher) Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	path, err := f.resolve(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to open local file", "path", path, "error", err)
+		return nil, fmt.Errorf("unable to open file: %w", err)
+	}
+	defer file.Close()
+
+	body, err := io.ReadAll(io.LimitReader(file, maxPageSize))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file: %w", err)
+	}
+
+	logging.FromContext(ctx).Debug("Read local file", "path", path, "bytes", len(body))
+	return &Page{URL: rawURL, StatusCode: http.StatusOK, Body: body}, nil
+}
+
+// resolve converts a file:// URL into a path inside Root, following directories to their index.html.
+func (f *FileFetcher) resolve(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid file URL: %w", err)
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported scheme %q for file source", u.Scheme)
+	}
+
+	path, err := resolveLocal(f.Root, filepath.FromSlash(u.Path))
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("file not found: %w", err)
+	}
+	if info.IsDir() {
+		// The index.html may itself be a link, so it is checked against Root as well
+		if path, err = resolveLocal(f.Root, filepath.Join(path, "index.html")); err != nil {
+			return "", fmt.Errorf("directory has no index.html: %w", err)
+		}
+	}
+	return path, nil
+}
+
+// RawFetcher serves HTML that was supplied directly, e.g. posted in a request body.
+// The URL passed to Fetch only serves as the base for resolving links.
+type RawFetcher struct {
+	HTML []byte // Document to analyze
+}
+
+// Fetch returns the supplied HTML regardless of the URL.
+func (f *RawFetcher) Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	if len(f.HTML) > maxPageSize {
+		return nil, fmt.Errorf("HTML document exceeds %d bytes", maxPageSize)
+	}
+	return &Page{URL: rawURL, StatusCode: http.StatusOK, Body: f.HTML}, nil
+}
+
+// WARCFetcher looks up documents in a WARC web archive (optionally gzip-compressed)
+// by the target URI of their response records.
+type WARCFetcher struct {
+	Root string // Directory the archive must live in
+	Path string // Archive file, absolute or relative to Root
+}
+
+// Fetch scans the archive for the response record of rawURL and returns its HTTP payload.
+func (f *WARCFetcher) Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	path, err := resolveLocal(f.Root, f.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open WARC archive: %w", err)
+	}
+	defer file.Close()
+
+	// Compressed archives are a series of gzip members, which gzip.Reader reads as one stream
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress WARC archive: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		headers, block, err := readWARCRecord(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed WARC archive: %w", err)
+		}
+		if headers["warc-type"] != "response" || strings.Trim(headers["warc-target-uri"], "<>") != rawURL {
+			continue
+		}
+		logging.FromContext(ctx).Debug("Found WARC response record", "url", rawURL, "archive", path)
+		return pageFromHTTPBlock(rawURL, block)
+	}
+	return nil, fmt.Errorf("URL %s not found in WARC archive", rawURL)
+}
+
+// readWARCRecord reads one record and returns its lower-cased headers and content block.
+func readWARCRecord(br *bufio.Reader) (map[string]string, []byte, error) {
+	// Skip blank lines between records and find the version line
+	var line string
+	for {
+		l, err := br.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && strings.TrimSpace(l) == "" {
+				return nil, nil, io.EOF
+			}
+			return nil, nil, err
+		}
+		if line = strings.TrimSpace(l); line != "" {
+			break
+		}
+	}
+	if !strings.HasPrefix(line, "WARC/") {
+		return nil, nil, fmt.Errorf("unexpected record start %q", line)
+	}
+
+	headers := make(map[string]string)
+	for {
+		l, err := br.ReadString('\n')
+		if err != nil {
+			return nil, nil, err
+		}
+		l = strings.TrimRight(l, "\r\n")
+		if l == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(l, ":"); ok {
+			headers[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+		}
+	}
+
+	length, err := strconv.Atoi(headers["content-length"])
+	if err != nil || length < 0 {
+		return nil, nil, fmt.Errorf("invalid Content-Length %q", headers["content-length"])
+	}
+	block := make([]byte, length)
+	if _, err := io.ReadFull(br, block); err != nil {
+		return nil, nil, err
+	}
+	return headers, block, nil
+}
+
+// pageFromHTTPBlock parses a recorded HTTP response and returns its decoded body.
+func pageFromHTTPBlock(rawURL string, block []byte) (*Page, error) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(block)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP response in WARC record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("%w: %d %s", ErrHTTPStatus, resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	var body io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress archived response: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxPageSize))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read archived response: %w", err)
+	}
+	return &Page{URL: rawURL, StatusCode: resp.StatusCode, Body: data}, nil
+}
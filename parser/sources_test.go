@@ -0,0 +1,164 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFile creates a file with the given content below dir
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
+
+// TestFileFetcher covers files, directory indexes and the local root restriction
+func TestFileFetcher(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "page.html", "<title>Page</title>")
+	writeFile(t, root, "docs/index.html", "<title>Docs</title>")
+
+	f := &FileFetcher{Root: root}
+	ctx := context.Background()
+
+	page, err := f.Fetch(ctx, "file://"+filepath.Join(root, "page.html"))
+	if err != nil || string(page.Body) != "<title>Page</title>" {
+		t.Errorf("Fetch(page.html) = %v, %v; want page content", page, err)
+	}
+
+	// Directories serve their index.html
+	page, err = f.Fetch(ctx, "file://"+filepath.Join(root, "docs"))
+	if err != nil || string(page.Body) != "<title>Docs</title>" {
+		t.Errorf("Fetch(docs) = %v, %v; want index content", page, err)
+	}
+
+	// Paths escaping the root are rejected
+	if _, err := f.Fetch(ctx, "file://"+filepath.Join(root, "..", "etc", "passwd")); err == nil {
+		t.Error("expected error for path outside root")
+	}
+
+	// Symbolic links inside the root cannot lead out of it, also as a directory index
+	outside := t.TempDir()
+	writeFile(t, outside, "secret.html", "<title>Secret</title>")
+	if err := os.Symlink(filepath.Join(outside, "secret.html"), filepath.Join(root, "link.html")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "linked"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.html"), filepath.Join(root, "linked", "index.html")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"link.html", "linked"} {
+		if page, err := f.Fetch(ctx, "file://"+filepath.Join(root, name)); err == nil {
+			t.Errorf("Fetch(%s) = %q; want error for a link outside root", name, page.Body)
+		}
+	}
+
+	// Without a root, local sources are disabled
+	if _, err := (&FileFetcher{}).Fetch(ctx, "file://"+filepath.Join(root, "page.html")); err == nil {
+		t.Error("expected error when local root is not configured")
+	}
+}
+
+// TestRealAnalyzePage_File analyzes local build output and checks its file links
+func TestRealAnalyzePage_File(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "index.html", `<!DOCTYPE html><title>Build</title><h1>Home</h1>
+<a href="about.html">About</a><a href="missing.html">Missing</a>`)
+	writeFile(t, root, "about.html", "<title>About</title>")
+
+	origRoot := LocalRoot
+	LocalRoot = root
+	defer func() { LocalRoot = origRoot }()
+
+	result, err := realAnalyzePage(context.Background(), "file://"+root+"/", Options{})
+	if err != nil {
+		t.Fatalf("realAnalyzePage returned error: %v", err)
+	}
+	if result.Title != "Build" {
+		t.Errorf("Title = %q; want %q", result.Title, "Build")
+	}
+	if got, want := result.InternalLinks, 2; got != want {
+		t.Errorf("InternalLinks = %d; want %d", got, want)
+	}
+	if got, want := result.InaccessibleLinks, 1; got != want {
+		t.Errorf("InaccessibleLinks = %d; want %d", got, want)
+	}
+
+	// The file links of a web page are never checked, so it cannot probe for local files
+	result, err = realAnalyzePage(context.Background(), "https://example.com", Options{
+		Fetcher: &RawFetcher{HTML: []byte(`<a href="file://` + root + `/about.html">About</a>`)},
+	})
+	if err != nil {
+		t.Fatalf("realAnalyzePage returned error: %v", err)
+	}
+	if len(result.Links) != 1 || result.Links[0].Checked || result.InaccessibleLinks != 0 {
+		t.Errorf("Links = %+v; want the file link unchecked", result.Links)
+	}
+}
+
+// TestRealAnalyzePage_Raw analyzes HTML supplied directly instead of fetching it
+func TestRealAnalyzePage_Raw(t *testing.T) {
+	result, err := realAnalyzePage(context.Background(), "https://example.com", Options{
+		Fetcher: &RawFetcher{HTML: []byte(`<!DOCTYPE html><title>Posted</title><input type="password">`)},
+	})
+	if err != nil {
+		t.Fatalf("realAnalyzePage returned error: %v", err)
+	}
+	if result.Title != "Posted" || !result.LoginForm {
+		t.Errorf("Title = %q, LoginForm = %v; want Posted, true", result.Title, result.LoginForm)
+	}
+}
+
+// warcRecord builds a single WARC response record for the given URI and HTTP response
+func warcRecord(uri, httpResponse string) string {
+	return fmt.Sprintf("WARC/1.0\r\nWARC-Type: response\r\nWARC-Target-URI: %s\r\nContent-Type: application/http; msgtype=response\r\nContent-Length: %d\r\n\r\n%s\r\n\r\n",
+		uri, len(httpResponse), httpResponse)
+}
+
+// TestWARCFetcher looks up response records in plain and gzip-compressed archives
+func TestWARCFetcher(t *testing.T) {
+	root := t.TempDir()
+	archive := "WARC/1.0\r\nWARC-Type: warcinfo\r\nContent-Length: 0\r\n\r\n\r\n\r\n" +
+		warcRecord("https://example.com/other", "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nother") +
+		warcRecord("https://example.com/", "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Length: 19\r\n\r\n<title>Home</title>") +
+		warcRecord("https://example.com/gone", "HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n")
+	writeFile(t, root, "crawl.warc", archive)
+
+	// The same archive compressed with gzip
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(archive))
+	zw.Close()
+	writeFile(t, root, "crawl.warc.gz", gz.String())
+
+	for _, name := range []string{"crawl.warc", "crawl.warc.gz"} {
+		f := &WARCFetcher{Root: root, Path: name}
+
+		page, err := f.Fetch(context.Background(), "https://example.com/")
+		if err != nil {
+			t.Fatalf("%s: Fetch returned error: %v", name, err)
+		}
+		if string(page.Body) != "<title>Home</title>" {
+			t.Errorf("%s: Body = %q; want archived HTML", name, page.Body)
+		}
+
+		if _, err := f.Fetch(context.Background(), "https://example.com/gone"); err == nil {
+			t.Errorf("%s: expected error for archived 404", name)
+		}
+		if _, err := f.Fetch(context.Background(), "https://example.com/unknown"); err == nil {
+			t.Errorf("%s: expected error for URL missing from archive", name)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"context"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the analysis pipeline. It is a no-op until a tracer
+// provider is installed at startup.
+var tracer = otel.Tracer("lucytech/parser")
+
+// startSpan starts a span for an operation on rawURL, tagged with the URL's host.
+func startSpan(ctx context.Context, name, rawURL string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		attrs = append(attrs, attribute.String("server.address", u.Hostname()))
+	}
+	attrs = append(attrs, attribute.String("url.full", rawURL))
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records the HTTP status (if any) and error of an operation and ends its span.
+func endSpan(span trace.Span, statusCode int, err error) {
+	if statusCode != 0 {
+		span.SetAttributes(attribute.Int("http.response.status_code", statusCode))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracedFetch fetches a page inside a span named after the operation, e.g. "fetch" or "render".
+func tracedFetch(ctx context.Context, name string, fetcher Fetcher, rawURL string) (*Page, error) {
+	ctx, span := startSpan(ctx, name, rawURL)
+	page, err := fetcher.Fetch(ctx, rawURL)
+	status := 0
+	if page != nil {
+		status = page.StatusCode
+		span.SetAttributes(attribute.Int("page.size_bytes", len(page.Body)))
+	}
+	endSpan(span, status, err)
+	return page, err
+}
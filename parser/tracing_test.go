@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// withSpanRecorder records the parser's spans in memory for the duration of a test
+func withSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	origTracer := tracer
+	tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	t.Cleanup(func() { tracer = origTracer })
+	return recorder
+}
+
+// TestRealAnalyzePage_Spans checks the span tree of an analysis and that no trace context is sent to servers
+func TestRealAnalyzePage_Spans(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	recorder := withSpanRecorder(t)
+
+	var mu sync.Mutex
+	var traceparents []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		traceparents = append(traceparents, r.Header.Get("Traceparent"))
+		mu.Unlock()
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `<!DOCTYPE html><title>Traced</title><a href="/about">About</a><a href="/missing">Missing</a>`)
+	}))
+	defer ts.Close()
+
+	if _, err := realAnalyzePage(context.Background(), ts.URL, Options{}); err != nil {
+		t.Fatalf("realAnalyzePage returned error: %v", err)
+	}
+
+	spans := recorder.Ended()
+	byName := make(map[string][]sdktrace.ReadOnlySpan)
+	for _, s := range spans {
+		byName[s.Name()] = append(byName[s.Name()], s)
+	}
+	if len(byName["analyze"]) != 1 {
+		t.Fatalf("analyze spans = %d; want 1 (got %d spans in total)", len(byName["analyze"]), len(spans))
+	}
+	root := byName["analyze"][0].SpanContext()
+
+	for name, want := range map[string]int{"fetch": 1, "traverse": 1, "check_link": 2} {
+		if got := len(byName[name]); got != want {
+			t.Errorf("%s spans = %d; want %d", name, got, want)
+		}
+		for _, s := range byName[name] {
+			if s.Parent().SpanID() != root.SpanID() {
+				t.Errorf("%s span parent = %s; want analyze span %s", name, s.Parent().SpanID(), root.SpanID())
+			}
+		}
+	}
+
+	// The broken link is visible on its span
+	var broken bool
+	for _, s := range byName["check_link"] {
+		for _, attr := range s.Attributes() {
+			if attr.Key == "http.response.status_code" && attr.Value.AsInt64() == http.StatusNotFound {
+				broken = true
+			}
+		}
+	}
+	if !broken {
+		t.Error("no check_link span recorded the 404 status")
+	}
+
+	// Outgoing requests reach third-party servers, so they carry no trace context
+	if len(traceparents) != 3 {
+		t.Fatalf("requests = %d; want 3", len(traceparents))
+	}
+	for _, tp := range traceparents {
+		if tp != "" {
+			t.Errorf("request sent traceparent %q; want none", tp)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// TransportConfig configures how pages and links are requested, e.g. to reach sites
+// behind a corporate proxy that use certificates of an internal CA.
+type TransportConfig struct {
+	Proxy   string // URL of an http, https or socks5 proxy for all requests; empty uses HTTP_PROXY and HTTPS_PROXY
+	NoProxy string // Comma-separated hosts, domains (".example.com"), IPs and CIDR ranges reached directly; empty uses NO_PROXY
+
+	CAFile     string // PEM bundle of CA certificates trusted in addition to the system's
+	ClientCert string // PEM client certificate for mutual TLS; requires ClientKey
+	ClientKey  string // PEM private key of ClientCert
+
+	InsecureSkipVerify bool // Accept any server certificate; every result is flagged with InsecureTLS
+}
+
+var (
+	// transport sends the requests of httpClient and SharedTransport, see tracedTransport.
+	transport http.RoundTripper = tracedTransport(http.DefaultTransport)
+
+	// insecureTLS records that certificate verification is turned off.
+	insecureTLS bool
+)
+
+// SharedTransport sends requests through the transport set up with ConfigureTransport,
+// so that the clients of other packages, e.g. for crawls and sitemaps, reach the same
+// sites as page fetches and link checks.
+var SharedTransport http.RoundTripper = sharedTransport{}
+
+// sharedTransport looks the transport up on every request, so it follows ConfigureTransport
+// even for clients created before it was called.
+type sharedTransport struct{}
+
+func (sharedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return transport.RoundTrip(req)
+}
+
+// ConfigureTransport sets up the proxy and TLS settings of all page fetches and link
+// checks. It is called once at startup, before any analysis runs.
+func ConfigureTransport(cfg TransportConfig) error {
+	t, err := NewTransport(cfg)
+	if err != nil {
+		return err
+	}
+	transport = tracedTransport(t)
+	insecureTLS = cfg.InsecureSkipVerify
+	browserConfig = cfg
+	slog.Info("HTTP transport configured",
+		"proxy", redactProxy(cfg.Proxy), "no_proxy", cfg.NoProxy, "ca_file", cfg.CAFile,
+		"client_cert", cfg.ClientCert, "insecure_skip_verify", cfg.InsecureSkipVerify)
+	if cfg.InsecureSkipVerify {
+		slog.Warn("TLS certificate verification is disabled; results are flagged as insecure")
+	}
+	if _, err := browserFlags(cfg); err != nil {
+		slog.Warn("Rendering mode is unavailable with these transport settings", "error", err)
+	}
+	return nil
+}
+
+// tracedTransport wraps t to create client spans. The trace context is not propagated:
+// pages and links point at third-party servers, which must not learn our trace IDs.
+func tracedTransport(t http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(t, otelhttp.WithPropagators(propagation.NewCompositeTextMapPropagator()))
+}
+
+// NewTransport builds an HTTP transport from the configuration, starting from the
+// defaults of http.DefaultTransport.
+func NewTransport(cfg TransportConfig) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Proxy != "" || cfg.NoProxy != "" {
+		proxies := httpproxy.FromEnvironment()
+		if cfg.Proxy != "" {
+			u, err := url.Parse(cfg.Proxy)
+			if err != nil || u.Host == "" {
+				return nil, fmt.Errorf("invalid proxy URL %q: want e.g. http://proxy.example.com:3128", cfg.Proxy)
+			}
+			switch u.Scheme {
+			case "http", "https", "socks5", "socks5h":
+			default:
+				return nil, fmt.Errorf("unsupported proxy scheme %q: want http, https or socks5", u.Scheme)
+			}
+			proxies.HTTPProxy, proxies.HTTPSProxy = cfg.Proxy, cfg.Proxy
+		}
+		if cfg.NoProxy != "" {
+			proxies.NoProxy = cfg.NoProxy
+		}
+		proxyFunc := proxies.ProxyFunc()
+		t.Proxy = func(req *http.Request) (*url.URL, error) { return proxyFunc(req.URL) }
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in CA bundle %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.ClientCert != "" || cfg.ClientKey != "" {
+		if cfg.ClientCert == "" || cfg.ClientKey == "" {
+			return nil, fmt.Errorf("a client certificate needs both a certificate and a key file")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
+
+// redactProxy hides the password of a proxy URL for logging.
+func redactProxy(proxy string) string {
+	if u, err := url.Parse(proxy); err == nil {
+		return u.Redacted()
+	}
+	return proxy
+}
@@ -0,0 +1,157 @@
+package parser
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// configureTransport applies a transport configuration for the duration of a test
+func configureTransport(t *testing.T, cfg TransportConfig) {
+	t.Helper()
+	orig, origInsecure, origBrowser := transport, insecureTLS, browserConfig
+	t.Cleanup(func() { transport, insecureTLS, browserConfig = orig, origInsecure, origBrowser })
+	if err := ConfigureTransport(cfg); err != nil {
+		t.Fatalf("ConfigureTransport returned error: %v", err)
+	}
+}
+
+// writePEM writes a PEM block to a file in the test's temporary directory
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestConfigureTransport_TLS checks custom CAs, mutual TLS and the insecure flag on page fetches and link checks
+func TestConfigureTransport_TLS(t *testing.T) {
+	// A self-signed client certificate the server requires
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "lucytech"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalPKCS8PrivateKey(key)
+	clientCert, _ := x509.ParseCertificate(der)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<!DOCTYPE html><title>Intranet</title><a href="/about">About</a>`))
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	ts.StartTLS()
+	defer ts.Close()
+	caFile := writePEM(t, "ca.pem", "CERTIFICATE", ts.Certificate().Raw)
+	certFile := writePEM(t, "client.pem", "CERTIFICATE", der)
+	keyFile := writePEM(t, "client-key.pem", "PRIVATE KEY", keyDER)
+
+	// Without the client certificate the handshake fails
+	configureTransport(t, TransportConfig{CAFile: caFile})
+	if _, err := realAnalyzePage(context.Background(), ts.URL, Options{}); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("without client certificate: err = %v; want ErrUnreachable", err)
+	}
+
+	configureTransport(t, TransportConfig{CAFile: caFile, ClientCert: certFile, ClientKey: keyFile})
+	result, err := realAnalyzePage(context.Background(), ts.URL, Options{})
+	if err != nil {
+		t.Fatalf("realAnalyzePage returned error: %v", err)
+	}
+	if result.InaccessibleLinks != 0 || !result.Links[0].Checked || result.InsecureTLS {
+		t.Errorf("result = %+v; want the link checked over mutual TLS, verified", result)
+	}
+
+	// Skipping verification needs no CA, and is flagged
+	configureTransport(t, TransportConfig{ClientCert: certFile, ClientKey: keyFile, InsecureSkipVerify: true})
+	result, err = realAnalyzePage(context.Background(), ts.URL, Options{SkipLinkChecks: true})
+	if err != nil || !result.InsecureTLS {
+		t.Errorf("insecure: result = %+v, %v; want it flagged", result, err)
+	}
+}
+
+// TestNewTransport_Proxy checks the proxy choice with and without NO_PROXY rules
+func TestNewTransport_Proxy(t *testing.T) {
+	tr, err := NewTransport(TransportConfig{Proxy: "socks5://proxy.corp.example:1080", NoProxy: ".intranet.example,10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("NewTransport returned error: %v", err)
+	}
+	tests := []struct {
+		url, want string
+	}{
+		{"https://www.example.com/", "socks5://proxy.corp.example:1080"},
+		{"http://www.example.com/", "socks5://proxy.corp.example:1080"},
+		{"https://wiki.intranet.example/", ""},
+		{"http://10.1.2.3/", ""},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+		proxy, err := tr.Proxy(req)
+		got := ""
+		if proxy != nil {
+			got = proxy.String()
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("proxy for %s = %q, %v; want %q", tt.url, got, err, tt.want)
+		}
+	}
+}
+
+// TestConfigureTransport_HTTPProxy checks that page fetches go through an HTTP proxy
+func TestConfigureTransport_HTTPProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String()) // Requests to a proxy carry the absolute URL
+		w.Write([]byte(`<!DOCTYPE html><title>Proxied</title><a href="/about">About</a>`))
+	}))
+	defer proxy.Close()
+
+	configureTransport(t, TransportConfig{Proxy: proxy.URL})
+	result, err := realAnalyzePage(context.Background(), "http://intranet.invalid/", Options{})
+	if err != nil {
+		t.Fatalf("realAnalyzePage returned error: %v", err)
+	}
+	if result.Title != "Proxied" || len(proxied) != 2 || proxied[1] != "http://intranet.invalid/about" {
+		t.Errorf("title %q, proxied %q; want the page and its link through the proxy", result.Title, proxied)
+	}
+}
+
+// TestNewTransport_Invalid checks that unusable settings are rejected
+func TestNewTransport_Invalid(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	os.WriteFile(empty, nil, 0o600)
+	for _, cfg := range []TransportConfig{
+		{Proxy: "ftp://proxy.example.com"},
+		{Proxy: "proxy.example.com:3128"},
+		{CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+		{CAFile: empty},
+		{ClientCert: empty},
+		{ClientCert: empty, ClientKey: empty},
+	} {
+		if _, err := NewTransport(cfg); err == nil {
+			t.Errorf("NewTransport(%+v) accepted invalid settings", cfg)
+		}
+	}
+}
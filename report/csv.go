@@ -0,0 +1,49 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"lucytech/parser"
+	"strconv"
+)
+
+// csvHeader names the columns of the CSV report.
+var csvHeader = []string{"url", "type", "checked", "accessible", "status_code", "error", "missing_anchor", "rel"}
+
+// writeCSV writes one row per link, so spreadsheets can filter broken links.
+func writeCSV(w io.Writer, r *parser.AnalysisResult) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, l := range r.Links {
+		status := ""
+		if l.StatusCode != 0 {
+			status = strconv.Itoa(l.StatusCode)
+		}
+		row := []string{
+			csvSafe(l.URL),
+			linkType(l),
+			strconv.FormatBool(l.Checked),
+			strconv.FormatBool(l.Accessible),
+			status,
+			csvSafe(l.Error),
+			strconv.FormatBool(l.MissingAnchor),
+			l.Rel,
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvSafe defuses values that spreadsheets would evaluate as formulas.
+// Links come from untrusted pages, so "=HYPERLINK(...)" must stay text.
+func csvSafe(s string) string {
+	if s != "" && (s[0] == '=' || s[0] == '+' || s[0] == '-' || s[0] == '@') {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,136 @@
+package report
+
+import (
+	"fmt"
+	"lucytech/parser"
+)
+
+// Finding severities, ordered from most to least severe. They match SARIF levels.
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelNote    = "note"
+)
+
+// Rule IDs of findings, stable across releases so CI tooling can track and suppress them.
+const (
+	RuleAnalysisFailed  = "analysis-failed"  // The page could not be fetched or parsed
+	RuleBrokenLink      = "broken-link"      // A link on the page is inaccessible
+	RuleHeadingOutline  = "heading-outline"  // The heading hierarchy has a structural problem
+	RuleAssertionFailed = "assertion-failed" // A configured CSS selector assertion did not hold
+	RuleMissingAnchor   = "missing-anchor"   // A link's fragment names no element of its target page
+)
+
+// rule describes a rule ID for SARIF tool metadata.
+type rule struct {
+	ID          string
+	Name        string
+	Description string
+	Level       string // Default level; broken external links are downgraded to warnings
+}
+
+// rules lists every rule a finding can refer to, in SARIF rule index order.
+var rules = []rule{
+	{RuleAnalysisFailed, "AnalysisFailed", "The page could not be fetched or analyzed.", LevelError},
+	{RuleBrokenLink, "BrokenLink", "A link on the page could not be reached.", LevelError},
+	{RuleHeadingOutline, "HeadingOutline", "The heading hierarchy skips levels or has no single top-level heading.", LevelWarning},
+	{RuleAssertionFailed, "AssertionFailed", "A site-specific CSS selector assertion did not hold.", LevelError},
+	{RuleMissingAnchor, "MissingAnchor", "A link points at a fragment that no element of its target page defines.", LevelWarning},
+}
+
+// Page is the outcome of analyzing one page, as reported by the multi-page formats.
+type Page struct {
+	URL    string                 `json:"url"`              // Page that was analyzed
+	Result *parser.AnalysisResult `json:"result,omitempty"` // Nil if the analysis failed
+	Error  string                 `json:"error,omitempty"`  // Why the analysis failed
+}
+
+// Finding is a problem found on a page.
+type Finding struct {
+	RuleID  string // One of the Rule constants
+	Level   string // One of the Level constants
+	Page    string // URL of the page the finding is on
+	Message string // Human readable description
+}
+
+// check is a single verdict about a page; JUnit reports one test case per check.
+type check struct {
+	name     string
+	skipped  string    // Reason the check did not run, if it did not
+	findings []Finding // Empty if the check passed
+}
+
+// checks lists the verdicts about a page: one per link, one for the heading outline
+// and one per assertion, or a single failed check if the analysis itself failed.
+func checks(p Page) []check {
+	if p.Result == nil {
+		msg := p.Error
+		if msg == "" {
+			msg = "no analysis result"
+		}
+		return []check{{name: "analyze", findings: []Finding{{RuleAnalysisFailed, LevelError, p.URL, msg}}}}
+	}
+	r := p.Result
+
+	var cs []check
+	for _, l := range r.Links {
+		c := check{name: "link " + l.URL}
+		switch {
+		case l.MissingAnchor:
+			// Same-page fragments are verified even when link checks are disabled
+			c.findings = []Finding{{RuleMissingAnchor, LevelWarning, p.URL, fmt.Sprintf("%s link %s points at a missing anchor", linkType(l), l.URL)}}
+		case l.Scheme != "":
+			c.skipped = "not a web link"
+		case !l.Checked:
+			c.skipped = "link checks disabled"
+		case !l.Accessible:
+			level := LevelError
+			if !l.Internal {
+				level = LevelWarning // External sites are outside the team's control
+			}
+			c.findings = []Finding{{RuleBrokenLink, level, p.URL, fmt.Sprintf("%s link %s is inaccessible (%s)", linkType(l), l.URL, linkStatus(l))}}
+		}
+		cs = append(cs, c)
+	}
+
+	outline := check{name: "heading outline"}
+	for _, issue := range r.OutlineIssues {
+		msg := issue.Message
+		if issue.Position > 0 {
+			msg = fmt.Sprintf("heading #%d: %s", issue.Position, msg)
+		}
+		outline.findings = append(outline.findings, Finding{RuleHeadingOutline, LevelWarning, p.URL, msg})
+	}
+	cs = append(cs, outline)
+
+	for _, a := range r.Assertions {
+		c := check{name: "assertion " + a.Name}
+		if !a.Passed {
+			c.findings = []Finding{{RuleAssertionFailed, LevelError, p.URL, fmt.Sprintf("%s (%s): %s", a.Name, a.Selector, a.Message)}}
+		}
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+// Findings returns the problems found on the pages, in page and check order.
+func Findings(pages []Page) []Finding {
+	var fs []Finding
+	for _, p := range pages {
+		for _, c := range checks(p) {
+			fs = append(fs, c.findings...)
+		}
+	}
+	return fs
+}
+
+// AtLeast reports whether level is as severe as threshold or more.
+func AtLeast(level, threshold string) bool {
+	rank := map[string]int{LevelError: 3, LevelWarning: 2, LevelNote: 1}
+	return rank[level] >= rank[threshold]
+}
+
+// pageOf wraps a single result for the multi-page writers.
+func pageOf(r *parser.AnalysisResult) []Page {
+	return []Page{{URL: r.URL, Result: r}}
+}
@@ -0,0 +1,151 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"lucytech/parser"
+	"strings"
+	"testing"
+)
+
+// samplePages returns an analyzed page with findings and a page that failed to load
+func samplePages() []Page {
+	return []Page{
+		{URL: "https://example.com/pricing", Result: sampleResult()},
+		{URL: "https://example.com/down", Error: "unreachable: connection refused"},
+	}
+}
+
+// TestFindings checks rule IDs and levels, including downgraded external links
+func TestFindings(t *testing.T) {
+	var got []string
+	for _, f := range Findings(samplePages()) {
+		got = append(got, f.Level+" "+f.RuleID)
+	}
+	want := []string{
+		"warning broken-link",     // partner.example.org is external
+		"warning broken-link",     // So is the formula-like link
+		"warning heading-outline", // h4 skips level h3
+		"error assertion-failed",  // No banner
+		"error analysis-failed",   // Second page
+	}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("findings = %v; want %v", got, want)
+	}
+
+	if !AtLeast(LevelError, LevelWarning) || AtLeast(LevelWarning, LevelError) {
+		t.Error("AtLeast does not order error above warning")
+	}
+}
+
+// TestFindings_MissingAnchor checks that missing anchors are their own warning, even unchecked
+func TestFindings_MissingAnchor(t *testing.T) {
+	r := &parser.AnalysisResult{URL: "https://example.com/docs", Links: []parser.LinkResult{
+		{URL: "https://example.com/docs#gone", Internal: true, MissingAnchor: true},
+		{URL: "https://example.com/faq#shipping", Internal: true, Checked: true, Accessible: true, StatusCode: 200, MissingAnchor: true},
+	}}
+	fs := Findings([]Page{{URL: r.URL, Result: r}})
+	if len(fs) != 2 || fs[0].RuleID != RuleMissingAnchor || fs[0].Level != LevelWarning ||
+		fs[1].Message != "internal link https://example.com/faq#shipping points at a missing anchor" {
+		t.Errorf("findings = %+v; want two missing-anchor warnings", fs)
+	}
+}
+
+// TestWriteJUnit checks suites per page, cases per check and their counts
+func TestWriteJUnit(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteJUnit(&b, samplePages()); err != nil {
+		t.Fatalf("WriteJUnit returned error: %v", err)
+	}
+	var root junitSuites
+	if err := xml.Unmarshal(b.Bytes(), &root); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, b.String())
+	}
+
+	// 3 links, the outline and 2 assertions; then the failed analysis
+	if root.Tests != 7 || root.Failures != 4 || root.Errors != 1 || len(root.Suites) != 2 {
+		t.Errorf("totals = %d tests, %d failures, %d errors, %d suites; want 7, 4, 1, 2",
+			root.Tests, root.Failures, root.Errors, len(root.Suites))
+	}
+	signup := root.Suites[0].Cases[0]
+	if signup.Name != "link https://example.com/signup" || signup.Failure != nil {
+		t.Errorf("first case = %+v; want a passing link check", signup)
+	}
+	if e := root.Suites[1].Cases[0].Error; e == nil || e.Type != RuleAnalysisFailed {
+		t.Errorf("failed page case = %+v; want an analysis-failed error", root.Suites[1].Cases[0])
+	}
+}
+
+// TestWriteJUnit_SkippedLinks checks that unchecked and non-web links are reported as skipped
+func TestWriteJUnit_SkippedLinks(t *testing.T) {
+	r := sampleResult()
+	for i := range r.Links {
+		r.Links[i].Checked = false
+	}
+	r.Links = append(r.Links, parser.LinkResult{URL: "mailto:team@example.com", Scheme: "mailto"})
+	var b bytes.Buffer
+	if err := Write(&b, FormatJUnit, r); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	var root junitSuites
+	xml.Unmarshal(b.Bytes(), &root)
+	if root.Skipped != 4 {
+		t.Errorf("skipped = %d; want 4", root.Skipped)
+	}
+	if c := root.Suites[0].Cases[3]; c.Skipped == nil || c.Skipped.Message != "not a web link" {
+		t.Errorf("mailto case = %+v; want it skipped as not a web link", c)
+	}
+}
+
+// TestWriteSARIF checks the log structure, rule indexes and locations
+func TestWriteSARIF(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteSARIF(&b, samplePages()); err != nil {
+		t.Fatalf("WriteSARIF returned error: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(b.Bytes(), &log); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("log = %+v; want one SARIF 2.1.0 run", log)
+	}
+	run := log.Runs[0]
+	if len(run.Results) != 5 {
+		t.Fatalf("results = %d; want 5", len(run.Results))
+	}
+	for _, res := range run.Results {
+		if run.Tool.Driver.Rules[res.RuleIndex].ID != res.RuleID {
+			t.Errorf("result %s points at rule %d (%s)", res.RuleID, res.RuleIndex, run.Tool.Driver.Rules[res.RuleIndex].ID)
+		}
+		if len(res.Locations) != 1 || res.Locations[0].PhysicalLocation.ArtifactLocation.URI == "" {
+			t.Errorf("result %s has no location", res.RuleID)
+		}
+	}
+
+	// A clean page still yields a valid log with an empty result list
+	b.Reset()
+	WriteSARIF(&b, []Page{{URL: "https://example.com", Result: &parser.AnalysisResult{}}})
+	if !strings.Contains(b.String(), `"results": []`) {
+		t.Errorf("clean run does not have an empty results array:\n%s", b.String())
+	}
+}
+
+// TestWriteText checks the command-line summary
+func TestWriteText(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteText(&b, samplePages()); err != nil {
+		t.Fatalf("WriteText returned error: %v", err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"FAIL https://example.com/pricing (6 checks, 4 findings)\n",
+		"  error    assertion-failed  No banner (.banner): found 2 elements\n",
+		"FAIL https://example.com/down (1 check, 1 finding)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
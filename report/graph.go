@@ -0,0 +1,291 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"lucytech/crawl"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Link graph formats, in addition to FormatCSV and FormatJSON.
+const (
+	FormatDOT     = "dot"
+	FormatGraphML = "graphml"
+)
+
+// graphFormat describes how a link graph format is written and served.
+type graphFormat struct {
+	contentType string
+	extension   string
+	write       func(w io.Writer, g *crawl.Graph) error
+}
+
+// graphFormats maps link graph format names to their writers.
+var graphFormats = map[string]graphFormat{
+	FormatDOT:     {"text/vnd.graphviz; charset=utf-8", "dot", writeDOT},
+	FormatGraphML: {"application/graphml+xml; charset=utf-8", "graphml", writeGraphML},
+	FormatCSV:     {"text/csv; charset=utf-8", "csv", writeGraphCSV},
+	FormatJSON:    {"application/json", "json", writeGraphJSON},
+}
+
+// GraphFormats lists the link graph formats in the order they are offered to users.
+var GraphFormats = []string{FormatDOT, FormatGraphML, FormatCSV, FormatJSON}
+
+// WriteGraph renders the internal link graph of a crawl in the given format.
+func WriteGraph(w io.Writer, name string, g *crawl.Graph) error {
+	f, ok := graphFormats[name]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+	}
+	return f.write(w, g)
+}
+
+// GraphContentType returns the MIME type of a link graph format, or "" if it is unknown.
+func GraphContentType(name string) string {
+	return graphFormats[name].contentType
+}
+
+// GraphFilename suggests a download name for a link graph, e.g. "example.com-links.dot".
+func GraphFilename(name string, g *crawl.Graph) string {
+	base := "crawl"
+	if u, err := url.Parse(g.Start); err == nil && u.Hostname() != "" {
+		base = u.Hostname()
+	}
+	return base + "-links." + graphFormats[name].extension
+}
+
+// graphNode is a node of the exported graph: a crawled page or an orphan.
+type graphNode struct {
+	crawl.Page
+	state string
+}
+
+// graphEdge is a link or redirect between two nodes of the exported graph.
+type graphEdge struct {
+	from, to int
+	redirect bool
+	status   int // Status of the redirect
+}
+
+// graphOf flattens a crawl into nodes and edges. Orphans become nodes without edges,
+// and links to URLs that are not nodes, e.g. beyond the crawl limits, are left out.
+func graphOf(g *crawl.Graph) ([]graphNode, []graphEdge) {
+	nodes := make([]graphNode, 0, len(g.Pages)+len(g.Orphans))
+	index := make(map[string]int)
+	for _, p := range g.Pages {
+		index[p.URL] = len(nodes)
+		nodes = append(nodes, graphNode{Page: p, state: p.State()})
+	}
+	for _, u := range g.Orphans {
+		if _, ok := index[u]; !ok {
+			index[u] = len(nodes)
+			nodes = append(nodes, graphNode{Page: crawl.Page{URL: u, Depth: -1, InSitemap: true}, state: "orphan"})
+		}
+	}
+
+	var edges []graphEdge
+	for i, n := range nodes {
+		for _, to := range n.Outbound {
+			if j, ok := index[to]; ok {
+				redirect := to == n.RedirectTo
+				e := graphEdge{from: i, to: j, redirect: redirect}
+				if redirect {
+					e.status = n.StatusCode
+				}
+				edges = append(edges, e)
+			}
+		}
+	}
+	return nodes, edges
+}
+
+// dotColors highlights node states in DOT output.
+var dotColors = map[string]string{
+	crawl.StateBroken:     "red",
+	crawl.StateRedirect:   "orange",
+	crawl.StateBlocked:    "gray",
+	crawl.StateNotCrawled: "gray",
+	"orphan":              "purple",
+}
+
+// dotEscaper escapes text for double-quoted DOT strings.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", "")
+
+// writeDOT writes the graph in the Graphviz DOT language. Nodes are labelled with
+// their path and click depth and coloured by state; redirects are dashed edges.
+func writeDOT(w io.Writer, g *crawl.Graph) error {
+	nodes, edges := graphOf(g)
+	var b strings.Builder
+	b.WriteString("digraph links {\n  rankdir=LR;\n  node [shape=box, fontname=\"Helvetica\"];\n")
+	for _, n := range nodes {
+		label := pathOf(n.URL)
+		if n.Depth >= 0 {
+			label += "\ndepth " + strconv.Itoa(n.Depth)
+		} else {
+			label += "\norphan"
+		}
+		fmt.Fprintf(&b, "  \"%s\" [label=\"%s\", tooltip=\"%s\"", dotEscaper.Replace(n.URL), dotEscaper.Replace(label), dotEscaper.Replace(n.Title))
+		if color, ok := dotColors[n.state]; ok {
+			fmt.Fprintf(&b, ", color=%s", color)
+		}
+		b.WriteString("];\n")
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&b, "  \"%s\" -> \"%s\"", dotEscaper.Replace(nodes[e.from].URL), dotEscaper.Replace(nodes[e.to].URL))
+		if e.redirect {
+			fmt.Fprintf(&b, " [style=dashed, label=\"%d\"]", e.status)
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// GraphML document structure.
+type (
+	graphML struct {
+		XMLName xml.Name     `xml:"graphml"`
+		XMLNS   string       `xml:"xmlns,attr"`
+		Keys    []graphMLKey `xml:"key"`
+		Graph   graphMLGraph `xml:"graph"`
+	}
+	graphMLKey struct {
+		ID   string `xml:"id,attr"`
+		For  string `xml:"for,attr"`
+		Name string `xml:"attr.name,attr"`
+		Type string `xml:"attr.type,attr"`
+	}
+	graphMLGraph struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	}
+	graphMLNode struct {
+		ID   string        `xml:"id,attr"`
+		Data []graphMLData `xml:"data"`
+	}
+	graphMLEdge struct {
+		Source string        `xml:"source,attr"`
+		Target string        `xml:"target,attr"`
+		Data   []graphMLData `xml:"data"`
+	}
+	graphMLData struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+)
+
+// graphMLKeys declares the node and edge attributes of the GraphML output.
+var graphMLKeys = []graphMLKey{
+	{"url", "node", "url", "string"},
+	{"title", "node", "title", "string"},
+	{"depth", "node", "depth", "int"},
+	{"status", "node", "status_code", "int"},
+	{"state", "node", "state", "string"},
+	{"in_sitemap", "node", "in_sitemap", "boolean"},
+	{"inbound", "node", "inbound_links", "int"},
+	{"kind", "edge", "kind", "string"},
+}
+
+// writeGraphML writes the graph as GraphML for tools such as Gephi, yEd or Cytoscape.
+// Orphans have depth -1.
+func writeGraphML(w io.Writer, g *crawl.Graph) error {
+	nodes, edges := graphOf(g)
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys:  graphMLKeys,
+		Graph: graphMLGraph{ID: "links", EdgeDefault: "directed"},
+	}
+	for i, n := range nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: "n" + strconv.Itoa(i),
+			Data: []graphMLData{
+				{"url", n.URL},
+				{"title", n.Title},
+				{"depth", strconv.Itoa(n.Depth)},
+				{"status", strconv.Itoa(n.StatusCode)},
+				{"state", n.state},
+				{"in_sitemap", strconv.FormatBool(n.InSitemap)},
+				{"inbound", strconv.Itoa(len(n.Inbound))},
+			},
+		})
+	}
+	for _, e := range edges {
+		kind := "link"
+		if e.redirect {
+			kind = "redirect"
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: "n" + strconv.Itoa(e.from),
+			Target: "n" + strconv.Itoa(e.to),
+			Data:   []graphMLData{{"kind", kind}},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// graphCSVHeader names the columns of the link graph CSV.
+var graphCSVHeader = []string{"url", "depth", "state", "status_code", "redirect_to", "title", "inbound_links", "outbound_links", "in_sitemap", "error"}
+
+// writeGraphCSV writes one row per page of the graph, orphans last with an empty depth.
+func writeGraphCSV(w io.Writer, g *crawl.Graph) error {
+	nodes, _ := graphOf(g)
+	cw := csv.NewWriter(w)
+	cw.Write(graphCSVHeader)
+	for _, n := range nodes {
+		depth, status := "", ""
+		if n.Depth >= 0 {
+			depth = strconv.Itoa(n.Depth)
+		}
+		if n.StatusCode != 0 {
+			status = strconv.Itoa(n.StatusCode)
+		}
+		cw.Write([]string{
+			csvSafe(n.URL),
+			depth,
+			n.state,
+			status,
+			csvSafe(n.RedirectTo),
+			csvSafe(n.Title),
+			strconv.Itoa(len(n.Inbound)),
+			strconv.Itoa(len(n.Outbound)),
+			strconv.FormatBool(n.InSitemap),
+			csvSafe(n.Error),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeGraphJSON writes the graph as indented JSON.
+func writeGraphJSON(w io.Writer, g *crawl.Graph) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// pathOf returns the path and query of a URL, for compact labels.
+func pathOf(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.RequestURI()
+}
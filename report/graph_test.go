@@ -0,0 +1,103 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"lucytech/crawl"
+	"strings"
+	"testing"
+)
+
+// sampleGraph returns a crawl with a link, a redirect, a broken page and an orphan
+func sampleGraph() *crawl.Graph {
+	return &crawl.Graph{
+		Start: "https://example.com/",
+		Pages: []crawl.Page{
+			{URL: "https://example.com/", StatusCode: 200, Title: `Home "page"`, Outbound: []string{"https://example.com/old", "https://example.com/missing", "https://example.com/beyond"}},
+			{URL: "https://example.com/old", Depth: 1, StatusCode: 301, RedirectTo: "https://example.com/new", Inbound: []string{"https://example.com/"}, Outbound: []string{"https://example.com/new"}},
+			{URL: "https://example.com/missing", Depth: 1, StatusCode: 404, Inbound: []string{"https://example.com/"}},
+			{URL: "https://example.com/new", Depth: 1, StatusCode: 200, Title: "=cmd", Inbound: []string{"https://example.com/old"}},
+		},
+		Orphans: []string{"https://example.com/orphan"},
+	}
+}
+
+// renderGraph writes the sample graph in the given format
+func renderGraph(t *testing.T, format string) string {
+	t.Helper()
+	var b bytes.Buffer
+	if err := WriteGraph(&b, format, sampleGraph()); err != nil {
+		t.Fatalf("WriteGraph(%s) returned error: %v", format, err)
+	}
+	return b.String()
+}
+
+// TestWriteGraphDOT checks nodes, escaping, state colours and redirect edges
+func TestWriteGraphDOT(t *testing.T) {
+	out := renderGraph(t, FormatDOT)
+	for _, want := range []string{
+		"digraph links {",
+		`"https://example.com/" [label="/\ndepth 0", tooltip="Home \"page\""];`,
+		`"https://example.com/missing" [label="/missing\ndepth 1", tooltip="", color=red];`,
+		`"https://example.com/orphan" [label="/orphan\norphan", tooltip="", color=purple];`,
+		`"https://example.com/" -> "https://example.com/old";`,
+		`"https://example.com/old" -> "https://example.com/new" [style=dashed, label="301"];`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "/beyond") {
+		t.Errorf("DOT output links to a URL that was not crawled:\n%s", out)
+	}
+}
+
+// TestWriteGraphML checks that the output is GraphML with one node per page and orphan
+func TestWriteGraphML(t *testing.T) {
+	var doc graphML
+	if err := xml.Unmarshal([]byte(renderGraph(t, FormatGraphML)), &doc); err != nil {
+		t.Fatalf("GraphML output is not XML: %v", err)
+	}
+	if doc.Graph.EdgeDefault != "directed" || len(doc.Graph.Nodes) != 5 || len(doc.Graph.Edges) != 3 {
+		t.Fatalf("graph = %d nodes, %d edges; want 5 nodes and 3 directed edges", len(doc.Graph.Nodes), len(doc.Graph.Edges))
+	}
+	if e := doc.Graph.Edges[2]; e.Source != "n1" || e.Target != "n3" || e.Data[0].Value != "redirect" {
+		t.Errorf("edge = %+v; want the redirect from /old to /new", e)
+	}
+	if n := doc.Graph.Nodes[4]; n.Data[0].Value != "https://example.com/orphan" || n.Data[4].Value != "orphan" {
+		t.Errorf("node = %+v; want the orphan", n)
+	}
+}
+
+// TestWriteGraphCSV checks one row per node with states and defused values
+func TestWriteGraphCSV(t *testing.T) {
+	rows, err := csv.NewReader(strings.NewReader(renderGraph(t, FormatCSV))).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV output does not parse: %v", err)
+	}
+	if len(rows) != 6 || strings.Join(rows[0], ",") != strings.Join(graphCSVHeader, ",") {
+		t.Fatalf("rows = %v; want the header and 5 nodes", rows)
+	}
+	var states []string
+	for _, row := range rows[1:] {
+		states = append(states, row[2])
+	}
+	if strings.Join(states, " ") != "ok redirect broken ok orphan" {
+		t.Errorf("states = %v", states)
+	}
+	if rows[4][5] != "'=cmd" || rows[5][1] != "" {
+		t.Errorf("rows = %v; want the formula defused and no depth for the orphan", rows[4:])
+	}
+}
+
+// TestWriteGraph_UnknownFormat checks the error and the download names
+func TestWriteGraph_UnknownFormat(t *testing.T) {
+	if err := WriteGraph(&bytes.Buffer{}, "pdf", sampleGraph()); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("err = %v; want ErrUnknownFormat", err)
+	}
+	if name := GraphFilename(FormatGraphML, sampleGraph()); name != "example.com-links.graphml" {
+		t.Errorf("GraphFilename = %q", name)
+	}
+}
@@ -0,0 +1,36 @@
+package report
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"lucytech/parser"
+)
+
+// htmlSource is the printable report template. It is embedded so the report
+// does not depend on the working directory or on any external stylesheet.
+//
+//go:embed report.html
+var htmlSource string
+
+// htmlTemplate renders the printable HTML report.
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"linkType":   linkType,
+	"linkStatus": linkStatus,
+}).Parse(htmlSource))
+
+// htmlData is passed to htmlTemplate.
+type htmlData struct {
+	Result    *parser.AnalysisResult
+	Summary   []field
+	Generated string
+}
+
+// writeHTML writes a standalone page with print styles, which browsers can save as PDF.
+func writeHTML(w io.Writer, r *parser.AnalysisResult) error {
+	return htmlTemplate.Execute(w, htmlData{
+		Result:    r,
+		Summary:   summary(r),
+		Generated: now().UTC().Format("2006-01-02 15:04 MST"),
+	})
+}
@@ -0,0 +1,105 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+	"lucytech/parser"
+	"strings"
+)
+
+// junitSuites is the root element of a JUnit XML report.
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+// junitSuite holds the checks of one page.
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+// junitCase is one check; at most one of Failure, Error and Skipped is set.
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+// junitProblem describes why a test case failed.
+type junitProblem struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+	Details string `xml:",chardata"`
+}
+
+// junitSkipped marks a test case that did not run.
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes a JUnit XML report with one test suite per page and one test
+// case per check. Findings become failures whatever their level, and a page that
+// could not be analyzed becomes an error.
+func WriteJUnit(w io.Writer, pages []Page) error {
+	root := junitSuites{Name: "lucytech"}
+	for _, p := range pages {
+		suite := junitSuite{Name: p.URL}
+		for _, c := range checks(p) {
+			tc := junitCase{Name: c.name, ClassName: p.URL}
+			switch {
+			case c.skipped != "":
+				tc.Skipped = &junitSkipped{Message: c.skipped}
+				suite.Skipped++
+			case len(c.findings) > 0:
+				messages := make([]string, len(c.findings))
+				for i, f := range c.findings {
+					messages[i] = f.Message
+				}
+				problem := &junitProblem{Type: c.findings[0].RuleID, Message: messages[0], Details: strings.Join(messages, "\n")}
+				if c.findings[0].RuleID == RuleAnalysisFailed {
+					tc.Error = problem
+					suite.Errors++
+				} else {
+					tc.Failure = problem
+					suite.Failures++
+				}
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suite.Tests = len(suite.Cases)
+
+		root.Tests += suite.Tests
+		root.Failures += suite.Failures
+		root.Errors += suite.Errors
+		root.Skipped += suite.Skipped
+		root.Suites = append(root.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeJUnit writes a JUnit report for a single result.
+func writeJUnit(w io.Writer, r *parser.AnalysisResult) error {
+	return WriteJUnit(w, pageOf(r))
+}
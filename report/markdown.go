@@ -0,0 +1,89 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"lucytech/parser"
+	"strings"
+)
+
+// writeMarkdown writes a summary suitable for pasting into tickets.
+// Links are limited to the ones that failed, which are the ones worth discussing.
+func writeMarkdown(w io.Writer, r *parser.AnalysisResult) error {
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintf(b, "# Web Page Analysis Report\n\n")
+	fmt.Fprintf(b, "_Generated %s_\n\n", now().UTC().Format("2006-01-02 15:04 MST"))
+
+	fmt.Fprintf(b, "| | |\n|---|---|\n")
+	for _, f := range summary(r) {
+		fmt.Fprintf(b, "| **%s** | %s |\n", f.Label, mdCell(mdText(f.Value)))
+	}
+
+	if lines := headingLines(r.Outline, 0); len(lines) > 0 {
+		fmt.Fprintf(b, "\n## Heading Outline\n\n")
+		for _, line := range lines {
+			trimmed := strings.TrimLeft(line, " ")
+			fmt.Fprintf(b, "%s- %s\n", line[:len(line)-len(trimmed)], mdText(trimmed))
+		}
+	}
+
+	if len(r.OutlineIssues) > 0 {
+		fmt.Fprintf(b, "\n## Outline Issues\n\n")
+		for _, issue := range r.OutlineIssues {
+			if issue.Position > 0 {
+				fmt.Fprintf(b, "- #%d: %s\n", issue.Position, mdText(issue.Message))
+			} else {
+				fmt.Fprintf(b, "- %s\n", mdText(issue.Message))
+			}
+		}
+	}
+
+	if len(r.Assertions) > 0 {
+		fmt.Fprintf(b, "\n## Assertions\n\n| Assertion | Selector | Matches | Result |\n|---|---|---|---|\n")
+		for _, a := range r.Assertions {
+			outcome := "✅ Pass"
+			if !a.Passed {
+				outcome = "❌ Fail " + a.Message
+			}
+			fmt.Fprintf(b, "| %s | `%s` | %d | %s |\n", mdCell(mdText(a.Name)), strings.ReplaceAll(mdCell(a.Selector), "`", "'"), a.Matches, mdCell(mdText(outcome)))
+		}
+	}
+
+	var broken []parser.LinkResult
+	for _, l := range r.Links {
+		if l.Checked && !l.Accessible {
+			broken = append(broken, l)
+		}
+	}
+	if len(broken) > 0 {
+		fmt.Fprintf(b, "\n## Inaccessible Links\n\n| Link | Type | Status |\n|---|---|---|\n")
+		for _, l := range broken {
+			fmt.Fprintf(b, "| <%s> | %s | %s |\n", mdCell(l.URL), linkType(l), mdCell(linkStatus(l)))
+		}
+	}
+
+	var missing []parser.LinkResult
+	for _, l := range r.Links {
+		if l.MissingAnchor {
+			missing = append(missing, l)
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(b, "\n## Missing Anchors\n\nThese links load, but their target has no element with the fragment as `id` or `name`.\n\n")
+		for _, l := range missing {
+			fmt.Fprintf(b, "- <%s>\n", mdCell(l.URL))
+		}
+	}
+
+	return b.Flush()
+}
+
+// mdText escapes characters that Markdown would interpret in page-supplied text.
+var mdText = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `&lt;`, ">", `&gt;`, "#", `\#`,
+).Replace
+
+// mdCell escapes text for a Markdown table cell, which must stay on one line.
+var mdCell = strings.NewReplacer("|", `\|`, "\r", " ", "\n", " ").Replace
@@ -0,0 +1,284 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"lucytech/parser"
+	"strings"
+)
+
+// A4 page geometry in PDF points (1/72 inch).
+const (
+	pageWidth  = 595.28
+	pageHeight = 841.89
+	pageMargin = 50.0
+)
+
+// pdfStyle selects the font, size and colour of a line of text.
+type pdfStyle struct {
+	font  string  // Font resource name, "F1" (Helvetica) or "F2" (Helvetica-Bold)
+	size  float64 // Font size in points
+	color string  // Fill colour operator, e.g. "0 0 0 rg"
+}
+
+// Text styles used by the PDF report.
+var (
+	styleTitle   = pdfStyle{"F2", 18, "0 0 0 rg"}
+	styleHeading = pdfStyle{"F2", 13, "0 0 0 rg"}
+	styleLabel   = pdfStyle{"F2", 10, "0 0 0 rg"}
+	styleBody    = pdfStyle{"F1", 10, "0 0 0 rg"}
+	styleMuted   = pdfStyle{"F1", 9, "0.4 0.4 0.4 rg"}
+	styleFail    = pdfStyle{"F1", 10, "0.78 0.16 0.16 rg"}
+)
+
+// pdfDoc lays out lines of text on A4 pages. It supports just enough of PDF for
+// a text report: the standard Helvetica fonts, wrapping and page breaks.
+type pdfDoc struct {
+	pages []*bytes.Buffer // Content stream of each page
+	y     float64         // Baseline of the next line on the current page
+}
+
+// writePDF writes the report as a PDF document.
+func writePDF(w io.Writer, r *parser.AnalysisResult) error {
+	d := &pdfDoc{}
+	d.newPage()
+
+	d.line(styleTitle, 0, "Web Page Analysis Report")
+	d.line(styleMuted, 0, "Generated "+now().UTC().Format("2006-01-02 15:04 MST"))
+	d.gap(8)
+	for _, f := range summary(r) {
+		d.pair(f.Label, f.Value)
+	}
+
+	if lines := headingLines(r.Outline, 0); len(lines) > 0 {
+		d.section("Heading Outline")
+		for _, line := range lines {
+			trimmed := strings.TrimLeft(line, " ")
+			d.line(styleBody, float64(len(line)-len(trimmed))*6, trimmed)
+		}
+	}
+
+	if len(r.OutlineIssues) > 0 {
+		d.section("Outline Issues")
+		for _, issue := range r.OutlineIssues {
+			msg := issue.Message
+			if issue.Position > 0 {
+				msg = fmt.Sprintf("#%d: %s", issue.Position, msg)
+			}
+			d.line(styleFail, 0, "• "+msg)
+		}
+	}
+
+	if len(r.Assertions) > 0 {
+		d.section("Assertions")
+		for _, a := range r.Assertions {
+			if a.Passed {
+				d.line(styleBody, 0, fmt.Sprintf("Pass  %s (%s, %d matches)", a.Name, a.Selector, a.Matches))
+			} else {
+				d.line(styleFail, 0, fmt.Sprintf("Fail  %s (%s, %d matches): %s", a.Name, a.Selector, a.Matches, a.Message))
+			}
+		}
+	}
+
+	if len(r.Links) > 0 {
+		d.section("Links")
+		for _, l := range r.Links {
+			style := styleBody
+			if (l.Checked && !l.Accessible) || l.MissingAnchor {
+				style = styleFail
+			}
+			d.line(style, 0, fmt.Sprintf("[%s, %s] %s", linkType(l), linkStatus(l), l.URL))
+		}
+	}
+
+	_, err := w.Write(d.bytes())
+	return err
+}
+
+// newPage starts a new page at the top margin.
+func (d *pdfDoc) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pageHeight - pageMargin
+}
+
+// gap adds vertical space, starting a new page if it runs past the bottom margin.
+func (d *pdfDoc) gap(h float64) {
+	d.y -= h
+	if d.y < pageMargin {
+		d.newPage()
+	}
+}
+
+// section starts a titled section.
+func (d *pdfDoc) section(title string) {
+	d.gap(10)
+	d.line(styleHeading, 0, title)
+	d.gap(2)
+}
+
+// pair writes a bold label followed by its value, wrapped in a column.
+func (d *pdfDoc) pair(label, value string) {
+	const column = 120.0
+	lines := wrap(value, styleBody, pageWidth-2*pageMargin-column)
+	d.ensure(styleBody.size * 1.4)
+	d.show(styleLabel, pageMargin, label)
+	for i, line := range lines {
+		if i > 0 {
+			d.ensure(styleBody.size * 1.4)
+		}
+		d.show(styleBody, pageMargin+column, line)
+		d.y -= styleBody.size * 1.4
+	}
+}
+
+// line writes text indented from the left margin, wrapping it as needed.
+func (d *pdfDoc) line(s pdfStyle, indent float64, text string) {
+	for _, l := range wrap(text, s, pageWidth-2*pageMargin-indent) {
+		d.ensure(s.size * 1.4)
+		d.show(s, pageMargin+indent, l)
+		d.y -= s.size * 1.4
+	}
+}
+
+// ensure starts a new page unless a line of the given height fits on the current one.
+func (d *pdfDoc) ensure(height float64) {
+	if d.y-height < pageMargin {
+		d.newPage()
+	}
+}
+
+// show draws a single line of text with its baseline at the current position.
+func (d *pdfDoc) show(s pdfStyle, x float64, text string) {
+	fmt.Fprintf(d.pages[len(d.pages)-1], "%s BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n",
+		s.color, s.font, s.size, x, d.y-s.size, pdfString(text))
+}
+
+// bytes assembles the document: catalog, page tree, fonts, pages and cross-reference table.
+func (d *pdfDoc) bytes() []byte {
+	var objects []string // Object bodies; object n is objects[n-1]
+	add := func(body string) int {
+		objects = append(objects, body)
+		return len(objects)
+	}
+
+	catalog := add("") // Filled in once the page tree exists
+	pagesObj := add("")
+	helvetica := add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	bold := add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	info := add("<< /Title (Web Page Analysis Report) /Producer (lucytech) >>")
+
+	var kids []string
+	for i, content := range d.pages {
+		// Page numbers are added last, when the page count is known
+		footer := fmt.Sprintf("Page %d of %d", i+1, len(d.pages))
+		fmt.Fprintf(content, "%s BT /F1 %.1f Tf %.2f %.2f Td (%s) Tj ET\n", styleMuted.color, styleMuted.size,
+			pageWidth-pageMargin-textWidth(footer, styleMuted), pageMargin/2, footer)
+
+		stream := add(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+		page := add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> /Contents %d 0 R >>",
+			pagesObj, pageWidth, pageHeight, helvetica, bold, stream))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	objects[catalog-1] = fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj)
+	objects[pagesObj-1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n") // The binary comment marks the file as binary for transfer tools
+	offsets := make([]int, len(objects))
+	for i, body := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, catalog, info, xref)
+	return b.Bytes()
+}
+
+// wrap breaks text into lines no wider than width, splitting overlong words such as URLs.
+func wrap(text string, s pdfStyle, width float64) []string {
+	var lines []string
+	var cur string
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if cur != "" {
+			candidate = cur + " " + word
+		}
+		if textWidth(candidate, s) <= width {
+			cur = candidate
+			continue
+		}
+		if cur != "" {
+			lines = append(lines, cur)
+		}
+		// Hard-break words that do not fit on a line of their own
+		cur = ""
+		for _, r := range word {
+			if cur != "" && textWidth(cur+string(r), s) > width {
+				lines = append(lines, cur)
+				cur = ""
+			}
+			cur += string(r)
+		}
+	}
+	if cur != "" || len(lines) == 0 {
+		lines = append(lines, cur)
+	}
+	return lines
+}
+
+// textWidth estimates the rendered width of text from approximate Helvetica glyph widths.
+func textWidth(text string, s pdfStyle) float64 {
+	var units float64 // Thousandths of the font size
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune("ijl.,:;'|!", r):
+			units += 278
+		case strings.ContainsRune(" ft/()[]-", r):
+			units += 333
+		case strings.ContainsRune("mwMW@", r):
+			units += 889
+		case r >= 'A' && r <= 'Z':
+			units += 700
+		default:
+			units += 556
+		}
+	}
+	if s.font == "F2" {
+		units *= 1.06 // Bold glyphs are slightly wider
+	}
+	return units * s.size / 1000
+}
+
+// winAnsi maps the typographic characters outside Latin-1 that WinAnsiEncoding supports.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '→': '>',
+}
+
+// pdfString encodes text as the body of a PDF literal string in WinAnsiEncoding.
+// Characters the standard fonts cannot show are replaced with "?".
+func pdfString(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		case winAnsi[r] != 0:
+			fmt.Fprintf(&b, "\\%03o", winAnsi[r])
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
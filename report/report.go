@@ -0,0 +1,196 @@
+// Package report renders analysis results as downloadable reports: CSV, pretty JSON,
+// Markdown, a self-contained printable HTML page and PDF, and the JUnit XML and SARIF
+// formats understood by CI tooling. Everything is generated in-process without
+// external services.
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"lucytech/parser"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report formats.
+const (
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatPDF      = "pdf"
+	FormatJUnit    = "junit"
+	FormatSARIF    = "sarif"
+)
+
+// ErrUnknownFormat is returned for a format that is not one of Formats.
+var ErrUnknownFormat = errors.New("unknown report format")
+
+// format describes how a report format is written and served.
+type format struct {
+	contentType string
+	extension   string
+	write       func(w io.Writer, r *parser.AnalysisResult) error
+}
+
+// formats maps format names to their writers.
+var formats = map[string]format{
+	FormatCSV:      {"text/csv; charset=utf-8", "csv", writeCSV},
+	FormatJSON:     {"application/json", "json", writeJSON},
+	FormatMarkdown: {"text/markdown; charset=utf-8", "md", writeMarkdown},
+	FormatHTML:     {"text/html; charset=utf-8", "html", writeHTML},
+	FormatPDF:      {"application/pdf", "pdf", writePDF},
+	FormatJUnit:    {"application/xml; charset=utf-8", "junit.xml", writeJUnit},
+	FormatSARIF:    {"application/sarif+json", "sarif", writeSARIF},
+}
+
+// Formats lists the supported formats in the order they are offered to users.
+var Formats = []string{FormatCSV, FormatJSON, FormatMarkdown, FormatHTML, FormatPDF, FormatJUnit, FormatSARIF}
+
+// now returns the generation time printed in reports; replaceable in tests.
+var now = time.Now
+
+// Write renders the result in the given format.
+func Write(w io.Writer, name string, r *parser.AnalysisResult) error {
+	f, ok := formats[name]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+	}
+	return f.write(w, r)
+}
+
+// ContentType returns the MIME type of a format, or "" if the format is unknown.
+func ContentType(name string) string {
+	return formats[name].contentType
+}
+
+// Filename suggests a download name for a report, e.g. "example.com-report.pdf".
+func Filename(name string, r *parser.AnalysisResult) string {
+	base := "analysis"
+	if u, err := url.Parse(r.URL); err == nil && u.Hostname() != "" {
+		base = u.Hostname()
+	}
+	return base + "-report." + formats[name].extension
+}
+
+// writeJSON writes the result as indented JSON.
+func writeJSON(w io.Writer, r *parser.AnalysisResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// field is a labelled value of the report summary.
+type field struct {
+	Label, Value string
+}
+
+// summary returns the headline figures shared by the Markdown, HTML and PDF reports.
+func summary(r *parser.AnalysisResult) []field {
+	source := r.URL
+	if source == "" {
+		source = "uploaded HTML"
+	}
+	fields := []field{
+		{"URL", source},
+		{"Title", r.Title},
+		{"HTML version", r.HTMLVersion},
+		{"Mode", r.Mode},
+		{"Internal links", strconv.Itoa(r.InternalLinks)},
+		{"External links", strconv.Itoa(r.ExternalLinks)},
+		{"Inaccessible links", strconv.Itoa(r.InaccessibleLinks)},
+		{"Missing anchors", strconv.Itoa(r.MissingAnchors)},
+		{"Login form", yesNo(r.LoginForm)},
+	}
+	if r.DisplayURL != "" {
+		fields = slices.Insert(fields, 1, field{"Unicode URL", r.DisplayURL})
+	}
+	if r.InsecureTLS {
+		fields = append(fields, field{"TLS verification", "skipped"})
+	}
+	if len(r.OtherLinks) > 0 {
+		fields = append(fields, field{"Other links", counts(r.OtherLinks)})
+	}
+	if len(r.RelLinks) > 0 {
+		fields = append(fields, field{"Link rel", counts(r.RelLinks)})
+	}
+	if len(r.Assertions) > 0 {
+		passed := 0
+		for _, a := range r.Assertions {
+			if a.Passed {
+				passed++
+			}
+		}
+		fields = append(fields, field{"Assertions passed", fmt.Sprintf("%d of %d", passed, len(r.Assertions))})
+	}
+	return fields
+}
+
+// counts lists tallies by name, e.g. "mailto 2, tel 1".
+func counts(m map[string]int) string {
+	var parts []string
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		parts = append(parts, fmt.Sprintf("%s %d", k, m[k]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// linkType names the kind of a link for the report tables: internal, external or the
+// scheme of a non-web link such as mailto.
+func linkType(l parser.LinkResult) string {
+	if l.Scheme != "" {
+		return l.Scheme
+	}
+	if l.Internal {
+		return "internal"
+	}
+	return "external"
+}
+
+// linkStatus describes the outcome of a link check, e.g. "404" or "not checked".
+func linkStatus(l parser.LinkResult) string {
+	switch {
+	case l.MissingAnchor:
+		return "missing anchor"
+	case !l.Checked:
+		return "not checked"
+	case l.StatusCode != 0 && l.Accessible:
+		return strconv.Itoa(l.StatusCode) + " OK"
+	case l.StatusCode != 0:
+		return strconv.Itoa(l.StatusCode)
+	case l.Accessible:
+		return "OK"
+	case l.Error != "":
+		return "error: " + l.Error
+	default:
+		return "inaccessible"
+	}
+}
+
+// headingLines flattens the outline into indented lines, e.g. "  H2 Pricing".
+func headingLines(headings []*parser.Heading, depth int) []string {
+	var lines []string
+	for _, h := range headings {
+		text := h.Text
+		if text == "" {
+			text = "(empty)"
+		}
+		lines = append(lines, strings.Repeat("  ", depth)+h.Tag()+" "+text)
+		lines = append(lines, headingLines(h.Children, depth+1)...)
+	}
+	return lines
+}
+
+// yesNo formats a boolean for humans.
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,187 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lucytech/parser"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sampleResult returns a result exercising every report section
+func sampleResult() *parser.AnalysisResult {
+	return &parser.AnalysisResult{
+		URL:               "https://example.com/pricing",
+		HTMLVersion:       "HTML 5",
+		Title:             "Plans | Pricing (2025)",
+		Outline:           []*parser.Heading{{Level: 1, Text: "Pricing", Position: 1, Children: []*parser.Heading{{Level: 2, Text: "Teams", Position: 2}}}},
+		OutlineIssues:     []parser.OutlineIssue{{Position: 3, Message: "h4 skips level h3"}},
+		InternalLinks:     1,
+		ExternalLinks:     2,
+		InaccessibleLinks: 1,
+		Links: []parser.LinkResult{
+			{URL: "https://example.com/signup", Internal: true, Checked: true, Accessible: true, StatusCode: 200},
+			{URL: "https://partner.example.org/", Checked: true, StatusCode: 404},
+			{URL: "=HYPERLINK(\"https://evil.example\")", Checked: true, Error: "unsupported protocol scheme"},
+		},
+		Assertions: []parser.AssertionResult{
+			{Assertion: parser.Assertion{Name: "One H1", Selector: "h1", Expect: "count", Count: 1}, Matches: 1, Passed: true},
+			{Assertion: parser.Assertion{Name: "No banner", Selector: ".banner", Expect: "absent"}, Matches: 2, Message: "found 2 elements"},
+		},
+		Mode: parser.ModeRaw,
+	}
+}
+
+// withFixedTime pins the generation time printed in reports
+func withFixedTime(t *testing.T) {
+	t.Helper()
+	orig := now
+	now = func() time.Time { return time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC) }
+	t.Cleanup(func() { now = orig })
+}
+
+// render writes the sample result in the given format
+func render(t *testing.T, format string) string {
+	t.Helper()
+	var b bytes.Buffer
+	if err := Write(&b, format, sampleResult()); err != nil {
+		t.Fatalf("Write(%s) returned error: %v", format, err)
+	}
+	return b.String()
+}
+
+// TestWriteCSV checks one row per link with formula-like values defused
+func TestWriteCSV(t *testing.T) {
+	rows, err := csv.NewReader(strings.NewReader(render(t, FormatCSV))).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	want := [][]string{
+		csvHeader,
+		{"https://example.com/signup", "internal", "true", "true", "200", "", "false", ""},
+		{"https://partner.example.org/", "external", "true", "false", "404", "", "false", ""},
+		{"'=HYPERLINK(\"https://evil.example\")", "external", "true", "false", "", "unsupported protocol scheme", "false", ""},
+	}
+	if fmt.Sprint(rows) != fmt.Sprint(want) {
+		t.Errorf("rows = %q; want %q", rows, want)
+	}
+}
+
+// TestWriteJSON checks that the JSON report round-trips
+func TestWriteJSON(t *testing.T) {
+	out := render(t, FormatJSON)
+	if !strings.Contains(out, "\n  \"url\"") {
+		t.Errorf("JSON is not indented: %s", out)
+	}
+	var got parser.AnalysisResult
+	if err := json.Unmarshal([]byte(out), &got); err != nil || len(got.Links) != 3 || got.Title != sampleResult().Title {
+		t.Errorf("round trip = %+v, %v", got, err)
+	}
+}
+
+// TestWriteMarkdown checks the summary table, escaping and the broken link list
+func TestWriteMarkdown(t *testing.T) {
+	withFixedTime(t)
+	out := render(t, FormatMarkdown)
+	for _, want := range []string{
+		"_Generated 2025-03-01 09:30 UTC_",
+		`| **Title** | Plans \| Pricing (2025) |`,
+		"- H1 Pricing\n  - H2 Teams\n",
+		"- #3: h4 skips level h3",
+		"| No banner | `.banner` | 2 | ❌ Fail found 2 elements |",
+		"| <https://partner.example.org/> | external | 404 |",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Markdown lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "example.com/signup") {
+		t.Errorf("Markdown lists an accessible link:\n%s", out)
+	}
+}
+
+// TestWriteHTML checks that the printable report is self-contained and escaped
+func TestWriteHTML(t *testing.T) {
+	withFixedTime(t)
+	r := sampleResult()
+	r.Title = "<script>alert(1)</script>"
+	var b bytes.Buffer
+	if err := Write(&b, FormatHTML, r); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	out := b.String()
+	if strings.Contains(out, "<script>alert") {
+		t.Error("page title is not escaped")
+	}
+	for _, want := range []string{"@media print", "Generated 2025-03-01 09:30 UTC", "<td class=\"fail\">404</td>", "https://example.com/signup"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("HTML lacks %q", want)
+		}
+	}
+	if regexp.MustCompile(`<(link|script)[^>]+(href|src)=`).MatchString(out) {
+		t.Error("HTML report references external resources")
+	}
+}
+
+// TestWritePDF checks the document structure, escaping and pagination
+func TestWritePDF(t *testing.T) {
+	r := sampleResult()
+	for i := range 100 {
+		r.Links = append(r.Links, parser.LinkResult{URL: fmt.Sprintf("https://example.com/page/%d", i), Internal: true})
+	}
+	var b bytes.Buffer
+	if err := Write(&b, FormatPDF, r); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	out := b.String()
+
+	if !strings.HasPrefix(out, "%PDF-1.4\n") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Fatal("missing PDF header or trailer")
+	}
+	// startxref must point at the cross-reference table, and each entry at its object
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(out)
+	xref, _ := strconv.Atoi(m[1])
+	if !strings.HasPrefix(out[xref:], "xref\n") {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(out[xref:], -1)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(e[1])
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(out[off:], want) {
+			t.Errorf("xref entry %d points at %q; want %q", i+1, out[off:off+10], want)
+		}
+	}
+
+	if got := strings.Count(out, "/Type /Page "); got < 2 {
+		t.Errorf("pages = %d; want the links to overflow onto a second page", got)
+	}
+	if !strings.Contains(out, `(Plans | Pricing \(2025\))`) {
+		t.Error("parentheses in text are not escaped")
+	}
+}
+
+// TestWrite_UnknownFormat checks that unsupported formats are rejected
+func TestWrite_UnknownFormat(t *testing.T) {
+	if err := Write(&bytes.Buffer{}, "docx", sampleResult()); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("err = %v; want ErrUnknownFormat", err)
+	}
+	if ContentType("docx") != "" {
+		t.Error("ContentType of an unknown format is not empty")
+	}
+}
+
+// TestFilename checks download names
+func TestFilename(t *testing.T) {
+	if got := Filename(FormatMarkdown, sampleResult()); got != "example.com-report.md" {
+		t.Errorf("Filename = %q", got)
+	}
+	if got := Filename(FormatPDF, &parser.AnalysisResult{}); got != "analysis-report.pdf" {
+		t.Errorf("Filename for uploads = %q", got)
+	}
+}
@@ -0,0 +1,98 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"lucytech/parser"
+	"lucytech/version"
+)
+
+// SARIF 2.1.0 document structure, limited to the properties the analyzer fills in.
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		Version        string      `json:"version"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID                   string       `json:"id"`
+		Name                 string       `json:"name"`
+		ShortDescription     sarifMessage `json:"shortDescription"`
+		DefaultConfiguration struct {
+			Level string `json:"level"`
+		} `json:"defaultConfiguration"`
+	}
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		RuleIndex int             `json:"ruleIndex"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+		} `json:"physicalLocation"`
+	}
+)
+
+// WriteSARIF writes the findings on the pages as a SARIF 2.1.0 log. Each finding is a
+// result located at the page it was found on.
+func WriteSARIF(w io.Writer, pages []Page) error {
+	driver := sarifDriver{
+		Name:           "lucytech",
+		Version:        version.Get().Version,
+		InformationURI: "https://github.com/nasminspy/lucytech",
+	}
+	ruleIndex := make(map[string]int, len(rules))
+	for i, r := range rules {
+		sr := sarifRule{ID: r.ID, Name: r.Name, ShortDescription: sarifMessage{r.Description}}
+		sr.DefaultConfiguration.Level = r.Level
+		driver.Rules = append(driver.Rules, sr)
+		ruleIndex[r.ID] = i
+	}
+
+	results := []sarifResult{} // Never null: an empty run means no findings
+	for _, f := range Findings(pages) {
+		var loc sarifLocation
+		loc.PhysicalLocation.ArtifactLocation.URI = f.Page
+		results = append(results, sarifResult{
+			RuleID:    f.RuleID,
+			RuleIndex: ruleIndex[f.RuleID],
+			Level:     f.Level,
+			Message:   sarifMessage{f.Message},
+			Locations: []sarifLocation{loc},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	})
+}
+
+// writeSARIF writes a SARIF log for a single result.
+func writeSARIF(w io.Writer, r *parser.AnalysisResult) error {
+	return WriteSARIF(w, pageOf(r))
+}
@@ -0,0 +1,41 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WriteText writes a plain-text summary of the findings on each page, as printed by
+// the command-line mode, e.g.
+//
+//	FAIL https://example.com (12 checks, 1 finding)
+//	  error    broken-link  internal link https://example.com/old is inaccessible (404)
+func WriteText(w io.Writer, pages []Page) error {
+	b := bufio.NewWriter(w)
+	for _, p := range pages {
+		cs := checks(p)
+		var fs []Finding
+		for _, c := range cs {
+			fs = append(fs, c.findings...)
+		}
+
+		verdict := "PASS"
+		if len(fs) > 0 {
+			verdict = "FAIL"
+		}
+		fmt.Fprintf(b, "%s %s (%s, %s)\n", verdict, p.URL, plural(len(cs), "check"), plural(len(fs), "finding"))
+		for _, f := range fs {
+			fmt.Fprintf(b, "  %-8s %-17s %s\n", f.Level, f.RuleID, f.Message)
+		}
+	}
+	return b.Flush()
+}
+
+// plural formats a count with its noun, e.g. "1 check" or "3 checks".
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
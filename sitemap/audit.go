@@ -0,0 +1,397 @@
+package sitemap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"lucytech/logging"
+	"lucytech/metrics"
+	"lucytech/parser"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// userAgent identifies the requests of sitemap audits.
+const userAgent = "lucytech-sitemap"
+
+// Issue types reported by sitemap audits.
+const (
+	IssueSitemapError   = "sitemap_error"     // A sitemap could not be fetched or parsed
+	IssueInvalidURL     = "invalid_url"       // A loc is not an absolute HTTP(S) URL
+	IssueOffSite        = "off_site"          // A loc is on another host than the site
+	IssueDuplicate      = "duplicate"         // A URL is listed more than once
+	IssueInvalidLastmod = "invalid_lastmod"   // A lastmod is not a W3C Datetime
+	IssueBlocked        = "blocked_by_robots" // A listed URL is disallowed by robots.txt
+	IssueRedirect       = "redirect"          // A listed URL redirects
+	IssueStatus         = "non_200"           // A listed URL answers with another status than 200, or not at all
+	IssueUnlisted       = "not_in_sitemap"    // A page is linked internally but not listed
+	IssueTruncated      = "truncated"         // The audit stopped at the configured limits
+)
+
+// ErrNoSitemap is returned when none of the discovered sitemaps could be read.
+var ErrNoSitemap = errors.New("no readable sitemap found")
+
+// httpClient fetches robots.txt and the sitemaps; replaceable in tests.
+var httpClient = &http.Client{
+	Timeout:   30 * time.Second,
+	Transport: parser.SharedTransport,
+}
+
+// checkClient checks the listed URLs. It does not follow redirects, so they can be reported.
+var checkClient = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: parser.SharedTransport,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Config bounds a sitemap audit. Zero values select the defaults.
+type Config struct {
+	MaxURLs     int // Entries read across all sitemaps; default 1000
+	MaxSitemaps int // Sitemaps read, indexes included; default 50
+	Parallelism int // Listed URLs checked at once; default 8
+}
+
+// Sitemap describes one sitemap or sitemap index that was read.
+type Sitemap struct {
+	URL     string `json:"url"`
+	Index   bool   `json:"index"`           // True for a sitemap index
+	Entries int    `json:"entries"`         // Listed URLs, or sitemaps for an index
+	Error   string `json:"error,omitempty"` // Why the sitemap could not be read
+}
+
+// Entry is a URL listed in a sitemap, with the outcome of checking it.
+type Entry struct {
+	URL        string `json:"url"`
+	Lastmod    string `json:"lastmod,omitempty"`
+	Sitemap    string `json:"sitemap"`               // Sitemap that lists the URL
+	Blocked    bool   `json:"blocked"`               // Disallowed by robots.txt, so it was not fetched
+	StatusCode int    `json:"status_code,omitempty"` // Status of the check, 0 if not checked or no response
+	Location   string `json:"location,omitempty"`    // Redirect target
+	Error      string `json:"error,omitempty"`       // Transport error of the check
+}
+
+// Issue is a problem found by the audit.
+type Issue struct {
+	Type    string `json:"type"`
+	URL     string `json:"url"`               // The URL the problem is about
+	Sitemap string `json:"sitemap,omitempty"` // Sitemap listing the URL, if any
+	Detail  string `json:"detail"`
+}
+
+// Report is the outcome of a sitemap audit.
+type Report struct {
+	Site     string    `json:"site"`             // Origin of the audited site
+	Robots   string    `json:"robots,omitempty"` // URL of robots.txt, if the site has one
+	Sitemaps []Sitemap `json:"sitemaps"`         // In the order they were read
+	Entries  []Entry   `json:"entries"`          // Distinct listed URLs in sitemap order
+	Issues   []Issue   `json:"issues"`
+
+	robots *Robots
+	listed map[string]bool // Normalized entry URLs
+}
+
+// Audit discovers and reads the sitemaps of a site and checks every listed URL.
+// rawURL is either the site, whose sitemaps are taken from robots.txt and fall back
+// to /sitemap.xml, or the URL of a sitemap ending in .xml or .xml.gz.
+func Audit(ctx context.Context, rawURL string, cfg Config) (*Report, error) {
+	if cfg.Parallelism <= 0 {
+		cfg.Parallelism = 8
+	}
+	r, err := Read(ctx, rawURL, cfg)
+	if err != nil {
+		return nil, err
+	}
+	r.check(ctx, cfg.Parallelism)
+
+	for _, issue := range r.Issues {
+		metrics.SitemapIssues.WithLabelValues(issue.Type).Inc()
+	}
+	logging.FromContext(ctx).Info("Sitemap audit complete", "site", r.Site, "sitemaps", len(r.Sitemaps), "entries", len(r.Entries), "issues", len(r.Issues))
+	return r, nil
+}
+
+// Read discovers and reads the sitemaps of a site like Audit, without requesting the
+// listed URLs. The report holds the problems found in the sitemaps themselves.
+func Read(ctx context.Context, rawURL string, cfg Config) (*Report, error) {
+	if cfg.MaxURLs <= 0 {
+		cfg.MaxURLs = 1000
+	}
+	if cfg.MaxSitemaps <= 0 {
+		cfg.MaxSitemaps = 50
+	}
+
+	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+		rawURL = "https://" + rawURL
+	}
+	site, err := url.Parse(rawURL)
+	if err != nil || site.Host == "" {
+		return nil, fmt.Errorf("%w: %s", parser.ErrInvalidURL, rawURL)
+	}
+	origin := site.Scheme + "://" + site.Host
+	r := &Report{Site: origin, Issues: []Issue{}, listed: make(map[string]bool)}
+
+	robotsURL := origin + "/robots.txt"
+	if r.robots = FetchRobots(ctx, robotsURL); r.robots != nil {
+		r.Robots = robotsURL
+	}
+
+	var queue []string
+	switch {
+	case looksLikeSitemap(site.Path):
+		queue = []string{site.String()}
+	case r.robots != nil && len(r.robots.Sitemaps) > 0:
+		queue = r.robots.Sitemaps
+	default:
+		queue = []string{origin + "/sitemap.xml"}
+	}
+	if err := r.read(ctx, parser.NormalizeURL(site).Host, queue, cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Listed reports whether the sitemaps list the URL, ignoring its fragment.
+func (r *Report) Listed(u *url.URL) bool {
+	return r.listed[parser.NormalizeURL(u).String()]
+}
+
+// Analyzable returns the listed URLs that robots.txt allows to be fetched.
+func (r *Report) Analyzable() []string {
+	var urls []string
+	for _, e := range r.Entries {
+		if !e.Blocked {
+			urls = append(urls, e.URL)
+		}
+	}
+	return urls
+}
+
+// Unlisted finds pages that the analyzed pages link to internally but that the
+// sitemaps do not list. Links blocked by robots.txt and links found broken are not
+// expected in a sitemap and are skipped.
+func (r *Report) Unlisted(pages []*parser.AnalysisResult) []Issue {
+	issues := []Issue{}
+	seen := make(map[string]bool)
+	site, _ := url.Parse(r.Site)
+	host := parser.NormalizeURL(site).Host
+	for _, page := range pages {
+		for _, link := range page.Links {
+			if !link.Internal || (link.Checked && !link.Accessible) {
+				continue
+			}
+			u, err := url.Parse(link.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || parser.NormalizeURL(u).Host != host {
+				continue
+			}
+			key := parser.NormalizeURL(u).String()
+			if r.listed[key] || seen[key] || !r.robots.Allowed(u) {
+				continue
+			}
+			seen[key] = true
+			issues = append(issues, Issue{Type: IssueUnlisted, URL: link.URL, Detail: "linked from " + page.URL})
+			metrics.SitemapIssues.WithLabelValues(IssueUnlisted).Inc()
+		}
+	}
+	return issues
+}
+
+// read reads the queued sitemaps, following sitemap indexes, and collects their entries.
+// It fails only if no sitemap could be read at all.
+func (r *Report) read(ctx context.Context, host string, queue []string, cfg Config) error {
+	visited := make(map[string]bool)
+	var firstErr error
+	for len(queue) > 0 {
+		loc := queue[0]
+		queue = queue[1:]
+		if visited[loc] {
+			continue
+		}
+		if len(r.Sitemaps) == cfg.MaxSitemaps {
+			r.issue(IssueTruncated, loc, "", fmt.Sprintf("not read: the audit reads at most %d sitemaps", cfg.MaxSitemaps))
+			break
+		}
+		visited[loc] = true
+
+		doc, index, err := fetchSitemap(ctx, loc)
+		if err != nil {
+			metrics.SitemapsFetched.WithLabelValues("error").Inc()
+			r.Sitemaps = append(r.Sitemaps, Sitemap{URL: loc, Error: err.Error()})
+			r.issue(IssueSitemapError, loc, "", err.Error())
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", loc, err)
+			}
+			continue
+		}
+		metrics.SitemapsFetched.WithLabelValues("ok").Inc()
+
+		if index {
+			r.Sitemaps = append(r.Sitemaps, Sitemap{URL: loc, Index: true, Entries: len(doc.Sitemaps)})
+			for _, child := range doc.Sitemaps {
+				if r.validEntry(child, loc, host) {
+					queue = append(queue, strings.TrimSpace(child.Loc))
+				}
+			}
+			continue
+		}
+		r.Sitemaps = append(r.Sitemaps, Sitemap{URL: loc, Entries: len(doc.URLs)})
+		for i, e := range doc.URLs {
+			if len(r.Entries) == cfg.MaxURLs {
+				r.issue(IssueTruncated, loc, "", fmt.Sprintf("%d entries not read: the audit reads at most %d URLs", len(doc.URLs)-i, cfg.MaxURLs))
+				return nil
+			}
+			r.addEntry(e, loc, host)
+		}
+	}
+
+	if len(r.Entries) == 0 && firstErr != nil {
+		return fmt.Errorf("%w: %w", ErrNoSitemap, firstErr)
+	}
+	return nil
+}
+
+// validEntry checks the loc and lastmod of a sitemap entry and records their problems.
+// It reports whether the loc can be used.
+func (r *Report) validEntry(e entryXML, sitemap, host string) bool {
+	loc := strings.TrimSpace(e.Loc)
+	u, err := url.Parse(loc)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		r.issue(IssueInvalidURL, loc, sitemap, "not an absolute HTTP(S) URL")
+		return false
+	}
+	if parser.NormalizeURL(u).Host != host {
+		r.issue(IssueOffSite, loc, sitemap, "sitemaps may only list URLs of "+host)
+		return false
+	}
+	if lastmod := strings.TrimSpace(e.Lastmod); lastmod != "" && !ValidLastmod(lastmod) {
+		r.issue(IssueInvalidLastmod, loc, sitemap, fmt.Sprintf("lastmod %q is not a W3C Datetime such as 2024-05-01 or 2024-05-01T12:30:00+00:00", lastmod))
+	}
+	return true
+}
+
+// addEntry adds a listed URL to the report unless it is invalid or a duplicate.
+func (r *Report) addEntry(e entryXML, sitemap, host string) {
+	if !r.validEntry(e, sitemap, host) {
+		return
+	}
+	loc := strings.TrimSpace(e.Loc)
+	u, _ := url.Parse(loc)
+	key := parser.NormalizeURL(u).String()
+	if r.listed[key] {
+		r.issue(IssueDuplicate, loc, sitemap, "listed more than once")
+		return
+	}
+	r.listed[key] = true
+
+	entry := Entry{URL: loc, Lastmod: strings.TrimSpace(e.Lastmod), Sitemap: sitemap}
+	if !r.robots.Allowed(u) {
+		entry.Blocked = true
+		r.issue(IssueBlocked, loc, sitemap, "disallowed by robots.txt")
+	}
+	r.Entries = append(r.Entries, entry)
+}
+
+// check requests every listed URL that robots.txt allows, without following redirects,
+// and records redirects and statuses other than 200.
+func (r *Report) check(ctx context.Context, parallelism int) {
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := range r.Entries {
+		if r.Entries[i].Blocked {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(e *Entry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			checkEntry(ctx, e)
+		}(&r.Entries[i])
+	}
+	wg.Wait()
+
+	// Issues are added afterwards to keep them in sitemap order
+	for _, e := range r.Entries {
+		switch {
+		case e.Blocked:
+		case e.Error != "":
+			r.issue(IssueStatus, e.URL, e.Sitemap, "unreachable: "+e.Error)
+		case e.StatusCode >= 300 && e.StatusCode < 400:
+			r.issue(IssueRedirect, e.URL, e.Sitemap, fmt.Sprintf("%d %s to %s", e.StatusCode, http.StatusText(e.StatusCode), e.Location))
+		case e.StatusCode != http.StatusOK:
+			r.issue(IssueStatus, e.URL, e.Sitemap, fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)))
+		}
+	}
+}
+
+// checkEntry requests a listed URL with HEAD, falling back to GET for servers that
+// do not support HEAD, and stores the outcome in the entry.
+func checkEntry(ctx context.Context, e *Entry) {
+	resp, err := request(ctx, checkClient, http.MethodHead, e.URL)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = request(ctx, checkClient, http.MethodGet, e.URL)
+	}
+	if err != nil {
+		e.Error = err.Error()
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	e.StatusCode = resp.StatusCode
+	if loc, err := resp.Location(); err == nil {
+		e.Location = loc.String()
+	}
+}
+
+// issue records a problem found by the audit.
+func (r *Report) issue(typ, u, sitemap, detail string) {
+	r.Issues = append(r.Issues, Issue{Type: typ, URL: u, Sitemap: sitemap, Detail: detail})
+}
+
+// FetchRobots fetches and parses robots.txt. It returns nil if the site has none or
+// it cannot be fetched, in which case everything is allowed.
+func FetchRobots(ctx context.Context, robotsURL string) *Robots {
+	resp, err := request(ctx, httpClient, http.MethodGet, robotsURL)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Unable to fetch robots.txt", "url", robotsURL, "error", err)
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+	if err != nil {
+		return nil
+	}
+	return ParseRobots(string(body))
+}
+
+// fetchSitemap fetches and parses a sitemap or sitemap index.
+func fetchSitemap(ctx context.Context, loc string) (document, bool, error) {
+	resp, err := request(ctx, httpClient, http.MethodGet, loc)
+	if err != nil {
+		return document{}, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return document{}, false, fmt.Errorf("status %s", resp.Status)
+	}
+	return parseDocument(resp.Body)
+}
+
+// request sends a request identified as a sitemap audit.
+func request(ctx context.Context, client *http.Client, method, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	return client.Do(req)
+}
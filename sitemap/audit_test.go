@@ -0,0 +1,128 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"lucytech/parser"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newSite serves robots.txt, a sitemap index with a plain and a gzipped sitemap, and pages
+func newSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "User-agent: *\nDisallow: /admin/\nSitemap: %s/sitemap_index.xml\n", srv.URL)
+	})
+	mux.HandleFunc("/sitemap_index.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%[1]s/pages.xml</loc></sitemap><sitemap><loc>%[1]s/posts.xml.gz</loc></sitemap><sitemap><loc>%[1]s/gone.xml</loc></sitemap></sitemapindex>`, srv.URL)
+	})
+	mux.HandleFunc("/pages.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<urlset>
+<url><loc>%[1]s/</loc><lastmod>2024-05-01</lastmod></url>
+<url><loc>%[1]s/old</loc><lastmod>01/05/2024</lastmod></url>
+<url><loc>%[1]s/admin/</loc></url>
+<url><loc>https://other.example/</loc></url>
+<url><loc>%[1]s/</loc></url>
+</urlset>`, srv.URL)
+	})
+	mux.HandleFunc("/posts.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		var b bytes.Buffer
+		gz := gzip.NewWriter(&b)
+		fmt.Fprintf(gz, `<urlset><url><loc>%s/missing</loc></url></urlset>`, srv.URL)
+		gz.Close()
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Write(b.Bytes())
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed) // HEAD falls back to GET
+		}
+	})
+	return srv
+}
+
+// TestAudit checks discovery through robots.txt, index traversal and every issue type found there
+func TestAudit(t *testing.T) {
+	srv := newSite(t)
+	r, err := Audit(context.Background(), srv.URL, Config{})
+	if err != nil {
+		t.Fatalf("Audit returned error: %v", err)
+	}
+	if r.Robots != srv.URL+"/robots.txt" || len(r.Sitemaps) != 4 || !r.Sitemaps[0].Index {
+		t.Errorf("robots %q, sitemaps %+v; want robots.txt and the index with 3 sitemaps", r.Robots, r.Sitemaps)
+	}
+
+	var got []string
+	for _, issue := range r.Issues {
+		got = append(got, issue.Type+" "+strings.TrimPrefix(issue.URL, srv.URL))
+	}
+	want := []string{
+		IssueInvalidLastmod + " /old",
+		IssueBlocked + " /admin/",
+		IssueOffSite + " https://other.example/",
+		IssueDuplicate + " /",
+		IssueSitemapError + " /gone.xml",
+		IssueRedirect + " /old",
+		IssueStatus + " /missing",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("issues:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if r.Entries[0].StatusCode != http.StatusOK || r.Entries[1].Location != srv.URL+"/new" {
+		t.Errorf("entries = %+v; want / to answer 200 and /old to redirect to /new", r.Entries)
+	}
+	if got := r.Analyzable(); len(got) != 3 {
+		t.Errorf("Analyzable = %v; want the 3 entries not blocked by robots.txt", got)
+	}
+
+	// Internal links missing from the sitemap, except blocked, broken and external ones
+	issues := r.Unlisted([]*parser.AnalysisResult{{
+		URL: srv.URL + "/",
+		Links: []parser.LinkResult{
+			{URL: srv.URL + "/old#top", Internal: true},
+			{URL: srv.URL + "/contact", Internal: true, Checked: true, Accessible: true},
+			{URL: srv.URL + "/contact", Internal: true},
+			{URL: srv.URL + "/admin/users", Internal: true},
+			{URL: srv.URL + "/broken", Internal: true, Checked: true},
+			{URL: "https://other.example/", Checked: true, Accessible: true},
+		},
+	}})
+	if len(issues) != 1 || issues[0].URL != srv.URL+"/contact" || issues[0].Type != IssueUnlisted {
+		t.Errorf("Unlisted = %+v; want only /contact", issues)
+	}
+}
+
+// TestAudit_Fallback checks the /sitemap.xml fallback, sitemap URLs and sites without sitemaps
+func TestAudit_Fallback(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<urlset><url><loc>%s/sitemap.xml</loc></url></urlset>`, srv.URL)
+	})
+
+	r, err := Audit(context.Background(), srv.URL, Config{})
+	if err != nil || r.Robots != "" || len(r.Entries) != 1 {
+		t.Errorf("fallback: report %+v, err %v; want /sitemap.xml without robots.txt", r, err)
+	}
+	if r, err = Audit(context.Background(), srv.URL+"/sitemap.xml", Config{MaxURLs: 1}); err != nil || len(r.Sitemaps) != 1 {
+		t.Errorf("sitemap URL: report %+v, err %v; want the given sitemap", r, err)
+	}
+
+	if _, err := Audit(context.Background(), srv.URL+"/none.xml", Config{}); !errors.Is(err, ErrNoSitemap) {
+		t.Errorf("missing sitemap: err %v; want ErrNoSitemap", err)
+	}
+}
@@ -0,0 +1,134 @@
+package sitemap
+
+import (
+	"bufio"
+	"net/url"
+	"strings"
+)
+
+// robotsToken is the product token matched against the User-agent lines of robots.txt.
+const robotsToken = "lucytech"
+
+// maxRobotsSize is the part of robots.txt that is parsed, as RFC 9309 allows.
+const maxRobotsSize = 500 << 10
+
+// Robots holds the rules of a robots.txt file that apply to this tool, and the
+// sitemaps it lists. The zero value allows everything.
+type Robots struct {
+	Sitemaps []string // Absolute URLs of the Sitemap lines
+	rules    []robotsRule
+}
+
+// robotsRule is an Allow or Disallow line of the selected group.
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// ParseRobots parses a robots.txt file as specified by RFC 9309. The rules of the
+// groups naming "lucytech" apply; without such groups those of the "*" groups do.
+func ParseRobots(text string) *Robots {
+	r := &Robots{}
+	var own, any []robotsRule
+	var agents []string
+	inRules := false // A rule line ends the User-agent lines that start a group
+	hasOwn := false
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRobotsSize)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue // An empty Disallow allows everything
+			}
+			rule := robotsRule{allow: key == "allow", pattern: value}
+			for _, agent := range agents {
+				switch agent {
+				case robotsToken:
+					own = append(own, rule)
+					hasOwn = true
+				case "*":
+					any = append(any, rule)
+				}
+			}
+		case "sitemap":
+			// Sitemap lines are independent of the groups
+			if u, err := url.Parse(value); err == nil && u.IsAbs() {
+				r.Sitemaps = append(r.Sitemaps, value)
+			}
+		}
+	}
+
+	r.rules = any
+	if hasOwn {
+		r.rules = own
+	}
+	return r
+}
+
+// Allowed reports whether the rules allow fetching the URL. The longest matching
+// pattern decides, and Allow wins over Disallow for patterns of the same length.
+// A nil Robots allows everything.
+func (r *Robots) Allowed(u *url.URL) bool {
+	if r == nil {
+		return true
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > longest || (n == longest && rule.allow) {
+			allowed, longest = rule.allow, n
+		}
+	}
+	return allowed
+}
+
+// robotsMatch reports whether a path matches a robots.txt pattern, where "*" matches
+// any sequence of characters and a trailing "$" anchors the pattern at the end.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		// The last part of an anchored pattern must match the end of the path
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	return !anchored || rest == ""
+}
@@ -0,0 +1,56 @@
+package sitemap
+
+import (
+	"net/url"
+	"testing"
+)
+
+// TestRobots checks group selection, longest-match precedence, wildcards and sitemap lines
+func TestRobots(t *testing.T) {
+	r := ParseRobots(`
+# Everyone
+User-agent: *
+Disallow: /private/
+Allow: /private/press$
+
+User-agent: Googlebot
+User-agent: LucyTech
+Disallow: /drafts/
+Disallow: /*.pdf$
+Allow: /drafts/public
+Disallow:
+
+Sitemap: https://example.com/sitemap_index.xml
+Sitemap: /relative.xml
+`)
+	if len(r.Sitemaps) != 1 || r.Sitemaps[0] != "https://example.com/sitemap_index.xml" {
+		t.Errorf("Sitemaps = %v; want the absolute sitemap URL only", r.Sitemaps)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/", true},
+		{"/private/page", true}, // The lucytech group replaces the * group
+		{"/drafts/post", false},
+		{"/drafts/public/post", true}, // The longer Allow wins
+		{"/docs/guide.pdf", false},
+		{"/docs/guide.pdf?download=1", true}, // $ anchors at the end of the path and query
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse("https://example.com" + tt.path)
+		if got := r.Allowed(u); got != tt.want {
+			t.Errorf("Allowed(%s) = %v; want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// Without a lucytech group, the * group applies
+	r = ParseRobots("User-agent: *\nDisallow: /private/\nAllow: /private/press$\n")
+	for path, want := range map[string]bool{"/private/page": false, "/private/press": true, "/private/press/2024": false} {
+		u, _ := url.Parse("https://example.com" + path)
+		if got := r.Allowed(u); got != want {
+			t.Errorf("* group: Allowed(%s) = %v; want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Package sitemap discovers a site's XML sitemaps through robots.txt or /sitemap.xml,
+// reads them, including sitemap indexes and gzipped sitemaps, and audits the listed
+// URLs for problems such as redirects, error statuses, entries blocked by robots.txt
+// and malformed lastmod dates.
+package sitemap
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxSitemapSize is the largest uncompressed sitemap accepted, as set by the sitemap protocol.
+const maxSitemapSize = 50 << 20
+
+// ErrNotSitemap is returned for XML documents that are neither a urlset nor a sitemap index.
+var ErrNotSitemap = errors.New("not a sitemap")
+
+// document is the root element of a sitemap or a sitemap index. Elements are matched
+// by local name, so the sitemap namespace is optional.
+type document struct {
+	XMLName  xml.Name
+	URLs     []entryXML `xml:"url"`
+	Sitemaps []entryXML `xml:"sitemap"`
+}
+
+// entryXML is a <url> of a sitemap or a <sitemap> of a sitemap index.
+type entryXML struct {
+	Loc     string `xml:"loc"`
+	Lastmod string `xml:"lastmod"`
+}
+
+// parseDocument reads a sitemap or a sitemap index, decompressing it first if it is
+// gzipped. It reports whether the document is an index.
+func parseDocument(r io.Reader) (doc document, index bool, err error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return doc, false, fmt.Errorf("invalid gzip data: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(r, maxSitemapSize+1))
+	if err != nil {
+		return doc, false, err
+	}
+	if len(raw) > maxSitemapSize {
+		return doc, false, fmt.Errorf("sitemap exceeds %d bytes", maxSitemapSize)
+	}
+	if err := xml.Unmarshal(raw, &doc); err != nil {
+		return doc, false, fmt.Errorf("invalid XML: %w", err)
+	}
+	switch doc.XMLName.Local {
+	case "urlset":
+		return doc, false, nil
+	case "sitemapindex":
+		return doc, true, nil
+	default:
+		return doc, false, fmt.Errorf("%w: root element is <%s>", ErrNotSitemap, doc.XMLName.Local)
+	}
+}
+
+// lastmodLayouts are the W3C Datetime formats allowed for lastmod.
+var lastmodLayouts = []string{
+	"2006",
+	"2006-01",
+	"2006-01-02",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05Z07:00", // Parsing also accepts fractions of a second
+}
+
+// ValidLastmod reports whether a lastmod value is a W3C Datetime, e.g. "2024-05-01"
+// or "2024-05-01T12:30:00+02:00". Times must carry a time zone.
+func ValidLastmod(s string) bool {
+	for _, layout := range lastmodLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// looksLikeSitemap reports whether a URL path names a sitemap file rather than a site.
+func looksLikeSitemap(path string) bool {
+	path = strings.ToLower(path)
+	return strings.HasSuffix(path, ".xml") || strings.HasSuffix(path, ".xml.gz")
+}
@@ -0,0 +1,49 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestParseDocument checks urlsets, indexes, gzipped sitemaps and other documents
+func TestParseDocument(t *testing.T) {
+	urlset := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/</loc><lastmod>2024-05-01</lastmod></url>
+  <url><loc> https://example.com/about </loc></url>
+</urlset>`
+	doc, index, err := parseDocument(strings.NewReader(urlset))
+	if err != nil || index || len(doc.URLs) != 2 || doc.URLs[0].Lastmod != "2024-05-01" {
+		t.Errorf("urlset: doc %+v, index %v, err %v; want 2 URLs", doc, index, err)
+	}
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(`<sitemapindex><sitemap><loc>https://example.com/posts.xml.gz</loc></sitemap></sitemapindex>`))
+	w.Close()
+	doc, index, err = parseDocument(&gz)
+	if err != nil || !index || len(doc.Sitemaps) != 1 {
+		t.Errorf("gzipped index: doc %+v, index %v, err %v; want 1 sitemap", doc, index, err)
+	}
+
+	if _, _, err := parseDocument(strings.NewReader(`<html><body>Not found</body></html>`)); !errors.Is(err, ErrNotSitemap) {
+		t.Errorf("HTML page: err %v; want ErrNotSitemap", err)
+	}
+}
+
+// TestValidLastmod checks the W3C Datetime formats
+func TestValidLastmod(t *testing.T) {
+	for _, s := range []string{"2024", "2024-05", "2024-05-01", "2024-05-01T12:30Z", "2024-05-01T12:30:00+02:00", "2024-05-01T12:30:00.5Z"} {
+		if !ValidLastmod(s) {
+			t.Errorf("ValidLastmod(%q) = false; want true", s)
+		}
+	}
+	for _, s := range []string{"2024-13-01", "01/05/2024", "2024-05-01 12:30:00", "2024-05-01T12:30:00", "yesterday"} {
+		if ValidLastmod(s) {
+			t.Errorf("ValidLastmod(%q) = true; want false", s)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package throttle
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// TestClientIP checks that forwarding headers are only trusted from configured proxies
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.10")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies returned error: %v", err)
+	}
+
+	tests := []struct {
+		name, remote, forwarded, realIP, want string
+	}{
+		{"direct client", "203.0.113.5:1234", "", "", "203.0.113.5"},
+		{"untrusted proxy is ignored", "203.0.113.5:1234", "198.51.100.1", "", "203.0.113.5"},
+		{"trusted proxy", "10.1.2.3:1234", "198.51.100.1", "", "198.51.100.1"},
+		{"spoofed entries left of the client", "10.1.2.3:1234", "1.2.3.4, 198.51.100.1, 192.0.2.10", "", "198.51.100.1"},
+		{"real IP fallback", "192.0.2.10:1234", "", "198.51.100.7", "198.51.100.7"},
+		{"only proxies", "10.1.2.3:1234", "10.9.9.9", "", "10.1.2.3"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if tt.realIP != "" {
+			r.Header.Set("X-Real-IP", tt.realIP)
+		}
+		if got := ClientIP(r, trusted); got != tt.want {
+			t.Errorf("%s: ClientIP = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := ParseTrustedProxies("not-an-ip"); err == nil {
+		t.Error("expected error for invalid proxy")
+	}
+}
@@ -0,0 +1,83 @@
+// Package throttle protects the analyzer from overload: it caps concurrent analyses,
+// queues the excess for a bounded time and rate-limits clients by IP address.
+package throttle
+
+import (
+	"context"
+	"errors"
+	"lucytech/metrics"
+	"sync/atomic"
+	"time"
+)
+
+// Errors returned by Gate.Acquire when an analysis cannot start.
+var (
+	ErrQueueFull    = errors.New("server is busy: too many analyses queued")
+	ErrQueueTimeout = errors.New("server is busy: timed out waiting for a free analysis slot")
+)
+
+// Gate caps the number of analyses running at once. Requests beyond the cap wait in a
+// bounded queue until a slot frees up or the queue timeout expires.
+type Gate struct {
+	slots    chan struct{} // One token per running analysis
+	waiting  atomic.Int64  // Requests currently queued
+	maxQueue int64         // Upper bound on waiting
+	timeout  time.Duration // Longest time a request may wait
+}
+
+// NewGate creates a gate running at most maxConcurrent analyses, with up to maxQueue
+// requests waiting at most timeout each. maxConcurrent must be positive.
+func NewGate(maxConcurrent, maxQueue int, timeout time.Duration) *Gate {
+	return &Gate{
+		slots:    make(chan struct{}, maxConcurrent),
+		maxQueue: int64(maxQueue),
+		timeout:  timeout,
+	}
+}
+
+// Acquire waits for a free slot and returns the function that releases it.
+// It fails immediately with ErrQueueFull if the queue is full, and with
+// ErrQueueTimeout or the context's error if no slot frees up in time.
+func (g *Gate) Acquire(ctx context.Context) (func(), error) {
+	release := func() { <-g.slots }
+
+	// Fast path: a slot is free
+	select {
+	case g.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if g.waiting.Add(1) > g.maxQueue {
+		g.waiting.Add(-1)
+		return nil, ErrQueueFull
+	}
+	metrics.QueueLength.Inc()
+	start := time.Now()
+	defer func() {
+		g.waiting.Add(-1)
+		metrics.QueueLength.Dec()
+		metrics.QueueWait.Observe(time.Since(start).Seconds())
+	}()
+
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+	select {
+	case g.slots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, ErrQueueTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Saturated reports whether the queue is full, i.e. new analyses are being turned away.
+func (g *Gate) Saturated() bool {
+	return g.waiting.Load() >= g.maxQueue && len(g.slots) == cap(g.slots)
+}
+
+// Timeout returns how long a request may wait in the queue.
+func (g *Gate) Timeout() time.Duration {
+	return g.timeout
+}
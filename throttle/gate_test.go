@@ -0,0 +1,50 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestGate covers running, queuing, a full queue and the queue timeout
+func TestGate(t *testing.T) {
+	g := NewGate(1, 1, 50*time.Millisecond)
+	ctx := context.Background()
+
+	release, err := g.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("first Acquire returned error: %v", err)
+	}
+
+	// The second request queues and gets the slot once it is released
+	acquired := make(chan error, 1)
+	go func() {
+		r, err := g.Acquire(ctx)
+		if err == nil {
+			defer r()
+		}
+		acquired <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// The queue holds one request, so a third is turned away immediately
+	if !g.Saturated() {
+		t.Error("Saturated = false with a running analysis and a full queue")
+	}
+	if _, err := g.Acquire(ctx); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("third Acquire error = %v; want ErrQueueFull", err)
+	}
+
+	release()
+	if err := <-acquired; err != nil {
+		t.Errorf("queued Acquire returned error: %v", err)
+	}
+
+	// A request waiting longer than the timeout gives up
+	release, _ = g.Acquire(ctx)
+	defer release()
+	if _, err := g.Acquire(ctx); !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("Acquire on busy gate error = %v; want ErrQueueTimeout", err)
+	}
+}
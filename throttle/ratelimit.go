@@ -0,0 +1,84 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// minIdleTimeout is the shortest time a client's bucket is kept after its last request.
+// Buckets that take longer to refill are kept until they are full, see NewIPLimiter.
+const minIdleTimeout = 10 * time.Minute
+
+// client is the token bucket of one IP address.
+type client struct {
+	bucket   *rate.Limiter
+	lastSeen time.Time
+}
+
+// IPLimiter rate-limits requests per client IP address with token buckets.
+type IPLimiter struct {
+	mu        sync.Mutex
+	limit     rate.Limit    // Tokens added per second
+	burst     int           // Bucket size
+	idle      time.Duration // How long idle buckets are kept; they are full again by then
+	clients   map[string]*client
+	lastSweep time.Time        // When idle clients were last removed
+	now       func() time.Time // Clock, replaceable in tests
+}
+
+// NewIPLimiter allows each IP perMinute requests per minute with bursts of up to burst
+// requests. perMinute must be positive.
+func NewIPLimiter(perMinute float64, burst int) *IPLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	// An empty bucket is full again after burst requests' worth of time; dropping it
+	// earlier would hand an idle client a fresh burst
+	refill := time.Duration(float64(burst) / perMinute * float64(time.Minute))
+	return &IPLimiter{
+		limit:   rate.Limit(perMinute / 60),
+		burst:   burst,
+		idle:    max(minIdleTimeout, refill),
+		clients: make(map[string]*client),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token for ip. If the bucket is empty it returns false and how long
+// the client should wait before retrying.
+func (l *IPLimiter) Allow(ip string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	c, ok := l.clients[ip]
+	if !ok {
+		c = &client{bucket: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[ip] = c
+	}
+	c.lastSeen = now
+
+	r := c.bucket.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now) // Rejected requests do not use up tokens
+		return false, delay
+	}
+	return true, 0
+}
+
+// sweep removes clients idle for longer than l.idle, at most once a minute.
+// Callers hold l.mu.
+func (l *IPLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for ip, c := range l.clients {
+		if now.Sub(c.lastSeen) > l.idle {
+			delete(l.clients, ip)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+// TestIPLimiter checks that clients are limited independently and idle ones are forgotten
+func TestIPLimiter(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	l := NewIPLimiter(60, 2)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("192.0.2.1"); !ok {
+			t.Fatalf("request %d within burst rejected", i)
+		}
+	}
+	ok, retryAfter := l.Allow("192.0.2.1")
+	if ok || retryAfter <= 0 || retryAfter > time.Second {
+		t.Errorf("Allow over burst = %v, %s; want rejection with retry within 1s", ok, retryAfter)
+	}
+
+	// Another client has its own bucket
+	if ok, _ := l.Allow("192.0.2.2"); !ok {
+		t.Error("request from another client rejected")
+	}
+
+	now = now.Add(minIdleTimeout + 2*time.Minute)
+	l.Allow("192.0.2.3")
+	if _, ok := l.clients["192.0.2.1"]; ok {
+		t.Error("idle client was not removed")
+	}
+}
+
+// TestIPLimiter_SlowRefill checks that buckets are kept until they have refilled
+func TestIPLimiter_SlowRefill(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	l := NewIPLimiter(1, 30) // An empty bucket takes 30 minutes to refill
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 30; i++ {
+		l.Allow("192.0.2.1")
+	}
+	now = now.Add(minIdleTimeout + 2*time.Minute)
+	if ok, _ := l.Allow("192.0.2.1"); !ok {
+		t.Fatal("request after refilling 12 tokens rejected")
+	}
+	now = now.Add(minIdleTimeout + 2*time.Minute)
+	l.Allow("192.0.2.2")
+	if _, ok := l.clients["192.0.2.1"]; !ok {
+		t.Error("client was removed before its bucket refilled")
+	}
+
+	now = now.Add(31 * time.Minute)
+	l.Allow("192.0.2.2")
+	if _, ok := l.clients["192.0.2.1"]; ok {
+		t.Error("client idle for longer than the refill time was not removed")
+	}
+}
@@ -0,0 +1,73 @@
+// Package tracing sets up OpenTelemetry distributed tracing for the analyzer.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Supported span exporters.
+const (
+	ExporterNone   = "none"   // Tracing disabled
+	ExporterStdout = "stdout" // Pretty-printed spans on standard output, for local debugging
+	ExporterOTLP   = "otlp"   // OTLP over HTTP to a collector
+)
+
+// Config selects where spans are exported.
+type Config struct {
+	Exporter    string // One of the Exporter constants; empty means none
+	Endpoint    string // OTLP endpoint URL, e.g. http://localhost:4318; empty uses the OTEL_EXPORTER_OTLP_* environment
+	ServiceName string // Value of the service.name resource attribute
+}
+
+// Init installs the global tracer provider and W3C trace context propagation.
+// The returned function flushes buffered spans and must be called on shutdown.
+func Init(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	// Propagate incoming trace context even when spans are not exported
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to create %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Middleware creates a server span for every request to the route and continues
+// any trace started by the caller.
+func Middleware(route string, next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, route, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return r.Method + " " + route
+	}))
+}
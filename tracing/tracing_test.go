@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestInit covers the disabled, stdout and unknown exporters
+func TestInit(t *testing.T) {
+	for _, exporter := range []string{"", ExporterNone, ExporterStdout} {
+		shutdown, err := Init(context.Background(), Config{Exporter: exporter, ServiceName: "test"})
+		if err != nil {
+			t.Fatalf("Init(%q) returned error: %v", exporter, err)
+		}
+		if err := shutdown(context.Background()); err != nil {
+			t.Errorf("shutdown(%q) returned error: %v", exporter, err)
+		}
+	}
+
+	if _, err := Init(context.Background(), Config{Exporter: "zipkin"}); err == nil {
+		t.Error("expected error for unknown exporter")
+	}
+}
+
+// TestMiddleware_ContinuesTrace checks that handlers run inside the trace started by the caller
+func TestMiddleware_ContinuesTrace(t *testing.T) {
+	if _, err := Init(context.Background(), Config{}); err != nil {
+		t.Fatalf("Init returned error: %v", err)
+	}
+
+	var got trace.TraceID
+	h := Middleware("/analyze", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = trace.SpanContextFromContext(r.Context()).TraceID()
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/analyze", nil)
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if want := "4bf92f3577b34da6a3ce929d0e0e4736"; got.String() != want {
+		t.Errorf("trace ID = %s; want %s", got, want)
+	}
+}
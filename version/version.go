@@ -0,0 +1,39 @@
+// Package version describes the running build.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, set at link time, e.g.
+//
+//	go build -ldflags "-X lucytech/version.Version=1.2.0 -X lucytech/version.Commit=$(git rev-parse HEAD)"
+var (
+	Version = "dev" // Release version of the build
+	Commit  = ""    // VCS revision; taken from the Go build info when not set
+)
+
+// Info is the build metadata reported by /version and the build_info metric.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the metadata of the running build. A missing commit is filled in from
+// the VCS information the Go toolchain embeds when building inside a repository.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, GoVersion: runtime.Version()}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			for _, s := range bi.Settings {
+				if s.Key == "vcs.revision" {
+					info.Commit = s.Value
+				}
+			}
+		}
+	}
+	return info
+}